@@ -20,15 +20,19 @@ package adminclient
 
 import (
 	"context"
+	"errors"
 
 	grpcclient "github.com/dvaumoron/puzzlegrpcclient"
 	pb "github.com/dvaumoron/puzzlerightservice"
 	adminservice "github.com/dvaumoron/puzzleweb/admin/service"
 	"github.com/dvaumoron/puzzleweb/common"
+	"github.com/dvaumoron/puzzleweb/common/grpcretry"
 	"github.com/dvaumoron/puzzleweb/common/log"
 	"google.golang.org/grpc"
 )
 
+var errUnknownGroup = errors.New("unknown group")
+
 // check matching with interface
 var _ adminservice.AdminService = RightClient{}
 
@@ -62,6 +66,42 @@ func (client RightClient) RegisterGroup(groupId uint64, groupName string) bool {
 	return true
 }
 
+// create the role when missing (an existing role is not modified), return if it has been created
+func (client RightClient) EnsureRole(ctx context.Context, roleName string, groupName string, actions []string) (bool, error) {
+	groupId, ok := client.nameToGroupId[groupName]
+	if !ok {
+		return false, errUnknownGroup
+	}
+
+	conn, err := client.Dial()
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	rightClient := pb.NewRightClient(conn)
+	roles, err := rightClient.ListRoles(ctx, &pb.ObjectIds{Ids: []uint64{groupId}}, grpcretry.WithRetry())
+	if err != nil {
+		return false, err
+	}
+	for _, role := range roles.List {
+		if role.Name == roleName && role.ObjectId == groupId {
+			return false, nil
+		}
+	}
+
+	response, err := rightClient.UpdateRole(ctx, &pb.Role{
+		Name: roleName, ObjectId: groupId, List: convertActionsForRequest(actions),
+	})
+	if err != nil {
+		return false, err
+	}
+	if !response.Success {
+		return false, common.ErrUpdate
+	}
+	return true, nil
+}
+
 func (client RightClient) AuthQuery(ctx context.Context, userId uint64, groupId uint64, action string) error {
 	conn, err := client.Dial()
 	if err != nil {
@@ -71,7 +111,7 @@ func (client RightClient) AuthQuery(ctx context.Context, userId uint64, groupId
 
 	response, err := pb.NewRightClient(conn).AuthQuery(ctx, &pb.RightRequest{
 		UserId: userId, ObjectId: groupId, Action: convertActionForRequest(action),
-	})
+	}, grpcretry.WithRetry())
 	if err != nil {
 		return err
 	}
@@ -112,7 +152,7 @@ func (client RightClient) GetActions(ctx context.Context, adminId uint64, roleNa
 
 	actions, err := rightClient.RoleRight(ctx, &pb.RoleRequest{
 		Name: roleName, ObjectId: client.nameToGroupId[groupName],
-	})
+	}, grpcretry.WithRetry())
 	if err != nil {
 		return nil, err
 	}
@@ -127,14 +167,17 @@ func (client RightClient) UpdateUser(ctx context.Context, adminId uint64, userId
 	defer conn.Close()
 
 	rightClient := pb.NewRightClient(conn)
-	response, err := rightClient.AuthQuery(ctx, &pb.RightRequest{
-		UserId: adminId, ObjectId: adminservice.AdminGroupId, Action: pb.RightAction_UPDATE,
-	})
-	if err != nil {
-		return err
-	}
-	if !response.Success {
-		return common.ErrNotAuthorized
+	// a user can always remove all its own roles (account deletion)
+	if adminId != userId || len(roles) != 0 {
+		response, err := rightClient.AuthQuery(ctx, &pb.RightRequest{
+			UserId: adminId, ObjectId: adminservice.AdminGroupId, Action: pb.RightAction_UPDATE,
+		})
+		if err != nil {
+			return err
+		}
+		if !response.Success {
+			return common.ErrNotAuthorized
+		}
 	}
 
 	converted := make([]*pb.RoleRequest, 0, len(roles))
@@ -146,7 +189,7 @@ func (client RightClient) UpdateUser(ctx context.Context, adminId uint64, userId
 		}
 	}
 
-	response, err = rightClient.UpdateUser(ctx, &pb.UserRight{UserId: userId, List: converted})
+	response, err := rightClient.UpdateUser(ctx, &pb.UserRight{UserId: userId, List: converted})
 	if err != nil {
 		return err
 	}
@@ -275,7 +318,7 @@ func (client RightClient) getAllGroups(rightClient pb.RightClient, ctx context.C
 		groupIds = append(groupIds, groupId)
 	}
 
-	roles, err := rightClient.ListRoles(ctx, &pb.ObjectIds{Ids: groupIds})
+	roles, err := rightClient.ListRoles(ctx, &pb.ObjectIds{Ids: groupIds}, grpcretry.WithRetry())
 	if err != nil {
 		return nil, err
 	}
@@ -283,7 +326,7 @@ func (client RightClient) getAllGroups(rightClient pb.RightClient, ctx context.C
 }
 
 func (client RightClient) getUserRoles(rightClient pb.RightClient, ctx context.Context, userId uint64) ([]adminservice.Group, error) {
-	roles, err := rightClient.ListUserRoles(ctx, &pb.UserId{Id: userId})
+	roles, err := rightClient.ListUserRoles(ctx, &pb.UserId{Id: userId}, grpcretry.WithRetry())
 	if err != nil {
 		return nil, err
 	}
@@ -316,39 +359,43 @@ func convertActionsFromRequest(actions []pb.RightAction) []string {
 	return resActions
 }
 
+// the protocol of the right service is limited to these actions
+var actionToRequest = map[string]pb.RightAction{
+	adminservice.ActionAccess: pb.RightAction_ACCESS,
+	adminservice.ActionCreate: pb.RightAction_CREATE,
+	adminservice.ActionUpdate: pb.RightAction_UPDATE,
+	adminservice.ActionDelete: pb.RightAction_DELETE,
+}
+
+var requestToAction = reverseActions(actionToRequest)
+
+func reverseActions(actions map[string]pb.RightAction) map[pb.RightAction]string {
+	res := make(map[pb.RightAction]string, len(actions))
+	for name, action := range actions {
+		res[action] = name
+	}
+	return res
+}
+
 func convertActionFromRequest(action pb.RightAction) string {
-	switch action {
-	case pb.RightAction_ACCESS:
-		return adminservice.ActionAccess
-	case pb.RightAction_CREATE:
-		return adminservice.ActionCreate
-	case pb.RightAction_UPDATE:
-		return adminservice.ActionUpdate
-	case pb.RightAction_DELETE:
-		return adminservice.ActionDelete
+	if name, ok := requestToAction[action]; ok {
+		return name
 	}
 	return adminservice.ActionAccess
 }
 
 func convertActionForRequest(action string) pb.RightAction {
-	switch action {
-	case adminservice.ActionAccess:
-		return pb.RightAction_ACCESS
-	case adminservice.ActionCreate:
-		return pb.RightAction_CREATE
-	case adminservice.ActionUpdate:
-		return pb.RightAction_UPDATE
-	case adminservice.ActionDelete:
-		return pb.RightAction_DELETE
-	}
-	return 0
+	return actionToRequest[action]
 }
 
+// unknown actions are ignored (they must not become an access right)
 func convertActionsForRequest(actions []string) []pb.RightAction {
-	resActions := make([]pb.RightAction, 0, 4)
+	resActions := make([]pb.RightAction, 0, len(actionToRequest))
 	// use Set to remove duplicate
 	for action := range common.MakeSet(actions) {
-		resActions = append(resActions, convertActionForRequest(action))
+		if requestAction, ok := actionToRequest[action]; ok {
+			resActions = append(resActions, requestAction)
+		}
 	}
 	return resActions
 }
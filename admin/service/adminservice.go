@@ -32,6 +32,9 @@ const (
 	ActionDelete = "delete"
 )
 
+// every action known by the right service, in display order
+var AllActions = []string{ActionAccess, ActionCreate, ActionUpdate, ActionDelete}
+
 type Group struct {
 	Id    uint64
 	Name  string
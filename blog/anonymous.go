@@ -0,0 +1,108 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package blog
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/mail"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dvaumoron/puzzleweb/common"
+	forumservice "github.com/dvaumoron/puzzleweb/forum/service"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	allowAnonymousName     = "AllowAnonymousComments"
+	maxAnonymousNameLength = 50
+	// beyond, the expired entries are purged and new client ips are refused until there is room
+	maxThrottledClients = 10000
+)
+
+// the email is only kept as a hash, usable with gravatar
+func readAnonymousAuthor(c *gin.Context, wordFilter common.WordFilter) (forumservice.AnonymousAuthor, error) {
+	name := strings.TrimSpace(c.PostForm("name"))
+	if name == "" {
+		return forumservice.AnonymousAuthor{}, common.ErrEmptyName
+	}
+	if common.TooLong(name, maxAnonymousNameLength) {
+		return forumservice.AnonymousAuthor{}, common.ErrNameTooLong
+	}
+
+	name, err := wordFilter.Check(name)
+	if err != nil {
+		return forumservice.AnonymousAuthor{}, err
+	}
+
+	emailHash := ""
+	if email := strings.TrimSpace(c.PostForm("email")); email != "" {
+		address, err := mail.ParseAddress(email)
+		if err != nil || address.Address != email {
+			return forumservice.AnonymousAuthor{}, common.ErrWrongEmail
+		}
+		hash := sha256.Sum256([]byte(strings.ToLower(email)))
+		emailHash = hex.EncodeToString(hash[:])
+	}
+	return forumservice.AnonymousAuthor{Name: name, EmailHash: emailHash}, nil
+}
+
+// in memory record of the last anonymous comment of each client ip
+type commentThrottle struct {
+	delay   time.Duration
+	mutex   sync.Mutex
+	clients map[string]time.Time
+}
+
+// return nil when there is no delay
+func newCommentThrottle(delay time.Duration) *commentThrottle {
+	if delay == 0 {
+		return nil
+	}
+	return &commentThrottle{delay: delay, clients: map[string]time.Time{}}
+}
+
+// a refused comment does not delay the next allowed one
+func (t *commentThrottle) allow(clientIp string) bool {
+	if t == nil {
+		return true
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := time.Now()
+	if next, ok := t.clients[clientIp]; ok && now.Before(next) {
+		return false
+	}
+	if len(t.clients) >= maxThrottledClients {
+		for ip, next := range t.clients {
+			if now.After(next) {
+				delete(t.clients, ip)
+			}
+		}
+		if len(t.clients) >= maxThrottledClients {
+			return false
+		}
+	}
+	t.clients[clientIp] = now.Add(t.delay)
+	return true
+}
@@ -19,8 +19,10 @@
 package blog
 
 import (
+	"context"
 	"errors"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -28,9 +30,10 @@ import (
 	blogservice "github.com/dvaumoron/puzzleweb/blog/service"
 	"github.com/dvaumoron/puzzleweb/common"
 	"github.com/dvaumoron/puzzleweb/common/config"
+	"github.com/dvaumoron/puzzleweb/common/log"
 	puzzleweb "github.com/dvaumoron/puzzleweb/core"
+	forumservice "github.com/dvaumoron/puzzleweb/forum/service"
 	"github.com/gin-gonic/gin"
-	"github.com/gorilla/feeds"
 	"go.uber.org/zap"
 )
 
@@ -38,12 +41,16 @@ const emptyTitle = "EmptyPostTitle"
 const emptyContent = "EmptyPostContent"
 
 const postIdName = "postId"
+const slugName = "slug"
 const commentMsgName = "CommentMsg"
+const viewName = "view"
+const printView = "print"
+const sortName = "sort"
+const commentSortName = "CommentSort"
 
 const parsingPostIdErrorMsg = "Failed to parse postId"
 
 var errEmptyComment = errors.New("EmptyComment")
-var errFeedFormat = errors.New("unrecognized feed format")
 
 // TODO draft with modify until publish ?
 // TODO use forum service for blog storage ?
@@ -54,44 +61,72 @@ type blogWidget struct {
 	deleteCommentHandler gin.HandlerFunc
 	createHandler        gin.HandlerFunc
 	previewHandler       gin.HandlerFunc
+	draftHandler         gin.HandlerFunc
 	saveHandler          gin.HandlerFunc
 	deleteHandler        gin.HandlerFunc
 	rssHandler           gin.HandlerFunc
+	moderation           *moderationWidget // nil when moderation is disabled
 }
 
 func (w blogWidget) LoadInto(router gin.IRouter) {
 	router.GET("/", w.listHandler)
 	router.GET("/view/:postId", w.viewHandler)
+	router.GET("/view/:postId/:slug", w.viewHandler)
 	router.POST("/comment/save/:postId", w.saveCommentHandler)
 	router.GET("/comment/delete/:postId/:commentId", w.deleteCommentHandler)
 	router.GET("/create", w.createHandler)
 	router.POST("/preview", w.previewHandler)
+	router.POST("/draft", w.draftHandler)
 	router.POST("/save", w.saveHandler)
 	router.GET("/delete/:postId", w.deleteHandler)
 	router.GET("/rss", w.rssHandler)
+	if w.moderation != nil {
+		w.moderation.LoadInto(router)
+	}
 }
 
 func MakeBlogPage(blogName string, blogConfig config.BlogConfig) puzzleweb.Page {
 	blogService := blogConfig.Service
 	commentService := blogConfig.CommentService
+	pendingComments := blogConfig.PendingComments
 	markdownService := blogConfig.MarkdownService
-	host := blogConfig.Domain
-	if port := common.CheckPort(blogConfig.Port); port != ":80" {
-		host += port
-	}
-	dateFormat := blogConfig.DateFormat
 	defaultPageSize := blogConfig.PageSize
+	maxPageSize := blogConfig.MaxPageSize
 	extractSize := blogConfig.ExtractSize
 	feedFormat := blogConfig.FeedFormat
 	feedSize := blogConfig.FeedSize
+	defaultCommentSort := blogConfig.CommentSort
+	maxTitleLength := blogConfig.MaxTitleLength
+	maxContentLength := blogConfig.MaxContentLength
+	maxCommentLength := blogConfig.MaxCommentLength
+	formGuard := blogConfig.FormGuard
+	wordFilter := blogConfig.WordFilter
+	captchaService := blogConfig.CaptchaService
+	allowAnonymous := blogConfig.AllowAnonymousComments
+	throttle := newCommentThrottle(blogConfig.AnonymousCommentDelay)
+	notifier := commentNotifier{
+		mailer: blogConfig.Mailer, templateService: blogConfig.TemplateService, loggerGetter: blogConfig.LoggerGetter,
+	}
 
 	listTmpl := "blog/list"
 	viewTmpl := "blog/view"
 	createTmpl := "blog/create"
 	previewTmpl := "blog/preview"
+	printTmpl := "blog/print"
+	moderateTmpl := "blog/moderate"
 	switch args := blogConfig.Args; len(args) {
 	default:
-		blogConfig.Logger.Info("MakeBlogPage should be called with 0 to 4 optional arguments.")
+		blogConfig.Logger.Info("MakeBlogPage should be called with 0 to 6 optional arguments.")
+		fallthrough
+	case 6:
+		if args[5] != "" {
+			moderateTmpl = args[5]
+		}
+		fallthrough
+	case 5:
+		if args[4] != "" {
+			printTmpl = args[4]
+		}
 		fallthrough
 	case 4:
 		if args[3] != "" {
@@ -115,13 +150,15 @@ func MakeBlogPage(blogName string, blogConfig config.BlogConfig) puzzleweb.Page
 	case 0:
 	}
 
+	moderation := newModerationWidget(blogService, commentService, pendingComments, defaultPageSize, maxPageSize, moderateTmpl)
+
 	p := puzzleweb.MakePage(blogName)
 	p.Widget = blogWidget{
 		listHandler: puzzleweb.CreateTemplate(func(data gin.H, c *gin.Context) (string, string) {
 			logger := puzzleweb.GetLogger(c)
 			userId, _ := data[common.UserIdName].(uint64)
 
-			pageNumber, start, end, filter := common.GetPagination(defaultPageSize, c)
+			pageNumber, pageSize, start, end, filter := common.GetPagination(defaultPageSize, maxPageSize, c)
 
 			ctx := c.Request.Context()
 			total, posts, err := blogService.GetPosts(ctx, userId, start, end, filter)
@@ -130,8 +167,10 @@ func MakeBlogPage(blogName string, blogConfig config.BlogConfig) puzzleweb.Page
 			}
 
 			filterPostsExtract(posts, extractSize)
+			localizePostsDate(posts, c)
+			countPostsComments(ctx, commentService, userId, posts, logger)
 
-			common.InitPagination(data, filter, pageNumber, end, total)
+			common.InitPagination(data, filter, pageNumber, pageSize, total, c)
 			data["Posts"] = posts
 			data[common.AllowedToCreateName] = blogService.CreateRight(ctx, userId)
 			data[common.AllowedToDeleteName] = blogService.DeleteRight(ctx, userId)
@@ -142,31 +181,79 @@ func MakeBlogPage(blogName string, blogConfig config.BlogConfig) puzzleweb.Page
 			logger := puzzleweb.GetLogger(c)
 			userId, _ := data[common.UserIdName].(uint64)
 
-			pageNumber, start, end, _ := common.GetPagination(defaultPageSize, c)
+			pageNumber, pageSize, start, end, _ := common.GetPagination(defaultPageSize, maxPageSize, c)
 
 			postId, err := strconv.ParseUint(c.Param(postIdName), 10, 64)
 			if err != nil {
 				logger.Warn(parsingPostIdErrorMsg, zap.Error(err))
-				return "", common.DefaultErrorRedirect(logger, common.ErrorTechnicalKey)
+				return "", common.ErrorResponse(c, logger, common.ErrNotFound)
+			}
+
+			// the slug is an optional segment
+			baseLevel := uint8(2)
+			slug := c.Param(slugName)
+			if slug != "" {
+				baseLevel = 3
 			}
 
 			ctx := c.Request.Context()
 			post, err := blogService.GetPost(ctx, userId, postId)
+			if errors.Is(err, common.ErrNotFound) {
+				return "", common.ErrorResponse(c, logger, err)
+			}
 			if err != nil {
 				return "", common.DefaultErrorRedirect(logger, err.Error())
 			}
 
-			total, comments, err := commentService.GetCommentThread(ctx, userId, post.Title, start, end)
+			if slug != common.Slugify(post.Title) {
+				common.SetPermanentRedirect(c)
+				targetBuilder := postUrlBuilder(common.GetBaseUrl(baseLevel, c), postId, post.Title)
+				if rawQuery := c.Request.URL.RawQuery; rawQuery != "" {
+					targetBuilder.WriteByte('?')
+					targetBuilder.WriteString(rawQuery)
+				}
+				return "", targetBuilder.String()
+			}
+
+			post.Date = common.FormatDate(post.Created, c)
+			data[common.BaseUrlName] = common.GetBaseUrl(baseLevel, c)
+			data["Post"] = post
+			common.InitOpenGraph(data, post.Title, post.Content, extractSize, c)
+			common.InitBlogPostingJsonLd(data, post.Title, post.Content, post.Creator.Login, post.Created, extractSize, c)
+			if c.Query(viewName) == printView {
+				// minimal render, without comments nor forms
+				return printTmpl, ""
+			}
+
+			commentSort := c.Query(sortName)
+			if commentSort != config.CommentSortAsc && commentSort != config.CommentSortDesc {
+				commentSort = defaultCommentSort
+			}
+			data[commentSortName] = commentSort
+
+			total, comments, err := commentService.GetCommentThread(
+				ctx, userId, post.Title, start, end, commentSort == config.CommentSortDesc,
+			)
 			if err != nil {
 				return "", common.DefaultErrorRedirect(logger, err.Error())
 			}
 
-			common.InitPagination(data, "", pageNumber, end, total)
-			data[common.BaseUrlName] = common.GetBaseUrl(2, c)
-			data["Post"] = post
+			localizeCommentsDate(comments, c)
+
+			common.InitPagination(data, "", pageNumber, pageSize, total, c)
 			data["Comments"] = comments
+			data["CommentsTimeAgo"] = commentsTimeAgo(comments, c)
 			data[common.AllowedToCreateName] = commentService.CreateMessageRight(ctx, userId)
-			data[common.AllowedToDeleteName] = commentService.DeleteRight(ctx, userId)
+			formGuard.InitForm(data)
+			if userId == 0 {
+				puzzleweb.InitCaptcha(data, captchaService)
+				data[allowAnonymousName] = allowAnonymous
+			}
+			deleteRight := commentService.DeleteRight(ctx, userId)
+			data[common.AllowedToDeleteName] = deleteRight
+			if pendingComments != nil && deleteRight {
+				data["PendingComments"] = countPendingComments(ctx, pendingComments, userId, post.Title)
+			}
 			if len(comments) == 0 {
 				if err == nil {
 					data[commentMsgName] = "NoComment"
@@ -187,19 +274,75 @@ func MakeBlogPage(blogName string, blogConfig config.BlogConfig) puzzleweb.Page
 			}
 			comment := c.PostForm("comment")
 
+			// the view redirect to the slugged url
+			targetBuilder := postUrlBuilder(common.GetBaseUrl(3, c), postId, "")
+			if !formGuard.Check(c) {
+				// silently dropped, a bot should not know it has been detected
+				logger.Info("Comment rejected by form guard")
+				return targetBuilder.String()
+			}
+
+			// captcha only for anonymous user
+			var author forumservice.AnonymousAuthor
+			anonymous := userId == 0 && allowAnonymous
+			if userId == 0 {
+				if err = puzzleweb.CheckCaptcha(captchaService, c); err != nil {
+					common.WriteError(targetBuilder, logger, err.Error())
+					return targetBuilder.String()
+				}
+			}
+			if anonymous {
+				if author, err = readAnonymousAuthor(c, wordFilter); err != nil {
+					common.WriteError(targetBuilder, logger, err.Error())
+					return targetBuilder.String()
+				}
+				if !throttle.allow(common.ClientIP(c)) {
+					common.WriteError(targetBuilder, logger, common.ErrorCommentTooSoonKey)
+					return targetBuilder.String()
+				}
+			}
+
+			if err = puzzleweb.CheckVerifiedEmail(c, puzzleweb.VerifiedComment); err != nil {
+				common.WriteError(targetBuilder, logger, err.Error())
+				return targetBuilder.String()
+			}
+
 			err = errEmptyComment
 			ctx := c.Request.Context()
 			if comment != "" {
-				var post blogservice.BlogPost
-				post, err = blogService.GetPost(ctx, userId, postId)
-				if err != nil {
-					return common.DefaultErrorRedirect(logger, err.Error())
+				err = common.ErrCommentTooLong
+				if !common.TooLong(comment, maxCommentLength) {
+					comment, err = wordFilter.Check(comment)
+					if err != nil {
+						common.WriteError(targetBuilder, logger, err.Error())
+						return targetBuilder.String()
+					}
+
+					var post blogservice.BlogPost
+					post, err = blogService.GetPost(ctx, userId, postId)
+					if err != nil {
+						return common.DefaultErrorRedirect(logger, err.Error())
+					}
+
+					createComment := func(service forumservice.CommentService) error {
+						if anonymous {
+							return service.CreateAnonymousComment(ctx, post.Title, author, comment)
+						}
+						return service.CreateComment(ctx, userId, post.Title, comment)
+					}
+					if pendingComments == nil || commentService.DeleteRight(ctx, userId) {
+						err = createComment(commentService)
+						if err == nil {
+							postUrl := postUrlBuilder(common.GetAbsoluteBaseUrl(3, c), postId, post.Title).String()
+							notifier.notify(c, post, postUrl, comment)
+						}
+					} else {
+						// invisible until a moderator approve it
+						err = createComment(pendingComments)
+					}
 				}
-
-				err = commentService.CreateComment(ctx, userId, post.Title, comment)
 			}
 
-			targetBuilder := postUrlBuilder(common.GetBaseUrl(3, c), postId)
 			if err != nil {
 				common.WriteError(targetBuilder, logger, err.Error())
 			}
@@ -227,7 +370,7 @@ func MakeBlogPage(blogName string, blogConfig config.BlogConfig) puzzleweb.Page
 			}
 
 			err = commentService.DeleteComment(ctx, userId, post.Title, commentId)
-			targetBuilder := postUrlBuilder(common.GetBaseUrl(4, c), postId)
+			targetBuilder := postUrlBuilder(common.GetBaseUrl(4, c), postId, post.Title)
 			if err != nil {
 				common.WriteError(targetBuilder, logger, err.Error())
 			}
@@ -235,6 +378,10 @@ func MakeBlogPage(blogName string, blogConfig config.BlogConfig) puzzleweb.Page
 		}),
 		createHandler: puzzleweb.CreateTemplate(func(data gin.H, c *gin.Context) (string, string) {
 			data[common.BaseUrlName] = common.GetBaseUrl(1, c)
+			if draft := puzzleweb.LoadDraft(c, blogName); draft != nil {
+				data["DraftTitle"] = draft.Get("title")
+				data["DraftMarkdown"] = draft.Get("markdown")
+			}
 			return createTmpl, ""
 		}),
 		previewHandler: puzzleweb.CreateTemplate(func(data gin.H, c *gin.Context) (string, string) {
@@ -248,6 +395,9 @@ func MakeBlogPage(blogName string, blogConfig config.BlogConfig) puzzleweb.Page
 			if markdown == "" {
 				return "", common.DefaultErrorRedirect(logger, emptyContent)
 			}
+			if errorKey := checkPostLength(title, markdown, maxTitleLength, maxContentLength); errorKey != "" {
+				return "", common.DefaultErrorRedirect(logger, errorKey)
+			}
 
 			ctx := c.Request.Context()
 			html, err := markdownService.Apply(ctx, markdown)
@@ -261,6 +411,17 @@ func MakeBlogPage(blogName string, blogConfig config.BlogConfig) puzzleweb.Page
 			data["PreviewHTML"] = html
 			return previewTmpl, ""
 		}),
+		// called in background by the create page, so there is no redirect
+		draftHandler: func(c *gin.Context) {
+			err := puzzleweb.SaveDraft(c, blogName, url.Values{
+				"title": {c.PostForm("title")}, "markdown": {c.PostForm("markdown")},
+			})
+			if err != nil {
+				puzzleweb.WriteAppError(c, err)
+				return
+			}
+			c.Status(http.StatusNoContent)
+		},
 		saveHandler: common.CreateRedirect(func(c *gin.Context) string {
 			logger := puzzleweb.GetLogger(c)
 			title := c.PostForm("title")
@@ -273,6 +434,20 @@ func MakeBlogPage(blogName string, blogConfig config.BlogConfig) puzzleweb.Page
 			if markdown == "" {
 				return common.DefaultErrorRedirect(logger, emptyContent)
 			}
+			if errorKey := checkPostLength(title, markdown, maxTitleLength, maxContentLength); errorKey != "" {
+				return common.DefaultErrorRedirect(logger, errorKey)
+			}
+			if err := puzzleweb.CheckVerifiedEmail(c, puzzleweb.VerifiedPost); err != nil {
+				return common.DefaultErrorRedirect(logger, err.Error())
+			}
+
+			title, err := wordFilter.Check(title)
+			if err == nil {
+				markdown, err = wordFilter.Check(markdown)
+			}
+			if err != nil {
+				return common.DefaultErrorRedirect(logger, err.Error())
+			}
 
 			ctx := c.Request.Context()
 			html, err := markdownService.Apply(ctx, markdown)
@@ -284,12 +459,18 @@ func MakeBlogPage(blogName string, blogConfig config.BlogConfig) puzzleweb.Page
 			if err != nil {
 				return common.DefaultErrorRedirect(logger, err.Error())
 			}
+			puzzleweb.ClearDraft(c, blogName)
 
 			err = commentService.CreateCommentThread(ctx, userId, title)
 			if err != nil {
 				return common.DefaultErrorRedirect(logger, err.Error())
 			}
-			return postUrlBuilder(common.GetBaseUrl(1, c), postId).String()
+			if pendingComments != nil {
+				if err = pendingComments.CreateCommentThread(ctx, userId, title); err != nil {
+					return common.DefaultErrorRedirect(logger, err.Error())
+				}
+			}
+			return postUrlBuilder(common.GetBaseUrl(1, c), postId, title).String()
 		}),
 		deleteHandler: common.CreateRedirect(func(c *gin.Context) string {
 			logger := puzzleweb.GetLogger(c)
@@ -318,6 +499,13 @@ func MakeBlogPage(blogName string, blogConfig config.BlogConfig) puzzleweb.Page
 
 			if err = commentService.DeleteCommentThread(ctx, userId, post.Title); err != nil {
 				common.WriteError(&targetBuilder, logger, err.Error())
+				return targetBuilder.String()
+			}
+
+			if pendingComments != nil {
+				if err = pendingComments.DeleteCommentThread(ctx, userId, post.Title); err != nil {
+					common.WriteError(&targetBuilder, logger, err.Error())
+				}
 			}
 			return targetBuilder.String()
 		}),
@@ -328,16 +516,16 @@ func MakeBlogPage(blogName string, blogConfig config.BlogConfig) puzzleweb.Page
 			_, posts, err := blogService.GetPosts(c.Request.Context(), userId, 0, feedSize, "")
 			if err != nil {
 				status := http.StatusInternalServerError
-				if err == common.ErrNotAuthorized {
+				if errors.Is(err, common.ErrNotAuthorized) {
 					status = http.StatusForbidden
 				}
 				c.AbortWithStatus(status)
 				return
 			}
 
-			baseUrl := host + common.GetBaseUrl(1, c)
+			baseUrl := common.GetAbsoluteBaseUrl(1, c)
 			// TODO improve blog title ?
-			data, err := buildFeed(posts, blogName, baseUrl, dateFormat, extractSize, feedFormat)
+			data, err := buildFeed(posts, blogName, baseUrl, extractSize, feedFormat, common.GetTimeZone(c))
 			if err != nil {
 				common.LogOriginalError(logger, err)
 				c.AbortWithStatus(http.StatusInternalServerError)
@@ -345,57 +533,95 @@ func MakeBlogPage(blogName string, blogConfig config.BlogConfig) puzzleweb.Page
 			}
 			c.Data(http.StatusOK, http.DetectContentType(data), data)
 		},
+		moderation: moderation,
 	}
 	return p
 }
 
-func postUrlBuilder(base string, postId uint64) *strings.Builder {
+// an empty title give an url without slug
+func postUrlBuilder(base string, postId uint64, title string) *strings.Builder {
 	targetBuilder := new(strings.Builder)
 	targetBuilder.WriteString(base)
 	targetBuilder.WriteString("view/")
 	targetBuilder.WriteString(strconv.FormatUint(postId, 10))
+	// no slug segment when the title has nothing to keep
+	if slug := common.Slugify(title); slug != "" {
+		targetBuilder.WriteByte('/')
+		targetBuilder.WriteString(slug)
+	}
 	return targetBuilder
 }
 
+func checkPostLength(title string, markdown string, maxTitleLength uint64, maxContentLength uint64) string {
+	if common.TooLong(title, maxTitleLength) {
+		return common.ErrorTitleTooLongKey
+	}
+	if common.TooLong(markdown, maxContentLength) {
+		return common.ErrorContentTooLongKey
+	}
+	return ""
+}
+
 func filterPostsExtract(posts []blogservice.BlogPost, extractSize uint64) {
 	for index := range posts {
 		posts[index].Content = common.FilterExtractHtml(string(posts[index].Content), extractSize)
 	}
 }
 
-func buildFeed(posts []blogservice.BlogPost, blogTitle string, baseUrl string, dateFormat string, extractSize uint64, feedFormat string) ([]byte, error) {
-	feedData := feeds.Feed{
-		Title:   blogTitle,
-		Link:    &feeds.Link{Href: baseUrl},
-		Created: time.Now(),
+func localizePostsDate(posts []blogservice.BlogPost, c *gin.Context) {
+	for index := range posts {
+		posts[index].Date = common.FormatDate(posts[index].Created, c)
+	}
+}
+
+// on error, the counts are left unknown instead of failing the list
+func countPostsComments(ctx context.Context, commentService forumservice.CommentService, userId uint64, posts []blogservice.BlogPost, logger log.Logger) {
+	if len(posts) == 0 {
+		return
 	}
 
+	titles := make([]string, 0, len(posts))
 	for _, post := range posts {
-		date, err := time.Parse(dateFormat, post.Date)
-		if err != nil {
-			return nil, err
-		}
+		titles = append(titles, post.Title)
+	}
+
+	counts, err := commentService.CountComments(ctx, userId, titles)
+	if err != nil {
+		logger.Warn("Failed to count comments", zap.Error(err))
+		return
+	}
+	for index := range posts {
+		count := counts[posts[index].Title]
+		posts[index].CommentCount = &count
+	}
+}
 
-		feedData.Items = append(feedData.Items, &feeds.Item{
+func localizeCommentsDate(comments []forumservice.ForumContent, c *gin.Context) {
+	for index := range comments {
+		comments[index].Date = common.FormatDate(comments[index].Created, c)
+	}
+}
+
+// same order as the comments
+func commentsTimeAgo(comments []forumservice.ForumContent, c *gin.Context) []common.RelativeTime {
+	res := make([]common.RelativeTime, 0, len(comments))
+	for _, comment := range comments {
+		res = append(res, common.TimeAgo(comment.Created, c))
+	}
+	return res
+}
+
+// dates stay in the standard format of the feed (not localized)
+func buildFeed(posts []blogservice.BlogPost, blogTitle string, baseUrl string, extractSize uint64, feedFormat string, location *time.Location) ([]byte, error) {
+	items := make([]common.FeedItem, 0, len(posts))
+	for _, post := range posts {
+		items = append(items, common.FeedItem{
 			Title:       post.Title,
-			Link:        &feeds.Link{Href: postUrlBuilder(baseUrl, post.PostId).String()},
+			Link:        postUrlBuilder(baseUrl, post.PostId, post.Title).String(),
 			Description: common.FilterExtractHtml(string(post.Content), extractSize),
-			Author:      &feeds.Author{Name: post.Creator.Login},
-			Created:     date,
+			Author:      post.Creator.Login,
+			Created:     post.Created,
 		})
 	}
-
-	data := ""
-	var err error
-	switch feedFormat {
-	case "atom":
-		data, err = feedData.ToAtom()
-	case "json":
-		data, err = feedData.ToJSON()
-	case "rss":
-		data, err = feedData.ToRss()
-	default:
-		return nil, errFeedFormat
-	}
-	return []byte(data), err
+	return common.BuildFeed(blogTitle, baseUrl, items, feedFormat, location)
 }
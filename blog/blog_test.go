@@ -0,0 +1,61 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package blog
+
+import (
+	"testing"
+
+	"github.com/dvaumoron/puzzleweb/common"
+)
+
+func TestCheckPostLength(t *testing.T) {
+	tests := []struct {
+		title, markdown string
+		want            string
+	}{
+		{"title", "content", ""},
+		{"title!", "content", common.ErrorTitleTooLongKey},
+		{"title", "content!!", common.ErrorContentTooLongKey},
+		{"title!", "content!!", common.ErrorTitleTooLongKey},
+	}
+	for _, tt := range tests {
+		if got := checkPostLength(tt.title, tt.markdown, 5, 8); got != tt.want {
+			t.Errorf("checkPostLength(%q, %q) = %q, want %q", tt.title, tt.markdown, got, tt.want)
+		}
+	}
+	if got := checkPostLength("a long title", "a long content", 0, 0); got != "" {
+		t.Errorf("zero limits should accept everything, got %q", got)
+	}
+}
+
+func TestPostUrlBuilder(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"Hello World", "/blog/view/3/hello-world"},
+		{"日本語", "/blog/view/3"},
+		{"", "/blog/view/3"},
+	}
+	for _, tt := range tests {
+		if got := postUrlBuilder("/blog/", 3, tt.title).String(); got != tt.want {
+			t.Errorf("postUrlBuilder(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
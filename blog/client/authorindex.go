@@ -0,0 +1,109 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package client
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	pb "github.com/dvaumoron/puzzleblogservice"
+	"github.com/dvaumoron/puzzleweb/common/grpcretry"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	scanBatchSize  = 100
+	authorIndexTTL = time.Minute
+)
+
+// the service can not filter by author, so all the posts are scanned by batch,
+// the result is kept for authorIndexTTL (or until this client change a post)
+type authorIndex struct {
+	mutex      sync.RWMutex
+	posts      map[uint64][]*pb.Content // by author, sorted by date desc
+	expiration time.Time
+	generation uint64
+	refresh    singleflight.Group
+}
+
+// the returned slice is shared and must not be modified
+func (index *authorIndex) get(ctx context.Context, blogClient pb.BlogClient, blogId uint64, authorId uint64) ([]*pb.Content, error) {
+	index.mutex.RLock()
+	posts, valid, generation := index.posts, time.Now().Before(index.expiration), index.generation
+	index.mutex.RUnlock()
+	if valid {
+		return posts[authorId], nil
+	}
+
+	// the service is scanned once for all the concurrent callers, without holding the lock
+	res, err, _ := index.refresh.Do("", func() (any, error) {
+		posts, err := scanPosts(ctx, blogClient, blogId)
+		if err != nil {
+			return nil, err
+		}
+
+		index.mutex.Lock()
+		defer index.mutex.Unlock()
+		// a post changed during the scan, the result could be stale
+		if index.generation == generation {
+			index.posts = posts
+			index.expiration = time.Now().Add(authorIndexTTL)
+		}
+		return posts, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res.(map[uint64][]*pb.Content)[authorId], nil
+}
+
+func (index *authorIndex) invalidate() {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	index.posts = nil
+	index.expiration = time.Time{}
+	index.generation++
+}
+
+func scanPosts(ctx context.Context, blogClient pb.BlogClient, blogId uint64) (map[uint64][]*pb.Content, error) {
+	posts := map[uint64][]*pb.Content{}
+	for batchStart := uint64(0); ; batchStart += scanBatchSize {
+		response, err := blogClient.GetPosts(ctx, &pb.SearchRequest{
+			BlogId: blogId, Start: batchStart, End: batchStart + scanBatchSize,
+		}, grpcretry.WithRetry())
+		if err != nil {
+			return nil, err
+		}
+
+		for _, content := range response.List {
+			posts[content.UserId] = append(posts[content.UserId], content)
+		}
+		if len(response.List) == 0 || batchStart+scanBatchSize >= response.Total {
+			break
+		}
+	}
+
+	for _, authorList := range posts {
+		slices.SortFunc(authorList, cmpDesc)
+	}
+	return posts, nil
+}
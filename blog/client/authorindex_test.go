@@ -0,0 +1,93 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package client
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	pb "github.com/dvaumoron/puzzleblogservice"
+	"google.golang.org/grpc"
+)
+
+// serve the posts by batch like the blog service and count the calls
+type fakeBlogClient struct {
+	pb.BlogClient
+	posts []*pb.Content
+	calls atomic.Int32
+}
+
+func (client *fakeBlogClient) GetPosts(ctx context.Context, in *pb.SearchRequest, opts ...grpc.CallOption) (*pb.Contents, error) {
+	client.calls.Add(1)
+	total := uint64(len(client.posts))
+	return &pb.Contents{Total: total, List: client.posts[min(in.Start, total):min(in.End, total)]}, nil
+}
+
+func makeFakePosts(size int) []*pb.Content {
+	posts := make([]*pb.Content, 0, size)
+	for i := 0; i < size; i++ {
+		posts = append(posts, &pb.Content{PostId: uint64(i), UserId: uint64(i % 3), CreatedAt: int64(i)})
+	}
+	return posts
+}
+
+func TestAuthorIndex(t *testing.T) {
+	blogClient := &fakeBlogClient{posts: makeFakePosts(250)}
+	index := &authorIndex{}
+	ctx := context.Background()
+
+	posts, err := index.get(ctx, blogClient, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 83 {
+		t.Fatalf("got %d posts, want 83", len(posts))
+	}
+	for i, post := range posts {
+		if post.UserId != 2 {
+			t.Fatalf("post %d has author %d, want 2", post.PostId, post.UserId)
+		}
+		if i != 0 && posts[i-1].CreatedAt < post.CreatedAt {
+			t.Fatal("posts are not sorted by date desc")
+		}
+	}
+	// 250 posts by batch of 100
+	if calls := blogClient.calls.Load(); calls != 3 {
+		t.Fatalf("got %d calls for the scan, want 3", calls)
+	}
+
+	// the other authors and the next pages come from the index
+	for authorId := uint64(0); authorId < 3; authorId++ {
+		if _, err = index.get(ctx, blogClient, 1, authorId); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls := blogClient.calls.Load(); calls != 3 {
+		t.Errorf("got %d calls, want 3 (no new scan)", calls)
+	}
+
+	index.invalidate()
+	if _, err = index.get(ctx, blogClient, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if calls := blogClient.calls.Load(); calls != 6 {
+		t.Errorf("got %d calls, want 6 (one new scan)", calls)
+	}
+}
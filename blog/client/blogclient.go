@@ -29,8 +29,11 @@ import (
 	adminservice "github.com/dvaumoron/puzzleweb/admin/service"
 	blogservice "github.com/dvaumoron/puzzleweb/blog/service"
 	"github.com/dvaumoron/puzzleweb/common"
+	"github.com/dvaumoron/puzzleweb/common/grpcretry"
 	profileservice "github.com/dvaumoron/puzzleweb/profile/service"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type blogClient struct {
@@ -40,12 +43,13 @@ type blogClient struct {
 	dateFormat     string
 	authService    adminservice.AuthService
 	profileService profileservice.ProfileService
+	authorIndex    *authorIndex
 }
 
 func New(serviceAddr string, dialOptions []grpc.DialOption, blogId uint64, groupId uint64, dateFormat string, authService adminservice.AuthService, profileService profileservice.ProfileService) blogservice.BlogService {
 	return blogClient{
 		Client: grpcclient.Make(serviceAddr, dialOptions...), blogId: blogId, groupId: groupId,
-		dateFormat: dateFormat, authService: authService, profileService: profileService,
+		dateFormat: dateFormat, authService: authService, profileService: profileService, authorIndex: &authorIndex{},
 	}
 }
 
@@ -74,6 +78,7 @@ func (client blogClient) CreatePost(ctx context.Context, userId uint64, title st
 	if !response.Success {
 		return 0, common.ErrUpdate
 	}
+	client.authorIndex.invalidate()
 	return response.Id, nil
 }
 
@@ -91,7 +96,10 @@ func (client blogClient) GetPost(ctx context.Context, userId uint64, postId uint
 
 	response, err := pb.NewBlogClient(conn).GetPost(ctx, &pb.IdRequest{
 		BlogId: client.blogId, PostId: postId,
-	})
+	}, grpcretry.WithRetry())
+	if status.Code(err) == codes.NotFound {
+		return blogservice.BlogPost{}, common.ErrNotFound
+	}
 	if err != nil {
 		return blogservice.BlogPost{}, err
 	}
@@ -118,7 +126,7 @@ func (client blogClient) GetPosts(ctx context.Context, userId uint64, start uint
 
 	response, err := pb.NewBlogClient(conn).GetPosts(ctx, &pb.SearchRequest{
 		BlogId: client.blogId, Start: start, End: end, Filter: filter,
-	})
+	}, grpcretry.WithRetry())
 	if err != nil {
 		return 0, nil, err
 	}
@@ -136,6 +144,71 @@ func (client blogClient) GetPosts(ctx context.Context, userId uint64, start uint
 	return total, posts, nil
 }
 
+func (client blogClient) GetPostsByAuthor(ctx context.Context, userId uint64, authorId uint64, start uint64, end uint64) (uint64, []blogservice.BlogPost, error) {
+	err := client.authService.AuthQuery(ctx, userId, client.groupId, adminservice.ActionAccess)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	conn, err := client.Dial()
+	if err != nil {
+		return 0, nil, err
+	}
+	defer conn.Close()
+
+	authorList, err := client.authorIndex.get(ctx, pb.NewBlogClient(conn), client.blogId, authorId)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	total := uint64(len(authorList))
+	if start >= total {
+		return total, nil, nil
+	}
+
+	// the index is shared, sortConvertPosts works on a copy
+	posts, err := client.sortConvertPosts(ctx, slices.Clone(authorList[start:min(end, total)]))
+	if err != nil {
+		return 0, nil, err
+	}
+	return total, posts, nil
+}
+
+// an author can always delete its own posts
+func (client blogClient) DeletePostsByAuthor(ctx context.Context, userId uint64, authorId uint64) error {
+	if userId != authorId {
+		err := client.authService.AuthQuery(ctx, userId, client.groupId, adminservice.ActionDelete)
+		if err != nil {
+			return err
+		}
+	}
+
+	conn, err := client.Dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// a fresh scan, the index could miss a recent post
+	blogClient := pb.NewBlogClient(conn)
+	posts, err := scanPosts(ctx, blogClient, client.blogId)
+	if err != nil {
+		return err
+	}
+
+	defer client.authorIndex.invalidate()
+	for _, content := range posts[authorId] {
+		response, err := blogClient.DeletePost(ctx, &pb.IdRequest{BlogId: client.blogId, PostId: content.PostId})
+		if err != nil {
+			return err
+		}
+		if !response.Success {
+			return common.ErrUpdate
+		}
+	}
+	return nil
+}
+
 func (client blogClient) DeletePost(ctx context.Context, userId uint64, postId uint64) error {
 	err := client.authService.AuthQuery(ctx, userId, client.groupId, adminservice.ActionDelete)
 	if err != nil {
@@ -157,6 +230,7 @@ func (client blogClient) DeletePost(ctx context.Context, userId uint64, postId u
 	if !response.Success {
 		return common.ErrUpdate
 	}
+	client.authorIndex.invalidate()
 	return nil
 }
 
@@ -193,6 +267,7 @@ func (client blogClient) sortConvertPosts(ctx context.Context, list []*pb.Conten
 func convertPost(post *pb.Content, creator profileservice.UserProfile, dateFormat string) blogservice.BlogPost {
 	createdAt := time.Unix(post.CreatedAt, 0)
 	return blogservice.BlogPost{
-		PostId: post.PostId, Creator: creator, Date: createdAt.Format(dateFormat), Title: post.Title, Content: post.Text,
+		PostId: post.PostId, Creator: creator, Date: createdAt.Format(dateFormat), Created: createdAt, Title: post.Title,
+		Content: post.Text,
 	}
 }
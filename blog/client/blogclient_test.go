@@ -0,0 +1,63 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package client
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/dvaumoron/puzzleblogservice"
+	loginservice "github.com/dvaumoron/puzzleweb/login/service"
+	profileservice "github.com/dvaumoron/puzzleweb/profile/service"
+)
+
+// record each GetProfiles call
+type fakeProfileService struct {
+	calls [][]uint64
+}
+
+func (service *fakeProfileService) GetProfiles(ctx context.Context, userIds []uint64) (map[uint64]profileservice.UserProfile, error) {
+	service.calls = append(service.calls, userIds)
+	profiles := map[uint64]profileservice.UserProfile{}
+	for _, userId := range userIds {
+		profiles[userId] = profileservice.UserProfile{User: loginservice.User{Id: userId}}
+	}
+	return profiles, nil
+}
+
+func TestSortConvertPostsSingleLookup(t *testing.T) {
+	profileService := &fakeProfileService{}
+	client := blogClient{profileService: profileService}
+	list := []*pb.Content{
+		{PostId: 1, UserId: 7, CreatedAt: 1}, {PostId: 2, UserId: 8, CreatedAt: 3}, {PostId: 3, UserId: 7, CreatedAt: 2},
+	}
+
+	posts, err := client.sortConvertPosts(context.Background(), list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(profileService.calls) != 1 {
+		t.Fatalf("got %d GetProfiles calls, want 1", len(profileService.calls))
+	}
+	for index, wantId := range []uint64{2, 3, 1} {
+		if post := posts[index]; post.PostId != wantId || post.Creator.Id != list[index].UserId {
+			t.Errorf("post %d : got %d by %d", index, post.PostId, post.Creator.Id)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package blog
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	blogservice "github.com/dvaumoron/puzzleweb/blog/service"
+	"github.com/dvaumoron/puzzleweb/common"
+	puzzleweb "github.com/dvaumoron/puzzleweb/core"
+	forumservice "github.com/dvaumoron/puzzleweb/forum/service"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const pendingScanSize = 100
+
+var errPendingCommentNotFound = errors.New("pending comment not found")
+
+// pending comments are kept in a separate comment container and copied to the visible one on approval
+type moderationWidget struct {
+	moderateHandler gin.HandlerFunc
+	approveHandler  gin.HandlerFunc
+	rejectHandler   gin.HandlerFunc
+}
+
+func (w *moderationWidget) LoadInto(router gin.IRouter) {
+	router.GET("/comment/moderate/:postId", w.moderateHandler)
+	router.GET("/comment/approve/:postId/:commentId", w.approveHandler)
+	router.GET("/comment/reject/:postId/:commentId", w.rejectHandler)
+}
+
+// return nil when moderation is disabled
+func newModerationWidget(blogService blogservice.BlogService, commentService forumservice.CommentService, pendingComments forumservice.CommentService, defaultPageSize uint64, maxPageSize uint64, moderateTmpl string) *moderationWidget {
+	if pendingComments == nil {
+		return nil
+	}
+
+	return &moderationWidget{
+		moderateHandler: puzzleweb.CreateTemplate(func(data gin.H, c *gin.Context) (string, string) {
+			logger := puzzleweb.GetLogger(c)
+			userId, _ := data[common.UserIdName].(uint64)
+
+			pageNumber, pageSize, start, end, _ := common.GetPagination(defaultPageSize, maxPageSize, c)
+
+			postId, err := strconv.ParseUint(c.Param(postIdName), 10, 64)
+			if err != nil {
+				logger.Warn(parsingPostIdErrorMsg, zap.Error(err))
+				return "", common.DefaultErrorRedirect(logger, common.ErrorTechnicalKey)
+			}
+
+			ctx := c.Request.Context()
+			if !commentService.DeleteRight(ctx, userId) {
+				return "", common.DefaultErrorRedirect(logger, common.ErrorNotAuthorizedKey)
+			}
+
+			post, err := blogService.GetPost(ctx, userId, postId)
+			if err != nil {
+				return "", common.DefaultErrorRedirect(logger, err.Error())
+			}
+
+			total, comments, err := pendingComments.GetCommentThread(ctx, userId, post.Title, start, end, false)
+			localizeCommentsDate(comments, c)
+
+			common.InitPagination(data, "", pageNumber, pageSize, total, c)
+			data[common.BaseUrlName] = common.GetBaseUrl(3, c)
+			data["Post"] = post
+			data["Comments"] = comments
+			if len(comments) == 0 {
+				if err == nil {
+					data[commentMsgName] = "NoPendingComment"
+				} else {
+					data[commentMsgName] = "CommentDisplayError"
+				}
+			}
+			return moderateTmpl, ""
+		}),
+		approveHandler: common.CreateRedirect(func(c *gin.Context) string {
+			return moderateComment(c, blogService, commentService, pendingComments, approveComment)
+		}),
+		rejectHandler: common.CreateRedirect(func(c *gin.Context) string {
+			return moderateComment(c, blogService, commentService, pendingComments, rejectComment)
+		}),
+	}
+}
+
+type moderationAction func(ctx context.Context, commentService forumservice.CommentService, pendingComments forumservice.CommentService, userId uint64, elemTitle string, commentId uint64) error
+
+func moderateComment(c *gin.Context, blogService blogservice.BlogService, commentService forumservice.CommentService, pendingComments forumservice.CommentService, action moderationAction) string {
+	logger := puzzleweb.GetLogger(c)
+	userId := puzzleweb.GetSessionUserId(c)
+
+	postId, err := strconv.ParseUint(c.Param(postIdName), 10, 64)
+	if err != nil {
+		logger.Warn(parsingPostIdErrorMsg, zap.Error(err))
+		return common.DefaultErrorRedirect(logger, common.ErrorTechnicalKey)
+	}
+	commentId, err := strconv.ParseUint(c.Param("commentId"), 10, 64)
+	if err != nil {
+		logger.Warn("Failed to parse commentId", zap.Error(err))
+		return common.DefaultErrorRedirect(logger, common.ErrorTechnicalKey)
+	}
+
+	ctx := c.Request.Context()
+	if !commentService.DeleteRight(ctx, userId) {
+		return common.DefaultErrorRedirect(logger, common.ErrorNotAuthorizedKey)
+	}
+
+	post, err := blogService.GetPost(ctx, userId, postId)
+	if err != nil {
+		return common.DefaultErrorRedirect(logger, err.Error())
+	}
+
+	targetBuilder := moderateUrlBuilder(common.GetBaseUrl(4, c), postId)
+	if err = action(ctx, commentService, pendingComments, userId, post.Title, commentId); err != nil {
+		common.WriteError(targetBuilder, logger, err.Error())
+	}
+	return targetBuilder.String()
+}
+
+// the comment keep its author but take the approval date
+func approveComment(ctx context.Context, commentService forumservice.CommentService, pendingComments forumservice.CommentService, userId uint64, elemTitle string, commentId uint64) error {
+	comment, err := findPendingComment(ctx, pendingComments, userId, elemTitle, commentId)
+	if err != nil {
+		return err
+	}
+
+	if creatorId := comment.Creator.Id; creatorId == 0 {
+		err = commentService.CreateAnonymousComment(ctx, elemTitle, comment.Anonymous, comment.Text)
+	} else {
+		err = commentService.CreateComment(ctx, creatorId, elemTitle, comment.Text)
+	}
+	if err != nil {
+		return err
+	}
+	return pendingComments.DeleteComment(ctx, userId, elemTitle, commentId)
+}
+
+func rejectComment(ctx context.Context, commentService forumservice.CommentService, pendingComments forumservice.CommentService, userId uint64, elemTitle string, commentId uint64) error {
+	return pendingComments.DeleteComment(ctx, userId, elemTitle, commentId)
+}
+
+func findPendingComment(ctx context.Context, pendingComments forumservice.CommentService, userId uint64, elemTitle string, commentId uint64) (forumservice.ForumContent, error) {
+	for start := uint64(0); ; start += pendingScanSize {
+		total, comments, err := pendingComments.GetCommentThread(ctx, userId, elemTitle, start, start+pendingScanSize, false)
+		if err != nil {
+			return forumservice.ForumContent{}, err
+		}
+
+		for _, comment := range comments {
+			if comment.Id == commentId {
+				return comment, nil
+			}
+		}
+		if len(comments) == 0 || start+pendingScanSize >= total {
+			return forumservice.ForumContent{}, errPendingCommentNotFound
+		}
+	}
+}
+
+func moderateUrlBuilder(base string, postId uint64) *strings.Builder {
+	targetBuilder := new(strings.Builder)
+	targetBuilder.WriteString(base)
+	targetBuilder.WriteString("comment/moderate/")
+	targetBuilder.WriteString(strconv.FormatUint(postId, 10))
+	return targetBuilder
+}
+
+// the pending count is only given to moderators, failure are treated as no pending comment
+func countPendingComments(ctx context.Context, pendingComments forumservice.CommentService, userId uint64, elemTitle string) uint64 {
+	total, _, err := pendingComments.GetCommentThread(ctx, userId, elemTitle, 0, 1, false)
+	if err != nil {
+		return 0
+	}
+	return total
+}
@@ -0,0 +1,92 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package blog
+
+import (
+	"context"
+	"time"
+
+	blogservice "github.com/dvaumoron/puzzleweb/blog/service"
+	"github.com/dvaumoron/puzzleweb/common"
+	"github.com/dvaumoron/puzzleweb/common/log"
+	puzzleweb "github.com/dvaumoron/puzzleweb/core"
+	"github.com/dvaumoron/puzzleweb/mail"
+	mailservice "github.com/dvaumoron/puzzleweb/mail/service"
+	profileservice "github.com/dvaumoron/puzzleweb/profile/service"
+	templateservice "github.com/dvaumoron/puzzleweb/templates/service"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	commentMailTmpl = "mail/comment"
+	settingsUrl     = "/settings"
+	notifyTimeOut   = time.Minute
+)
+
+// mail the author of a post when it is commented, the mail is written by the template service in the author language
+type commentNotifier struct {
+	mailer          mailservice.Mailer // nil when disabled
+	templateService templateservice.TemplateService
+	loggerGetter    log.LoggerGetter
+}
+
+func (n commentNotifier) notify(c *gin.Context, post blogservice.BlogPost, postUrl string, comment string) {
+	author := post.Creator
+	email := author.Info[profileservice.EmailInfoName]
+	commenterId := puzzleweb.GetSessionUserId(c)
+	if n.mailer == nil || email == "" || author.Id == commenterId {
+		return
+	}
+
+	settingsManager := puzzleweb.GetSettingsManager(c)
+	defaultLang := puzzleweb.GetLocalesManager(c).GetDefaultLang()
+	data := gin.H{
+		"PostTitle": post.Title, "PostUrl": postUrl, "Comment": comment,
+		"Commenter": puzzleweb.GetSessionUserLogin(c),
+		// the settings page let the author unsubscribe
+		"SettingsUrl": common.GetAbsolutePathUrl(settingsUrl, c),
+	}
+
+	// keep the trace but not the cancellation of the request
+	ctx := context.WithoutCancel(c.Request.Context())
+	go func() {
+		ctx, cancel := context.WithTimeout(ctx, notifyTimeOut)
+		defer cancel()
+
+		logger := n.loggerGetter.Logger(ctx)
+		lang, accepted := settingsManager.GetMailNotification(ctx, author.Id)
+		if !accepted {
+			return
+		}
+		if lang == "" {
+			lang = defaultLang
+		}
+
+		message, err := mail.RenderMessage(ctx, n.templateService, commentMailTmpl, email, lang, data)
+		if err != nil {
+			logger.Error("Failed to render comment mail", zap.Error(err))
+			return
+		}
+
+		if err = n.mailer.Send(ctx, message); err != nil {
+			logger.Error("Failed to send comment mail", zap.Uint64("authorId", author.Id), zap.Error(err))
+		}
+	}()
+}
@@ -0,0 +1,143 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package blog
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	blogservice "github.com/dvaumoron/puzzleweb/blog/service"
+	"github.com/dvaumoron/puzzleweb/common"
+	"github.com/dvaumoron/puzzleweb/common/config"
+	"github.com/dvaumoron/puzzleweb/common/log"
+	puzzleweb "github.com/dvaumoron/puzzleweb/core"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	recentPostsName    = "RecentPosts"
+	recentPostsTimeOut = 10 * time.Second
+)
+
+type RecentPost struct {
+	Title string
+	Url   string
+	Date  string
+}
+
+// a failure is also kept for the ttl, the stale posts are served when there is some
+type recentPostsCache struct {
+	blogService  blogservice.BlogService
+	loggerGetter log.LoggerGetter
+	blogUrl      string
+	size         uint64
+	ttl          time.Duration
+	mutex        sync.RWMutex
+	posts        []blogservice.BlogPost
+	err          error
+	expiration   time.Time
+	refresh      singleflight.Group
+}
+
+// return nil when recent posts are disabled, blogUrl is the path of the blog page,
+// the adder can be used on any page
+func NewRecentPostsAdder(blogConfig config.BlogConfig, blogUrl string) common.DataAdder {
+	if blogConfig.RecentPosts == 0 {
+		return nil
+	}
+
+	cache := &recentPostsCache{
+		blogService: blogConfig.Service, loggerGetter: blogConfig.LoggerGetter, blogUrl: blogUrl + "/",
+		size: blogConfig.RecentPosts, ttl: blogConfig.RecentPostsTTL,
+	}
+	return cache.addRecentPosts
+}
+
+func (cache *recentPostsCache) addRecentPosts(data gin.H, c *gin.Context) {
+	// the page is displayed even without the recent posts
+	posts, err := cache.load(c.Request.Context())
+	if err != nil {
+		puzzleweb.GetLogger(c).Warn("Failed to retrieve recent posts", zap.Error(err))
+		return
+	}
+
+	recentPosts := make([]RecentPost, 0, len(posts))
+	for _, post := range posts {
+		recentPosts = append(recentPosts, RecentPost{
+			Title: post.Title, Url: postUrlBuilder(cache.blogUrl, post.PostId, post.Title).String(),
+			Date: common.FormatDate(post.Created, c),
+		})
+	}
+	data[recentPostsName] = recentPosts
+}
+
+// the same posts are shown to every viewer, so they are the ones visible by an anonymous viewer
+func (cache *recentPostsCache) load(ctx context.Context) ([]blogservice.BlogPost, error) {
+	cache.mutex.RLock()
+	posts, err, valid := cache.posts, cache.err, time.Now().Before(cache.expiration)
+	cache.mutex.RUnlock()
+	if valid {
+		return posts, err
+	}
+
+	// the refresh outlives the request which triggers it, it is done once for all the concurrent callers
+	refreshCtx := context.WithoutCancel(ctx)
+	if posts != nil {
+		cache.refresh.DoChan("", func() (any, error) {
+			return nil, cache.update(refreshCtx)
+		})
+		return posts, nil
+	}
+
+	_, err, _ = cache.refresh.Do("", func() (any, error) {
+		return nil, cache.update(refreshCtx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cache.mutex.RLock()
+	defer cache.mutex.RUnlock()
+	return cache.posts, cache.err
+}
+
+func (cache *recentPostsCache) update(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, recentPostsTimeOut)
+	defer cancel()
+
+	_, posts, err := cache.blogService.GetPosts(ctx, 0, 0, cache.size, "")
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	cache.expiration = time.Now().Add(cache.ttl)
+	switch {
+	case err == nil:
+		cache.posts, cache.err = posts, nil
+	case cache.posts == nil:
+		cache.err = err
+		return err
+	default:
+		cache.loggerGetter.Logger(ctx).Warn("Failed to refresh recent posts, keeping the previous ones", zap.Error(err))
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package blog
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	blogservice "github.com/dvaumoron/puzzleweb/blog/service"
+	"github.com/dvaumoron/puzzleweb/common/log"
+	"go.uber.org/zap"
+)
+
+type nopLoggerGetter struct{}
+
+func (nopLoggerGetter) Logger(context.Context) log.Logger {
+	return zap.NewNop()
+}
+
+type fakeRecentService struct {
+	blogservice.BlogService
+	calls   atomic.Int32
+	delay   time.Duration
+	mutex   sync.Mutex
+	posts   []blogservice.BlogPost
+	failure error
+}
+
+func (s *fakeRecentService) GetPosts(ctx context.Context, userId uint64, start uint64, end uint64, filter string) (uint64, []blogservice.BlogPost, error) {
+	s.calls.Add(1)
+	time.Sleep(s.delay)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return uint64(len(s.posts)), s.posts, s.failure
+}
+
+func (s *fakeRecentService) set(posts []blogservice.BlogPost, failure error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.posts, s.failure = posts, failure
+}
+
+func makeTestRecentCache(service *fakeRecentService, ttl time.Duration) *recentPostsCache {
+	return &recentPostsCache{blogService: service, loggerGetter: nopLoggerGetter{}, size: 3, ttl: ttl}
+}
+
+func TestRecentPostsSingleFlight(t *testing.T) {
+	service := &fakeRecentService{delay: 20 * time.Millisecond, posts: []blogservice.BlogPost{{Title: "a"}}}
+	cache := makeTestRecentCache(service, time.Minute)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if posts, err := cache.load(context.Background()); err != nil || len(posts) != 1 {
+				t.Errorf("unexpected result : %v, %v", posts, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls := service.calls.Load(); calls != 1 {
+		t.Errorf("expected one call, got %d", calls)
+	}
+}
+
+func TestRecentPostsCachedFailure(t *testing.T) {
+	failure := errors.New("unavailable")
+	service := &fakeRecentService{failure: failure}
+	cache := makeTestRecentCache(service, time.Minute)
+
+	for i := 0; i < 3; i++ {
+		if _, err := cache.load(context.Background()); !errors.Is(err, failure) {
+			t.Errorf("expected the failure, got %v", err)
+		}
+	}
+	if calls := service.calls.Load(); calls != 1 {
+		t.Errorf("the failure should be cached, got %d calls", calls)
+	}
+}
+
+func TestRecentPostsServeStale(t *testing.T) {
+	service := &fakeRecentService{posts: []blogservice.BlogPost{{Title: "old"}}}
+	cache := makeTestRecentCache(service, time.Millisecond)
+	if _, err := cache.load(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	service.set(nil, errors.New("unavailable"))
+	posts, err := cache.load(context.Background())
+	if err != nil || len(posts) != 1 || posts[0].Title != "old" {
+		t.Errorf("expected the stale posts, got %v, %v", posts, err)
+	}
+}
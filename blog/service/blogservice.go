@@ -20,6 +20,7 @@ package blogservice
 
 import (
 	"context"
+	"time"
 
 	profileservice "github.com/dvaumoron/puzzleweb/profile/service"
 )
@@ -28,15 +29,20 @@ type BlogPost struct {
 	PostId  uint64
 	Creator profileservice.UserProfile
 	Date    string
+	Created time.Time
 	Title   string
 	Content string
+
+	CommentCount *uint64 // only filled in the list, nil when unknown
 }
 
 type BlogService interface {
 	CreatePost(ctx context.Context, userId uint64, title string, content string) (uint64, error)
 	GetPost(ctx context.Context, userId uint64, postId uint64) (BlogPost, error)
 	GetPosts(ctx context.Context, userId uint64, start uint64, end uint64, filter string) (uint64, []BlogPost, error)
+	GetPostsByAuthor(ctx context.Context, userId uint64, authorId uint64, start uint64, end uint64) (uint64, []BlogPost, error)
 	DeletePost(ctx context.Context, userId uint64, postId uint64) error
+	DeletePostsByAuthor(ctx context.Context, userId uint64, authorId uint64) error
 	CreateRight(ctx context.Context, userId uint64) bool
 	DeleteRight(ctx context.Context, userId uint64) bool
 }
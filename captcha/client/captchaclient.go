@@ -0,0 +1,94 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package captchaclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	captchaservice "github.com/dvaumoron/puzzleweb/captcha/service"
+)
+
+// work with hCaptcha, reCAPTCHA and all provider sharing their verify protocol
+type captchaClient struct {
+	httpClient    *http.Client
+	verifyUrl     string
+	secret        string
+	siteKey       string
+	responseField string
+}
+
+// a verify response is a small JSON object
+const maxResponseSize = 64 * 1024
+
+type verifyResponse struct {
+	Success bool `json:"success"`
+}
+
+func New(verifyUrl string, secret string, siteKey string, responseField string) captchaservice.CaptchaService {
+	return captchaClient{
+		httpClient: &http.Client{}, verifyUrl: verifyUrl, secret: secret, siteKey: siteKey, responseField: responseField,
+	}
+}
+
+func (client captchaClient) ResponseField() string {
+	return client.responseField
+}
+
+func (client captchaClient) SiteKey() string {
+	return client.siteKey
+}
+
+func (client captchaClient) Verify(ctx context.Context, token string, remoteIp string) (bool, error) {
+	if token == "" {
+		return false, nil
+	}
+
+	form := url.Values{"secret": {client.secret}, "response": {token}}
+	if remoteIp != "" {
+		form.Set("remoteip", remoteIp)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, client.verifyUrl, strings.NewReader(form.Encode()))
+	if err != nil {
+		return false, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	response, err := client.httpClient.Do(request)
+	if err != nil {
+		return false, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return false, fmt.Errorf("captcha verify failed with status %d", response.StatusCode)
+	}
+
+	var result verifyResponse
+	if err = json.NewDecoder(io.LimitReader(response.Body, maxResponseSize)).Decode(&result); err != nil {
+		return false, err
+	}
+	return result.Success, nil
+}
@@ -0,0 +1,51 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package captchaclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		status  int
+		body    string
+		want    bool
+		wantErr bool
+	}{
+		{http.StatusOK, `{"success":true}`, true, false},
+		{http.StatusOK, `{"success":false}`, false, false},
+		{http.StatusInternalServerError, `{"success":true}`, false, true},
+		{http.StatusOK, `not json`, false, true},
+	}
+	for _, tt := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+			w.Write([]byte(tt.body))
+		}))
+		got, err := New(server.URL, "secret", "site key", "response").Verify(context.Background(), "token", "")
+		server.Close()
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("Verify with %d %q = %v, %v", tt.status, tt.body, got, err)
+		}
+	}
+}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"strings"
 
+	adminclient "github.com/dvaumoron/puzzleweb/admin/client"
 	"github.com/dvaumoron/puzzleweb/blog"
 	"github.com/dvaumoron/puzzleweb/common/config"
 	"github.com/dvaumoron/puzzleweb/common/config/parser"
+	"github.com/dvaumoron/puzzleweb/common/log"
 	puzzleweb "github.com/dvaumoron/puzzleweb/core"
 	"github.com/dvaumoron/puzzleweb/forum"
 	"github.com/dvaumoron/puzzleweb/locale"
@@ -33,6 +35,24 @@ func BuildDefaultSite(configExtracter config.BaseConfigExtracter) (*puzzleweb.Si
 	return puzzleweb.NewSite(configExtracter, localesManager, settingsManager), ok
 }
 
+// idempotent, the groups must be registered before
+func EnsureRoles(initCtx context.Context, logger log.Logger, rightClient adminclient.RightClient, roles []parser.RoleConfig) bool {
+	for _, role := range roles {
+		created, err := rightClient.EnsureRole(initCtx, role.Name, role.Group, role.Actions)
+		if err != nil {
+			logger.Error("Failed to ensure role", zap.String("role", role.Name), zap.String("group", role.Group), zap.Error(err))
+			return false
+		}
+
+		if created {
+			logger.Info("Role created", zap.String("role", role.Name), zap.String("group", role.Group))
+		} else {
+			logger.Info("Role already present", zap.String("role", role.Name), zap.String("group", role.Group))
+		}
+	}
+	return true
+}
+
 func AddWidgetPages(site *puzzleweb.Site, initCtx context.Context, widgetPages []parser.WidgetPageConfig, configBuilder WidgetConfigBuilder, widgets map[string]parser.WidgetConfig) bool {
 	for _, widgetPageConfig := range widgetPages {
 		name := widgetPageConfig.Path
@@ -48,7 +68,7 @@ func AddWidgetPages(site *puzzleweb.Site, initCtx context.Context, widgetPages [
 			}
 		}
 
-		widgetPage, add := MakeWidgetPage(name, initCtx, configBuilder, widgets[widgetPageConfig.WidgetRef])
+		widgetPage, add := MakeWidgetPage(site, "/"+widgetPageConfig.Path, name, initCtx, configBuilder, widgets[widgetPageConfig.WidgetRef])
 		if add {
 			if nested {
 				if !parentPage.AddSubPage(widgetPage) {
@@ -63,7 +83,8 @@ func AddWidgetPages(site *puzzleweb.Site, initCtx context.Context, widgetPages [
 	return true
 }
 
-func MakeWidgetPage(pageName string, initCtx context.Context, configBuilder WidgetConfigBuilder, widgetConfig parser.WidgetConfig) (puzzleweb.Page, bool) {
+// the site and the url of the page are used by widgets adding data to every page (like blog recent posts)
+func MakeWidgetPage(site *puzzleweb.Site, pageUrl string, pageName string, initCtx context.Context, configBuilder WidgetConfigBuilder, widgetConfig parser.WidgetConfig) (puzzleweb.Page, bool) {
 	switch kind := widgetConfig.Kind; kind {
 	case "forum":
 		if forumConfig, ok := configBuilder.MakeForumConfig(widgetConfig); ok {
@@ -71,6 +92,9 @@ func MakeWidgetPage(pageName string, initCtx context.Context, configBuilder Widg
 		}
 	case "blog":
 		if blogConfig, ok := configBuilder.MakeBlogConfig(widgetConfig); ok {
+			if adder := blog.NewRecentPostsAdder(blogConfig, pageUrl); adder != nil {
+				site.AddDefaultData(adder)
+			}
 			return blog.MakeBlogPage(pageName, blogConfig), true
 		}
 	case "wiki":
@@ -19,17 +19,30 @@
 package common
 
 import (
+	"math"
 	"net/http"
-	"slices"
+	"net/url"
 	"strconv"
+	"strings"
 	"unicode"
+	"unicode/utf8"
 
+	"github.com/dvaumoron/puzzleweb/common/log"
 	"github.com/gin-gonic/gin"
+	"golang.org/x/text/unicode/norm"
 )
 
 const (
-	RedirectName = "Redirect"
-	BaseUrlName  = "BaseUrl"
+	RedirectName   = "Redirect"
+	BaseUrlName    = "BaseUrl"
+	TrustProxyName = "TrustProxy"
+
+	PaginationWindowName = "PaginationWindow" // in the gin context
+
+	permanentRedirectName = "PermanentRedirect"
+	accessDeniedName      = "AccessDenied"
+	appErrorName          = "AppError"
+	errorResponderName    = "ErrorResponder"
 
 	UserIdName     = "Id" // current connected user id
 	ViewedUserName = "ViewedUser"
@@ -44,6 +57,7 @@ var htmlVoidElement = MakeSet([]string{"area", "base", "br", "col", "embed", "hr
 type DataAdder func(gin.H, *gin.Context)
 type Redirecter func(*gin.Context) string
 type TemplateRedirecter func(gin.H, *gin.Context) (string, string)
+type ErrorResponder func(*gin.Context, error)
 
 type LocalesManager interface {
 	GetDefaultLang() string
@@ -52,6 +66,7 @@ type LocalesManager interface {
 	GetLang(*gin.Context) string
 	CheckLang(string, *gin.Context) string
 	SetLangCookie(string, *gin.Context) string
+	GetDateFormat(string) string
 }
 
 func GetCurrentUrl(c *gin.Context) string {
@@ -62,6 +77,62 @@ func GetCurrentUrl(c *gin.Context) string {
 	return path
 }
 
+func GetAbsoluteUrl(c *gin.Context) string {
+	var urlBuilder strings.Builder
+	writeSchemeAndHost(&urlBuilder, c)
+	urlBuilder.WriteString(c.Request.URL.Path)
+	return urlBuilder.String()
+}
+
+func GetAbsoluteBaseUrl(levelToErase uint8, c *gin.Context) string {
+	var urlBuilder strings.Builder
+	writeSchemeAndHost(&urlBuilder, c)
+	urlBuilder.WriteString(GetBaseUrl(levelToErase, c))
+	return urlBuilder.String()
+}
+
+// path should start with a slash
+func GetAbsolutePathUrl(path string, c *gin.Context) string {
+	var urlBuilder strings.Builder
+	writeSchemeAndHost(&urlBuilder, c)
+	urlBuilder.WriteString(path)
+	return urlBuilder.String()
+}
+
+func writeSchemeAndHost(urlBuilder *strings.Builder, c *gin.Context) {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	host := c.Request.Host
+	// forwarded headers are only read when the request come from a trusted proxy
+	if c.GetBool(TrustProxyName) {
+		if forwardedProto := firstHeaderValue(c.GetHeader("X-Forwarded-Proto")); forwardedProto != "" {
+			scheme = forwardedProto
+		}
+		if forwardedHost := firstHeaderValue(c.GetHeader("X-Forwarded-Host")); forwardedHost != "" {
+			host = forwardedHost
+		}
+	}
+
+	urlBuilder.WriteString(scheme)
+	urlBuilder.WriteString("://")
+	urlBuilder.WriteString(host)
+}
+
+func firstHeaderValue(value string) string {
+	first, _, _ := strings.Cut(value, ",")
+	return strings.TrimSpace(first)
+}
+
+// return the real client IP, forwarded headers are only used when the request come from a trusted proxy
+func ClientIP(c *gin.Context) string {
+	if c.GetBool(TrustProxyName) {
+		return c.ClientIP()
+	}
+	return c.RemoteIP()
+}
+
 func GetBaseUrl(levelToErase uint8, c *gin.Context) string {
 	res := GetCurrentUrl(c)
 	i := len(res) - 1
@@ -74,11 +145,21 @@ func GetBaseUrl(levelToErase uint8, c *gin.Context) string {
 	return res[:i+1]
 }
 
-func checkTarget(target string) string {
-	if target == "" {
-		target = "/"
+// a path on the current site (no scheme and no host, "//host" and "/\\host" are read as a host by the browsers)
+func IsLocalPath(target string) bool {
+	if target == "" || target[0] != '/' || (len(target) > 1 && (target[1] == '/' || target[1] == '\\')) {
+		return false
+	}
+	parsed, err := url.Parse(target)
+	return err == nil && parsed.Scheme == "" && parsed.Host == ""
+}
+
+// the redirection target often comes from the client, any target outside the site is replaced by the home page
+func SafeRedirectTarget(target string) string {
+	if IsLocalPath(target) {
+		return target
 	}
-	return target
+	return "/"
 }
 
 func CheckPort(port string) string {
@@ -90,18 +171,67 @@ func CheckPort(port string) string {
 
 func CreateRedirect(redirecter Redirecter) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.Redirect(http.StatusFound, checkTarget(redirecter(c)))
+		// redirecter must be called first, it can mark the redirection as permanent
+		WriteRedirect(c, SafeRedirectTarget(redirecter(c)))
+	}
+}
+
+// the not authorized error redirect is replaced by the access denied handler when one is set,
+// then an error recorded with ErrorResponse is written by the error responder when one is set
+func WriteRedirect(c *gin.Context, target string) {
+	if target == notAuthorizedTarget {
+		if denied, ok := c.Value(accessDeniedName).(gin.HandlerFunc); ok {
+			denied(c)
+			return
+		}
+	}
+	if err, ok := c.Value(appErrorName).(error); ok {
+		if responder, ok := c.Value(errorResponderName).(ErrorResponder); ok {
+			responder(c, err)
+			return
+		}
+	}
+	c.Redirect(RedirectStatus(c), target)
+}
+
+// record err to be written with its status instead of redirecting,
+// the returned error redirect is the fallback when no responder is set
+func ErrorResponse(c *gin.Context, logger log.Logger, err error) string {
+	c.Set(appErrorName, err)
+	return DefaultErrorRedirect(logger, err.Error())
+}
+
+func SetErrorResponder(c *gin.Context, responder ErrorResponder) {
+	c.Set(errorResponderName, responder)
+}
+
+// set the handler called instead of redirecting with the not authorized error
+func SetAccessDenied(c *gin.Context, denied gin.HandlerFunc) {
+	c.Set(accessDeniedName, denied)
+}
+
+// mark the redirection returned by the current handler as permanent (like a canonical url)
+func SetPermanentRedirect(c *gin.Context) {
+	c.Set(permanentRedirectName, true)
+}
+
+func RedirectStatus(c *gin.Context) int {
+	if c.GetBool(permanentRedirectName) {
+		return http.StatusMovedPermanently
 	}
+	return http.StatusFound
 }
 
 func CreateRedirectString(target string) gin.HandlerFunc {
-	target = checkTarget(target)
+	target = SafeRedirectTarget(target)
 	return func(c *gin.Context) {
 		c.Redirect(http.StatusFound, target)
 	}
 }
 
-func GetPagination(defaultPageSize uint64, c *gin.Context) (uint64, uint64, uint64, string) {
+// the asked page size is limited by maxPageSize (no limit when 0),
+// the page number is capped to keep the end of the page in the uint64 range
+func GetPagination(defaultPageSize uint64, maxPageSize uint64, c *gin.Context) (uint64, uint64, uint64, uint64, string) {
 	pageNumber, _ := strconv.ParseUint(c.Query("pageNumber"), 10, 64)
 	if pageNumber == 0 {
 		pageNumber = 1
@@ -110,106 +240,353 @@ func GetPagination(defaultPageSize uint64, c *gin.Context) (uint64, uint64, uint
 	if pageSize == 0 {
 		pageSize = defaultPageSize
 	}
+	if maxPageSize != 0 && pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
 	filter := c.Query("filter")
 
+	if maxPageNumber := math.MaxUint64 / max(pageSize, 1); pageNumber > maxPageNumber {
+		pageNumber = maxPageNumber
+	}
 	start := (pageNumber - 1) * pageSize
 	end := start + pageSize
 
-	return pageNumber, start, end, filter
+	return pageNumber, pageSize, start, end, filter
 }
 
-func InitPagination(data gin.H, filter string, pageNumber uint64, end uint64, total uint64) {
+// length counted in rune, a zero maxLength means no limit
+func TooLong(text string, maxLength uint64) bool {
+	return maxLength != 0 && uint64(utf8.RuneCountInString(text)) > maxLength
+}
+
+func InitPagination(data gin.H, filter string, pageNumber uint64, pageSize uint64, total uint64, c *gin.Context) {
 	data["Filter"] = filter
-	if pageNumber != 1 {
+	if pageNumber > 1 {
 		data["PreviousPageNumber"] = pageNumber - 1
 	}
-	if end < total {
+	data["Total"] = total
+	data["CurrentPage"] = pageNumber
+
+	if pageNumber == 0 || pageSize == 0 {
+		return
+	}
+	totalPages := total / pageSize
+	if total%pageSize != 0 {
+		totalPages++
+	}
+	if pageNumber < totalPages {
 		data["NextPageNumber"] = pageNumber + 1
 	}
-	data["Total"] = total
+	data["TotalPages"] = totalPages
+	setPaginationLinks(pageNumber, totalPages, c)
+
+	// numbers of the nearby pages, for direct navigation
+	window := c.GetUint64(PaginationWindowName)
+	firstPage := uint64(1)
+	if pageNumber > window {
+		firstPage = pageNumber - window
+	}
+	lastPage := min(totalPages, pageNumber+window)
+	var pageNumbers []uint64
+	for number := firstPage; number <= lastPage; number++ {
+		pageNumbers = append(pageNumbers, number)
+	}
+	data["PageNumbers"] = pageNumbers
 }
 
-// html must be well formed
+// Link header (RFC 8288) with the first, previous, next and last pages,
+// the urls keep the current path and query with another pageNumber
+func setPaginationLinks(pageNumber uint64, totalPages uint64, c *gin.Context) {
+	if totalPages == 0 {
+		return
+	}
+
+	query := c.Request.URL.Query()
+	var linkBuilder strings.Builder
+	writeLink := func(number uint64, rel string) {
+		if linkBuilder.Len() != 0 {
+			linkBuilder.WriteString(", ")
+		}
+		query.Set("pageNumber", strconv.FormatUint(number, 10))
+		linkBuilder.WriteByte('<')
+		linkBuilder.WriteString(c.Request.URL.EscapedPath())
+		linkBuilder.WriteByte('?')
+		linkBuilder.WriteString(query.Encode())
+		linkBuilder.WriteString(`>; rel="`)
+		linkBuilder.WriteString(rel)
+		linkBuilder.WriteByte('"')
+	}
+
+	writeLink(1, "first")
+	if pageNumber > 1 {
+		writeLink(min(pageNumber-1, totalPages), "prev")
+	}
+	if pageNumber < totalPages {
+		writeLink(pageNumber+1, "next")
+	}
+	writeLink(totalPages, "last")
+	c.Header("Link", linkBuilder.String())
+}
+
+// tolerate malformed html : comments, doctype, CDATA and processing instructions are dropped,
+// stray closing tags are ignored, a lone '<' is escaped, a tag cut by the end of the input
+// is dropped and the tags still open are closed
 func FilterExtractHtml(html string, extractSize uint64) string {
-	buffer := make([]rune, 0, len(html))
-	chars := make(chan rune)
-	go sendChar(chars, html)
+	var builder strings.Builder
+	builder.Grow(len(html))
 	var count uint64
-	tagStack := NewStack[string]()
-	for char := range chars {
-		if char == '<' {
-			char2 := <-chars
-			if char2 == '/' {
-				buffer = append(buffer, '<', '/')
-				buffer, _ = copyTagName(buffer, chars)
-				buffer = append(buffer, '>')
-				tagStack.Pop()
-			} else {
-				temp := make([]rune, 0, 20)
-				temp = append(temp, char2)
-				temp, notEnded := copyTagName(temp, chars)
-				if tagName := string(temp); !htmlVoidElement.Contains(tagName) {
-					tagStack.Push(tagName)
-				}
-				buffer = append(buffer, '<')
-				tempLen := len(temp)
-				buffer = slices.Grow(buffer, tempLen)
-				bufferLen := len(buffer)
-				buffer = buffer[:bufferLen+tempLen]
-				copy(buffer[bufferLen:], temp)
-				if notEnded {
-					buffer = append(buffer, ' ')
-					buffer = copyTagAttribute(buffer, chars)
+	var openTags []string
+	for index := 0; index < len(html); {
+		size := 1
+		if html[index] == '<' {
+			if end, skipped := skipMarkupDeclaration(html, index+1); skipped {
+				index = end
+				continue
+			}
+			if index+1 < len(html) && isTagStart(html[index+1]) {
+				tagName, attributes, end, ok := readTag(html, index+1)
+				if !ok {
+					break
 				}
-				buffer = append(buffer, '>')
+				index = end
+				openTags = writeExtractTag(&builder, openTags, tagName, attributes)
+				continue
 			}
+			builder.WriteString("&lt;")
+		} else if size = entityLength(html, index); size != 0 {
+			// an entity is one character, never cut inside
+			builder.WriteString(html[index : index+size])
 		} else {
-			buffer = append(buffer, char)
-			count++
-			if count > extractSize {
-				buffer = append(buffer, '.', '.', '.')
-				break
+			var char rune
+			char, size = utf8.DecodeRuneInString(html[index:])
+			builder.WriteRune(char)
+		}
+		index += size
+		count++
+		if count > extractSize {
+			builder.WriteString("...")
+			break
+		}
+	}
+
+	for last := len(openTags) - 1; last >= 0; last-- {
+		writeTag(&builder, "/"+openTags[last], "")
+	}
+	return builder.String()
+}
+
+// write the tag and return the updated open tags, a closing tag also closes the tags opened
+// after its opening one, and is ignored when it has no opening one
+func writeExtractTag(builder *strings.Builder, openTags []string, tagName string, attributes string) []string {
+	if closingName, closing := strings.CutPrefix(tagName, "/"); closing {
+		closingName = asciiToLower(closingName)
+		for openIndex := len(openTags) - 1; openIndex >= 0; openIndex-- {
+			if openTags[openIndex] == closingName {
+				for last := len(openTags) - 1; last >= openIndex; last-- {
+					writeTag(builder, "/"+openTags[last], "")
+				}
+				return openTags[:openIndex]
 			}
 		}
+		return openTags
+	}
+
+	tagName = strings.ToValidUTF8(tagName, string(utf8.RuneError))
+	writeTag(builder, tagName, strings.ToValidUTF8(attributes, string(utf8.RuneError)))
+	// browsers ignore the self-closing slash on non void element
+	name := asciiToLower(tagName)
+	if !htmlVoidElement.Contains(name) {
+		openTags = append(openTags, name)
+	}
+	return openTags
+}
+
+// longest named entity is "&CounterClockwiseContourIntegral;"
+const maxEntityLength = 33
+
+// length of the character reference ("&amp;", "&#38;" or "&#x26;") starting at index, 0 when there is none
+func entityLength(html string, index int) int {
+	if html[index] != '&' {
+		return 0
 	}
 
-	for !tagStack.Empty() {
-		buffer = append(buffer, '<', '/')
-		buffer = append(buffer, []rune(tagStack.Pop())...)
-		buffer = append(buffer, '>')
+	rest := html[index+1 : min(len(html), index+maxEntityLength)]
+	isEntityChar := isAsciiAlphanumeric
+	start := 0
+	if strings.HasPrefix(rest, "#x") || strings.HasPrefix(rest, "#X") {
+		isEntityChar, start = isHexDigit, 2
+	} else if strings.HasPrefix(rest, "#") {
+		isEntityChar, start = isDigit, 1
 	}
 
-	return string(buffer)
+	end := start
+	for end < len(rest) && isEntityChar(rest[end]) {
+		end++
+	}
+	if end == start || end == len(rest) || rest[end] != ';' || (start == 0 && isDigit(rest[0])) {
+		return 0
+	}
+	return end + 2 // with '&' and ';'
 }
 
-func sendChar(chars chan<- rune, s string) {
-	for _, char := range s {
-		chars <- char
+func isDigit(char byte) bool {
+	return '0' <= char && char <= '9'
+}
+
+func isHexDigit(char byte) bool {
+	return isDigit(char) || ('a' <= char && char <= 'f') || ('A' <= char && char <= 'F')
+}
+
+func isAsciiAlphanumeric(char byte) bool {
+	return isDigit(char) || ('a' <= char && char <= 'z') || ('A' <= char && char <= 'Z')
+}
+
+// skip "<!-- -->", "<![CDATA[ ]]>", "<!DOCTYPE >" and "<? >" (up to the end of the input when unterminated),
+// return the index after it and whether something was skipped
+func skipMarkupDeclaration(html string, start int) (int, bool) {
+	rest := html[start:]
+	var terminator string
+	switch {
+	case strings.HasPrefix(rest, "!--"):
+		start += 3
+		terminator = "-->"
+	case strings.HasPrefix(rest, "![CDATA["):
+		start += 8
+		terminator = "]]>"
+	case strings.HasPrefix(rest, "!"), strings.HasPrefix(rest, "?"):
+		terminator = ">"
+	default:
+		return start, false
+	}
+
+	end := strings.Index(html[start:], terminator)
+	if end == -1 {
+		return len(html), true
 	}
-	close(chars)
+	return start + end + len(terminator), true
 }
 
-func copyTagName(buffer []rune, chars <-chan rune) ([]rune, bool) {
-	notEnded := true
-	for char := range chars {
-		if unicode.IsSpace(char) {
-			break
+// html tag names are case insensitive only for ASCII letters
+func asciiToLower(tagName string) string {
+	return strings.Map(func(char rune) rune {
+		if 'A' <= char && char <= 'Z' {
+			return char + ('a' - 'A')
 		}
-		if char == '>' {
-			notEnded = false
-			break
+		return char
+	}, tagName)
+}
+
+func isTagStart(char byte) bool {
+	return char == '/' || ('a' <= char && char <= 'z') || ('A' <= char && char <= 'Z')
+}
+
+// states of the attribute scan in readTag
+const (
+	beforeAttributeName = iota
+	inAttributeName
+	afterAttributeName
+	beforeAttributeValue
+	inQuotedAttributeValue
+	inUnquotedAttributeValue
+)
+
+// read the tag following the '<' at start (the name stop at the first space, '/' or '>'),
+// return the name, the attributes, the index after the closing '>' and false when the input ends before it,
+// attributes are scanned like a browser does so a '>' in a quoted value does not end the tag
+func readTag(html string, start int) (string, string, int, bool) {
+	nameStart := start
+	if html[start] == '/' {
+		nameStart++
+	}
+	nameEnd := strings.IndexFunc(html[nameStart:], isTagNameEnd)
+	if nameEnd == -1 {
+		return html[start:], "", len(html), false
+	}
+	nameEnd += nameStart
+	tagName := html[start:nameEnd]
+	if html[nameEnd] == '>' {
+		return tagName, "", nameEnd + 1, true
+	}
+
+	attributesStart := nameEnd
+	if html[nameEnd] != '/' {
+		attributesStart++
+	}
+	state := beforeAttributeName
+	var quote byte
+	for index := attributesStart; index < len(html); index++ {
+		char := html[index]
+		switch {
+		case state == inQuotedAttributeValue:
+			if char == quote {
+				state = beforeAttributeName
+			}
+		case char == '>':
+			return tagName, html[attributesStart:index], index + 1, true
+		case isHtmlSpace(char):
+			switch state {
+			case inAttributeName:
+				state = afterAttributeName
+			case inUnquotedAttributeValue:
+				state = beforeAttributeName
+			}
+		case state == beforeAttributeValue:
+			if char == '"' || char == '\'' {
+				quote = char
+				state = inQuotedAttributeValue
+			} else {
+				state = inUnquotedAttributeValue
+			}
+		case state == inUnquotedAttributeValue:
+		case char == '/':
+			state = beforeAttributeName
+		case char == '=' && state != beforeAttributeName:
+			state = beforeAttributeValue
+		default:
+			state = inAttributeName
 		}
-		buffer = append(buffer, char)
 	}
-	return buffer, notEnded
+	return tagName, html[attributesStart:], len(html), false
 }
 
-func copyTagAttribute(buffer []rune, chars <-chan rune) []rune {
-	for char := range chars {
-		if char == '>' {
-			break
+func isHtmlSpace(char byte) bool {
+	return char == ' ' || char == '\t' || char == '\n' || char == '\f' || char == '\r'
+}
+
+func isTagNameEnd(char rune) bool {
+	return char == '>' || char == '/' || (char < utf8.RuneSelf && isHtmlSpace(byte(char)))
+}
+
+// letters without decomposition into base letter and diacritic
+var slugTransliterations = map[rune]string{
+	'ß': "ss", 'æ': "ae", 'œ': "oe", 'ø': "o", 'đ': "d", 'ð': "d", 'ł': "l", 'þ': "th", 'ı': "i",
+}
+
+// URL-safe ASCII : lower case, accents removed, other runs of non alphanumeric become a single hyphen
+// (empty when nothing remains, like with CJK or emoji, the caller choose its fallback)
+func Slugify(title string) string {
+	var slugBuilder strings.Builder
+	dash := false
+	writeAscii := func(part string) {
+		if dash && slugBuilder.Len() != 0 {
+			slugBuilder.WriteByte('-')
+		}
+		slugBuilder.WriteString(part)
+		dash = false
+	}
+	for _, char := range norm.NFD.String(strings.ToLower(title)) {
+		switch {
+		case unicode.Is(unicode.Mn, char):
+			// diacritic removed, the base letter is kept
+		case char < utf8.RuneSelf && (unicode.IsLetter(char) || unicode.IsDigit(char)):
+			writeAscii(string(char))
+		default:
+			if transliteration, ok := slugTransliterations[char]; ok {
+				writeAscii(transliteration)
+			} else {
+				dash = true
+			}
 		}
-		buffer = append(buffer, char)
 	}
-	return buffer
+	return slugBuilder.String()
 }
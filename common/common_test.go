@@ -0,0 +1,220 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package common
+
+import (
+	"math"
+	"net/http/httptest"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSafeRedirectTarget(t *testing.T) {
+	cases := map[string]string{
+		"/ok":                 "/ok",
+		"/safe/path?lang=fr":  "/safe/path?lang=fr",
+		"/":                   "/",
+		"":                    "/",
+		"//host":              "/",
+		"//host/path":         "/",
+		"/\\host":             "/",
+		"https://host":        "/",
+		"http://host/path":    "/",
+		"javascript:alert(1)": "/",
+		"relative/path":       "/",
+	}
+	for target, want := range cases {
+		if got := SafeRedirectTarget(target); got != want {
+			t.Errorf("SafeRedirectTarget(%q) = %q, want %q", target, got, want)
+		}
+	}
+}
+
+func makePaginationContext(query string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/list?"+query, nil)
+	return c
+}
+
+func TestGetPagination(t *testing.T) {
+	maxNumber := strconv.FormatUint(math.MaxUint64, 10)
+	tests := []struct {
+		query                        string
+		defaultPageSize, maxPageSize uint64
+		pageNumber, pageSize         uint64
+		start, end                   uint64
+	}{
+		{"", 10, 0, 1, 10, 0, 10},
+		{"pageNumber=3&pageSize=5", 10, 0, 3, 5, 10, 15},
+		{"pageSize=50", 10, 20, 1, 20, 0, 20},
+		{"pageNumber=2", 0, 0, 2, 0, 0, 0},
+		{"pageNumber=" + maxNumber, 10, 0, math.MaxUint64 / 10, 10, (math.MaxUint64/10 - 1) * 10, math.MaxUint64 / 10 * 10},
+		{"pageNumber=" + maxNumber + "&pageSize=" + maxNumber, 10, 0, 1, math.MaxUint64, 0, math.MaxUint64},
+	}
+	for _, tt := range tests {
+		pageNumber, pageSize, start, end, _ := GetPagination(tt.defaultPageSize, tt.maxPageSize, makePaginationContext(tt.query))
+		if pageNumber != tt.pageNumber || pageSize != tt.pageSize || start != tt.start || end != tt.end {
+			t.Errorf("GetPagination(%q) = %d, %d, %d, %d, want %d, %d, %d, %d", tt.query,
+				pageNumber, pageSize, start, end, tt.pageNumber, tt.pageSize, tt.start, tt.end)
+		}
+		if start > end {
+			t.Errorf("GetPagination(%q) : start %d after end %d", tt.query, start, end)
+		}
+	}
+}
+
+func TestInitPagination(t *testing.T) {
+	data := gin.H{}
+	InitPagination(data, "", 2, 0, 25, makePaginationContext(""))
+	if _, ok := data["TotalPages"]; ok {
+		t.Error("a zero page size should not compute the page count")
+	}
+
+	data = gin.H{}
+	InitPagination(data, "", 2, 10, 25, makePaginationContext("pageNumber=2"))
+	if data["TotalPages"] != uint64(3) || data["NextPageNumber"] != uint64(3) || data["PreviousPageNumber"] != uint64(1) {
+		t.Errorf("unexpected pagination data : %v", data)
+	}
+
+	data = gin.H{}
+	InitPagination(data, "", 1, 10, math.MaxUint64, makePaginationContext(""))
+	if data["TotalPages"] != uint64(math.MaxUint64/10+1) {
+		t.Errorf("unexpected page count : %v", data["TotalPages"])
+	}
+}
+
+func TestPaginationLinksEscapedPath(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/wiki/en/list/a%3Eb%20c?pageNumber=2", nil)
+	InitPagination(gin.H{}, "", 2, 10, 30, c)
+	link := c.Writer.Header().Get("Link")
+	if want := `</wiki/en/list/a%3Eb%20c?pageNumber=1>; rel="first"`; !strings.HasPrefix(link, want) {
+		t.Errorf("got %q, want a prefix %q", link, want)
+	}
+}
+
+func TestFilterExtractHtmlEntities(t *testing.T) {
+	tests := []struct {
+		html        string
+		extractSize uint64
+		want        string
+	}{
+		{"a &amp; b", 3, "a &amp; ..."},
+		{"&lt;&gt;&quot;x", 2, "&lt;&gt;&quot;..."},
+		{"x&#233;&#xE9;y", 2, "x&#233;&#xE9;..."},
+		{"a & b", 10, "a & b"},
+		{"a &nope b;", 3, "a &n..."},
+		{"<p>&eacute;t&eacute;</p>", 2, "<p>&eacute;t&eacute;...</p>"},
+	}
+	for _, tt := range tests {
+		if got := FilterExtractHtml(tt.html, tt.extractSize); got != tt.want {
+			t.Errorf("FilterExtractHtml(%q, %d) = %q, want %q", tt.html, tt.extractSize, got, tt.want)
+		}
+	}
+}
+
+func FuzzFilterExtractHtml(f *testing.F) {
+	for _, seed := range []string{
+		"<p>a &amp; b</p>", "<b><i>x</b>", "&#x26;&#38;", "<!-- c --><a href='>'>l</a>", "<", "&", "é<br/>",
+	} {
+		f.Add(seed, uint64(3))
+	}
+	f.Fuzz(func(t *testing.T, html string, extractSize uint64) {
+		extractSize %= 64
+		res := FilterExtractHtml(html, extractSize)
+		if !utf8.ValidString(res) {
+			t.Fatalf("invalid UTF-8 in %q", res)
+		}
+		// the result is well formed, so filtering it again keeps it
+		if again := FilterExtractHtml(res, uint64(len(res))); again != res {
+			t.Fatalf("not stable : %q then %q", res, again)
+		}
+	})
+}
+
+func TestTooLong(t *testing.T) {
+	tests := []struct {
+		text      string
+		maxLength uint64
+		want      bool
+	}{
+		{"", 0, false},
+		{"anything", 0, false},
+		{"abc", 3, false},
+		{"abcd", 3, true},
+		{"été", 3, false}, // counted in rune, not in byte
+		{"étés", 3, true},
+		{"", 1, false},
+	}
+	for _, tt := range tests {
+		if got := TooLong(tt.text, tt.maxLength); got != tt.want {
+			t.Errorf("TooLong(%q, %d) = %t, want %t", tt.text, tt.maxLength, got, tt.want)
+		}
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	cases := map[string]string{
+		"Hello World":     "hello-world",
+		"  Déjà vu !  ":   "deja-vu",
+		"Straße & Œuvre":  "strasse-oeuvre",
+		"C++ / Go 1.21":   "c-go-1-21",
+		"日本語":             "",
+		"🎉🎉":              "",
+		"Release 🎉 notes": "release-notes",
+		"中文 title":        "title",
+		"":                "",
+		"---":             "",
+		"ＡＢＣ full width":  "full-width",
+	}
+	for title, want := range cases {
+		if got := Slugify(title); got != want {
+			t.Errorf("Slugify(%q) = %q, want %q", title, got, want)
+		}
+	}
+}
+
+func TestFilterExtractHtmlNoLeak(t *testing.T) {
+	html := strings.Repeat("<p>some <b>text</b> &amp; more</p>", 100)
+	before := runtime.NumGoroutine()
+	for i := 0; i < 100; i++ {
+		FilterExtractHtml(html, 10) // truncated early
+	}
+	runtime.Gosched()
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("%d goroutines left after the truncated extracts (%d before)", after, before)
+	}
+}
+
+func BenchmarkFilterExtractHtml(b *testing.B) {
+	html := strings.Repeat(`<p>Some <a href="/wiki/en/view/Page">linked</a> text &amp; <code>code</code>, été.</p>`, 200)
+	for _, extractSize := range []uint64{200, uint64(len(html))} {
+		b.Run(strconv.FormatUint(extractSize, 10), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				FilterExtractHtml(html, extractSize)
+			}
+		})
+	}
+}
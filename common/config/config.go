@@ -24,9 +24,12 @@ import (
 
 	adminservice "github.com/dvaumoron/puzzleweb/admin/service"
 	blogservice "github.com/dvaumoron/puzzleweb/blog/service"
+	captchaservice "github.com/dvaumoron/puzzleweb/captcha/service"
+	"github.com/dvaumoron/puzzleweb/common"
 	"github.com/dvaumoron/puzzleweb/common/log"
 	forumservice "github.com/dvaumoron/puzzleweb/forum/service"
 	loginservice "github.com/dvaumoron/puzzleweb/login/service"
+	mailservice "github.com/dvaumoron/puzzleweb/mail/service"
 	markdownservice "github.com/dvaumoron/puzzleweb/markdown/service"
 	profileservice "github.com/dvaumoron/puzzleweb/profile/service"
 	widgetservice "github.com/dvaumoron/puzzleweb/remotewidget/service"
@@ -38,11 +41,35 @@ import (
 const (
 	WebKey = "puzzleWeb"
 
-	DefaultFavicon = "/favicon.ico"
+	DefaultFavicon   = "/favicon.ico"
+	ManifestUrl      = "/manifest.webmanifest"
+	HighlightCSSUrl  = "/highlight.css"
+	ServiceWorkerUrl = "/sw.js"
+
+	// query parameter carrying the fingerprint of the static files
+	StaticVersionQuery = "v"
+
+	// what happens to the content of a deleted user
+	DeletedContentAnonymize = "anonymize"
+	DeletedContentDelete    = "delete"
+
+	// what happens when the access to a page is denied
+	AccessDeniedError     = "error"     // redirect with the not authorized error
+	AccessDeniedLogin     = "login"     // anonymous user are sent to the login page, others get the error
+	AccessDeniedForbidden = "forbidden" // anonymous user are sent to the login page, others get a 403 page
+
+	// order of the blog comments
+	CommentSortAsc  = "asc" // oldest first
+	CommentSortDesc = "desc"
+
+	// level of the access log
+	AccessLogDebug = "debug"
+	AccessLogInfo  = "info"
+	AccessLogWarn  = "warn"
+	AccessLogNone  = "none" // disabled
 )
 
 type AuthConfig = ServiceConfig[adminservice.AuthService]
-type LoginConfig = ServiceConfig[loginservice.LoginService]
 type SettingsConfig = ServiceConfig[sessionservice.SessionService]
 type TemplateConfig = ServiceConfig[templateservice.TemplateService]
 type RemoteWidgetConfig = ServiceConfig[widgetservice.WidgetService]
@@ -60,6 +87,7 @@ type BaseConfigExtracter interface {
 	ExtractAdminConfig() AdminConfig
 	ExtractSettingsConfig() SettingsConfig
 	ExtractProfileConfig() ProfileConfig
+	ExtractVerificationConfig() VerificationConfig
 }
 
 type LocalesConfig struct {
@@ -68,6 +96,23 @@ type LocalesConfig struct {
 	Domain         string
 	SessionTimeOut int
 	AllLang        []string
+	DateFormat     string            // used when a locale has no known convention
+	DateFormats    map[string]string // explicitly configured by locale
+}
+
+type LoginConfig struct {
+	ServiceConfig[loginservice.LoginService]
+	CaptchaService captchaservice.CaptchaService
+}
+
+type VerificationConfig struct {
+	ServiceConfig[profileservice.AdvancedProfileService]
+	Mailer          mailservice.Mailer
+	TemplateService templateservice.TemplateService
+	SigningKey      []byte
+	TokenTTL        time.Duration
+	GatedActions    []string // empty when the verification is disabled
+	Resend          bool
 }
 
 type ServiceConfig[ServiceType any] struct {
@@ -90,26 +135,74 @@ func (c *ServiceConfig[ServiceType]) GetLoggerGetter() log.LoggerGetter {
 
 type SessionConfig struct {
 	ServiceConfig[sessionservice.SessionService]
-	Domain  string
-	TimeOut int
+	Domain     string
+	TimeOut    int
+	SigningKey []byte
+	Fallback   bool
+
+	MaxLifeTime int // in seconds since the login, 0 when unlimited
+
+	CookieName         string
+	FallbackCookieName string
+	FallbackSigningKey []byte
 }
 
 type SiteConfig struct {
 	ServiceConfig[sessionservice.SessionService]
 	TemplateService    templateservice.TemplateService
+	SessionSigningKey  []byte
+	SessionFallback    bool
 	Domain             string
 	Port               string
-	SessionTimeOut     int
+	SessionTimeOut     int // idle time out, refreshed by each request
+	SessionMaxLifeTime int // since the login, 0 when unlimited
 	MaxMultipartMemory int64
+	TrustedProxies     []string
+	Features           []string
+	UserFeatures       []string
 	StaticFileSystem   http.FileSystem
 	FaviconPath        string
+	IconPaths          map[string]string // url to path in the static folder
+	Manifest           []byte
+	ServiceWorkerPath  string
 	Page404Url         string
+	HomeRedirect       string // empty to render the index template
+	StaticMaxAge       time.Duration
+	StaticVersion      string // fingerprint of the static folder, empty when disabled
+	StaticAssets       map[string]string
 	LangPicturePaths   map[string]string
+	TimeAgoLimit       time.Duration
+	TimeZone           *time.Location
+	PaginationWindow   uint64
+	MaxDraftSize       uint64
+	PageCacheTTL       time.Duration // 0 disable the cache of static pages
+	PageCacheSize      int
+
+	AccessDenied string
+
+	MaxInFlight  int // 0 disable the limit
+	InFlightWait time.Duration
+
+	HighlightCSS []byte // nil when disabled
+
+	AccessLogLevel     string
+	AccessLogSkipPaths []string // like a health check
+
+	SessionCookie         string
+	SessionFallbackCookie string
+	SessionHostOnly       bool // without Domain attribute (required by the "__Host-" prefix)
 }
 
 func (sc *SiteConfig) ExtractSessionConfig() SessionConfig {
+	domain := sc.Domain
+	if sc.SessionHostOnly {
+		domain = ""
+	}
 	return SessionConfig{
-		ServiceConfig: sc.ServiceConfig, Domain: sc.Domain, TimeOut: sc.SessionTimeOut,
+		ServiceConfig: sc.ServiceConfig, Domain: domain, TimeOut: sc.SessionTimeOut,
+		SigningKey: common.DeriveKey(sc.SessionSigningKey, common.SessionIdKeyPurpose), Fallback: sc.SessionFallback,
+		MaxLifeTime: sc.SessionMaxLifeTime, CookieName: sc.SessionCookie, FallbackCookieName: sc.SessionFallbackCookie,
+		FallbackSigningKey: common.DeriveKey(sc.SessionSigningKey, common.SessionFallbackKeyPurpose),
 	}
 }
 
@@ -119,39 +212,75 @@ func (sc *SiteConfig) ExtractTemplateConfig() TemplateConfig {
 
 type AdminConfig struct {
 	ServiceConfig[adminservice.AdminService]
-	UserService    loginservice.AdvancedUserService
+	UserService    loginservice.FullLoginService
 	ProfileService profileservice.AdvancedProfileService
+	SessionService sessionservice.SessionService
 	PageSize       uint64
+	MaxPageSize    uint64
+	DateFormat     string
+	RolePresets    map[string][]string // name to roles ("role/group")
 }
 
 type ProfileConfig struct {
 	ServiceConfig[profileservice.AdvancedProfileService]
 	AdminService adminservice.AdminService
 	LoginService loginservice.FullLoginService
+	BlogService  blogservice.BlogService // optional, for recent posts
+	BlogUrl      string
+
+	DeletedContentPolicy string
+
+	PictureMaxSize int64
+	PictureMaxDim  int
+	PageSize       uint64
+	MaxPageSize    uint64
 }
 
 type BlogConfig struct {
 	ServiceConfig[blogservice.BlogService]
-	MarkdownService markdownservice.MarkdownService
-	CommentService  forumservice.CommentService
-	Domain          string
-	Port            string
-	DateFormat      string
-	PageSize        uint64
-	ExtractSize     uint64
-	FeedFormat      string
-	FeedSize        uint64
-	Args            []string
+	MarkdownService  markdownservice.MarkdownService
+	CommentService   forumservice.CommentService
+	PendingComments  forumservice.CommentService // nil when moderation is disabled
+	DateFormat       string
+	PageSize         uint64
+	MaxPageSize      uint64
+	ExtractSize      uint64
+	FeedFormat       string
+	MaxTitleLength   uint64
+	MaxContentLength uint64
+	MaxCommentLength uint64
+	FormGuard        common.FormGuard
+	WordFilter       common.WordFilter
+	CaptchaService   captchaservice.CaptchaService
+	Mailer           mailservice.Mailer // nil when disabled
+	TemplateService  templateservice.TemplateService
+	FeedSize         uint64
+	RecentPosts      uint64
+	RecentPostsTTL   time.Duration
+	CommentSort      string // default order, CommentSortAsc or CommentSortDesc
+	Args             []string
+
+	AllowAnonymousComments bool
+	AnonymousCommentDelay  time.Duration // between two anonymous comments from the same client ip
 }
 
 type ForumConfig struct {
 	ServiceConfig[forumservice.ForumService]
-	PageSize uint64
-	Args     []string
+	PageSize    uint64
+	MaxPageSize uint64
+	Args        []string
 }
 
 type WikiConfig struct {
 	ServiceConfig[wikiservice.WikiService]
-	MarkdownService markdownservice.MarkdownService
-	Args            []string
+	MarkdownService        markdownservice.MarkdownService
+	ExtractSize            uint64
+	MaxContentLength       uint64
+	TitleCaseFold          bool
+	TitleSpaceToUnderscore bool
+	LinkPattern            string // empty for the default one
+	MissingLinkClass       string
+	PageSize               uint64
+	MaxPageSize            uint64
+	Args                   []string
 }
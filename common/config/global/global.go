@@ -20,8 +20,11 @@ package globalconfig
 
 import (
 	"context"
+	"crypto/rand"
 	"net/http"
+	"net/mail"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -31,37 +34,57 @@ import (
 	adminclient "github.com/dvaumoron/puzzleweb/admin/client"
 	adminservice "github.com/dvaumoron/puzzleweb/admin/service"
 	blogclient "github.com/dvaumoron/puzzleweb/blog/client"
+	blogservice "github.com/dvaumoron/puzzleweb/blog/service"
+	captchaclient "github.com/dvaumoron/puzzleweb/captcha/client"
+	captchaservice "github.com/dvaumoron/puzzleweb/captcha/service"
+	"github.com/dvaumoron/puzzleweb/common"
 	"github.com/dvaumoron/puzzleweb/common/config"
 	"github.com/dvaumoron/puzzleweb/common/config/parser"
+	"github.com/dvaumoron/puzzleweb/common/grpcretry"
 	"github.com/dvaumoron/puzzleweb/common/log"
 	forumclient "github.com/dvaumoron/puzzleweb/forum/client"
 	forumservice "github.com/dvaumoron/puzzleweb/forum/service"
 	loginclient "github.com/dvaumoron/puzzleweb/login/client"
 	loginservice "github.com/dvaumoron/puzzleweb/login/service"
+	mailclient "github.com/dvaumoron/puzzleweb/mail/client"
+	mailservice "github.com/dvaumoron/puzzleweb/mail/service"
 	markdownclient "github.com/dvaumoron/puzzleweb/markdown/client"
+	"github.com/dvaumoron/puzzleweb/markdown/highlight"
 	markdownservice "github.com/dvaumoron/puzzleweb/markdown/service"
 	strengthclient "github.com/dvaumoron/puzzleweb/passwordstrength/client"
 	profileclient "github.com/dvaumoron/puzzleweb/profile/client"
 	profileservice "github.com/dvaumoron/puzzleweb/profile/service"
 	widgetclient "github.com/dvaumoron/puzzleweb/remotewidget/client"
 	sessionclient "github.com/dvaumoron/puzzleweb/session/client"
+	redisclient "github.com/dvaumoron/puzzleweb/session/redisclient"
 	sessionservice "github.com/dvaumoron/puzzleweb/session/service"
 	templateclient "github.com/dvaumoron/puzzleweb/templates/client"
 	templateservice "github.com/dvaumoron/puzzleweb/templates/service"
 	wikiclient "github.com/dvaumoron/puzzleweb/wiki/client"
+	"github.com/redis/go-redis/v9"
 	"github.com/uptrace/opentelemetry-go-extra/otelzap"
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
 )
 
 const (
-	defaultName           = "default"
-	defaultSessionTimeOut = 1200
-	defaultServiceTimeOut = 5 * time.Second
+	defaultName            = "default"
+	defaultSessionTimeOut  = 1200
+	defaultServiceTimeOut  = 5 * time.Second
+	defaultInFlightWait    = 100 * time.Millisecond
+	defaultRetryBackoff    = 100 * time.Millisecond
+	defaultRetryMaxBackoff = 2 * time.Second
+	defaultTimeAgoLimit    = 30 * 24 * time.Hour
+	defaultVerificationTTL = 48 * time.Hour
+	defaultRecentPostsTTL  = time.Minute
+	defaultSessionCookie   = "pw_session_id"
+	defaultFallbackCookie  = "pw_session_fallback"
+	defaultAnonymousDelay  = time.Minute
 )
 
 type loggerWrapper struct {
@@ -78,17 +101,71 @@ type GlobalConfig struct {
 
 	AllLang            []string
 	SessionTimeOut     int
+	SessionMaxLifeTime int
+	SessionSigningKey  []byte
+	SessionFallback    bool
 	ServiceTimeOut     time.Duration
 	MaxMultipartMemory int64
+	MaxInFlight        uint64
+	InFlightWait       time.Duration
+	TrustedProxies     []string
+	Features           []string
+	UserFeatures       []string
 	DateFormat         string
+	TimeAgoLimit       time.Duration
+	TimeZone           *time.Location
 	PageSize           uint64
+	MaxPageSize        uint64
+	PaginationWindow   uint64
 	ExtractSize        uint64
 	FeedFormat         string
 	FeedSize           uint64
+	MaxTitleLength     uint64
+	MaxContentLength   uint64
+	MaxCommentLength   uint64
+	MaxDraftSize       uint64
+	FormGuard          common.FormGuard
+	WordFilter         common.WordFilter
+
+	PageCacheTTL  time.Duration
+	PageCacheSize uint64
+
+	Highlighter  highlight.Highlighter
+	HighlightCSS []byte // nil when no widget highlight code
+
+	CaptchaService captchaservice.CaptchaService // nil when disabled
+	Mailer         mailservice.Mailer            // nil when disabled
+
+	VerifiedActions    []string
+	VerificationTTL    time.Duration
+	VerificationResend bool
+
+	ProfilePictureMaxSize uint64
+	ProfilePictureMaxDim  uint64
+	ProfileBlog           parser.WidgetConfig
+	ProfileBlogUrl        string
+	DeletedContentPolicy  string
+	RolePresets           map[string][]string
 
 	StaticFileSystem http.FileSystem
 	FaviconPath      string
+	IconPaths        map[string]string
+	ServiceWorker    string // path in the static folder, empty when disabled
+	Manifest         []byte // nil when disabled
 	Page404Url       string
+	AccessDenied     string
+	HomeRedirect     string // empty when disabled
+
+	StaticMaxAge  time.Duration
+	StaticVersion string            // empty when the fingerprint is disabled
+	StaticAssets  map[string]string // nil when there is no asset pattern
+
+	AccessLogLevel     string
+	AccessLogSkipPaths []string
+
+	SessionCookie         string
+	SessionFallbackCookie string
+	SessionHostOnly       bool
 
 	InitCtx          context.Context
 	Logger           log.Logger // for init phase (have the context)
@@ -96,6 +173,7 @@ type GlobalConfig struct {
 	TracerProvider   *sdktrace.TracerProvider
 	Tracer           trace.Tracer
 	LangPicturePaths map[string]string
+	LangDateFormats  map[string]string
 
 	DialOptions     []grpc.DialOption
 	SessionService  sessionservice.SessionService
@@ -118,6 +196,7 @@ type GlobalConfig struct {
 
 func Init(serviceName string, version string, parsedConfig parser.ParsedConfig, err error) (*GlobalConfig, trace.Span) {
 	logger, tp := puzzletelemetry.Init(serviceName, version)
+	logger = tuneLogger(logger, parsedConfig)
 	tracer := tp.Tracer(config.WebKey)
 
 	initCtx, initSpan := tracer.Start(context.Background(), "initialization")
@@ -137,6 +216,11 @@ func Init(serviceName string, version string, parsedConfig parser.ParsedConfig,
 		ctxLogger.Info("sessionTimeOut empty, using default", zap.Int(defaultName, defaultSessionTimeOut))
 		sessionTimeOut = defaultSessionTimeOut
 	}
+	sessionMaxLifeTime := parsedConfig.SessionMaxLifeTime
+	if sessionMaxLifeTime < 0 {
+		ctxLogger.Warn("Negative sessionMaxLifeTime, disabling it", zap.Int("sessionMaxLifeTime", sessionMaxLifeTime))
+		sessionMaxLifeTime = 0
+	}
 
 	serviceTimeOutStr := parsedConfig.ServiceTimeOut
 	if serviceTimeOutStr == "" {
@@ -156,17 +240,134 @@ func Init(serviceName string, version string, parsedConfig parser.ParsedConfig,
 
 	dateFormat := retrieveWithDefault(ctxLogger, "dateFormat", parsedConfig.DateFormat, "2/1/2006 15:04:05")
 	pageSize := retrieveUintWithDefault(ctxLogger, "pageSize", parsedConfig.PageSize, 20)
+	maxPageSize := retrieveUintWithDefault(ctxLogger, "maxPageSize", parsedConfig.MaxPageSize, 100)
+	// number of page links around the current one
+	paginationWindow := retrieveUintWithDefault(ctxLogger, "paginationWindow", parsedConfig.PaginationWindow, 2)
+	if maxPageSize < pageSize {
+		ctxLogger.Warn("maxPageSize lower than pageSize, using pageSize", zap.Uint64("pageSize", pageSize))
+		maxPageSize = pageSize
+	}
 	extractSize := retrieveUintWithDefault(ctxLogger, "extractSize", parsedConfig.ExtractSize, 200)
 	feedFormat := retrieveWithDefault(ctxLogger, "feedFormat", parsedConfig.FeedFormat, "atom")
 	feedSize := retrieveUintWithDefault(ctxLogger, "feedSize", parsedConfig.FeedSize, 100)
+	timeAgoLimit := retrieveDurationWithDefault(ctxLogger, "timeAgoLimit", parsedConfig.TimeAgoLimit, defaultTimeAgoLimit)
+
+	timeZone := time.Local
+	if timeZoneName := parsedConfig.TimeZone; timeZoneName != "" {
+		if timeZone, err = time.LoadLocation(timeZoneName); err != nil {
+			ctxLogger.Warn("Unknown timeZone, using the server one", zap.String("timeZone", timeZoneName), zap.Error(err))
+			timeZone = time.Local
+		}
+	}
+	pageCacheTTL := retrieveDurationWithDefault(ctxLogger, "pageCacheTTL", parsedConfig.PageCacheTTL, 0)
+	pageCacheSize := retrieveUintWithDefault(ctxLogger, "pageCacheSize", parsedConfig.PageCacheSize, 1000)
+	inFlightWait := retrieveDurationWithDefault(ctxLogger, "inFlightWait", parsedConfig.InFlightWait, defaultInFlightWait)
+	// lengths are counted in characters
+	maxTitleLength := retrieveUintWithDefault(ctxLogger, "maxTitleLength", parsedConfig.MaxTitleLength, 200)
+	maxContentLength := retrieveUintWithDefault(ctxLogger, "maxContentLength", parsedConfig.MaxContentLength, 100000)
+	maxCommentLength := retrieveUintWithDefault(ctxLogger, "maxCommentLength", parsedConfig.MaxCommentLength, 5000)
+	maxDraftSize := retrieveUintWithDefault(ctxLogger, "maxDraftSize", parsedConfig.MaxDraftSize, 64*1024)
+
+	formGuard := common.FormGuard{HoneypotField: parsedConfig.HoneypotField}
+	if minFormFillTime := parsedConfig.MinFormFillTime; minFormFillTime != "" {
+		formGuard.MinFillTime, err = time.ParseDuration(minFormFillTime)
+		if err != nil {
+			ctxLogger.Warn("Failed to parse minFormFillTime, check disabled", zap.Error(err))
+		}
+		// older forms are rejected (a replayed timestamp stay valid otherwise)
+		formGuard.MaxFillTime = retrieveDurationWithDefault(ctxLogger, "maxFormFillTime", parsedConfig.MaxFormFillTime, 6*time.Hour)
+		formGuard.SigningKey = []byte(parsedConfig.FormSigningKey)
+		if len(formGuard.SigningKey) == 0 {
+			// signed form will be invalidated by a restart
+			ctxLogger.Info("formSigningKey empty, using a random one")
+			formGuard.SigningKey = make([]byte, 32)
+			if _, err = rand.Read(formGuard.SigningKey); err != nil {
+				ctxLogger.Fatal("Failed to generate formSigningKey", zap.Error(err))
+			}
+		}
+	}
+
+	blockedWordsAction := retrieveWithDefault(ctxLogger, "blockedWordsAction", parsedConfig.BlockedWordsAction, common.BlockedWordsReject)
+	if blockedWordsAction != common.BlockedWordsReject && blockedWordsAction != common.BlockedWordsMask {
+		ctxLogger.Warn("Unknown blockedWordsAction, texts will be rejected", zap.String("blockedWordsAction", blockedWordsAction))
+		blockedWordsAction = common.BlockedWordsReject
+	}
+	wordFilter := common.NewWordFilter(
+		parsedConfig.BlockedWords, blockedWordsAction == common.BlockedWordsMask, parsedConfig.BlockedWordsSpacing,
+	)
+
+	rolePresets := make(map[string][]string, len(parsedConfig.RolePresets))
+	for _, preset := range parsedConfig.RolePresets {
+		rolePresets[preset.Name] = preset.Roles
+	}
+
+	var highlighter highlight.Highlighter
+	var highlightCSS []byte
+	if slices.ContainsFunc(parsedConfig.Widgets, func(widget parser.WidgetConfig) bool { return widget.HighlightCode }) {
+		highlighter = highlight.New(
+			retrieveWithDefault(ctxLogger, "highlightStyle", parsedConfig.HighlightStyle, "github"),
+			retrieveWithDefault(ctxLogger, "highlightClassPrefix", parsedConfig.HighlightClassPrefix, "hl-"),
+		)
+		if highlightCSS, err = highlighter.CSS(); err != nil {
+			ctxLogger.Fatal("Failed to generate highlighting css", zap.Error(err))
+		}
+	}
+
+	var captchaService captchaservice.CaptchaService
+	if captchaVerifyUrl := parsedConfig.CaptchaVerifyUrl; captchaVerifyUrl != "" {
+		captchaResponseField := retrieveWithDefault(
+			ctxLogger, "captchaResponseField", parsedConfig.CaptchaResponseField, "h-captcha-response",
+		)
+		captchaService = captchaclient.New(
+			captchaVerifyUrl, parsedConfig.CaptchaSecret, parsedConfig.CaptchaSiteKey, captchaResponseField,
+		)
+	}
+
+	mailer := newMailer(ctxLogger, parsedConfig, loggerGetter)
+	verifiedActions := parsedConfig.VerifiedActions
+	if len(verifiedActions) != 0 && mailer == nil {
+		ctxLogger.Warn("verifiedActions ignored, mails are disabled")
+		verifiedActions = nil
+	}
+	verificationTTL := retrieveDurationWithDefault(ctxLogger, "verificationTTL", parsedConfig.VerificationTTL, defaultVerificationTTL)
+
+	retryPolicy := grpcretry.Policy{
+		MaxAttempts: retrieveUintWithDefault(ctxLogger, "retryMaxAttempts", parsedConfig.RetryMaxAttempts, 3),
+		Backoff:     retrieveDurationWithDefault(ctxLogger, "retryBackoff", parsedConfig.RetryBackoff, defaultRetryBackoff),
+		MaxBackoff:  retrieveDurationWithDefault(ctxLogger, "retryMaxBackoff", parsedConfig.RetryMaxBackoff, defaultRetryMaxBackoff),
+		Codes:       retrieveCodesWithDefault(ctxLogger, "retryCodes", parsedConfig.RetryCodes, codes.Unavailable),
+	}
 
 	dialOptions := []grpc.DialOption{
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
 		grpc.WithUnaryInterceptor(otelgrpc.UnaryClientInterceptor()),
+		grpc.WithChainUnaryInterceptor(grpcretry.UnaryClientInterceptor(retryPolicy)),
 		grpc.WithStreamInterceptor(otelgrpc.StreamClientInterceptor()),
 	}
 
-	sessionService := sessionclient.New(parsedConfig.SessionServiceAddr, dialOptions)
+	sessionSigningKey := []byte(parsedConfig.SessionSigningKey)
+	sessionFallback := parsedConfig.SessionFallback
+	if len(sessionSigningKey) == 0 {
+		// session cookies will be invalidated by a restart and will not be shared between instances
+		ctxLogger.Warn("sessionSigningKey empty, using a random one")
+		sessionSigningKey = make([]byte, 32)
+		if _, err = rand.Read(sessionSigningKey); err != nil {
+			ctxLogger.Fatal("Failed to generate sessionSigningKey", zap.Error(err))
+		}
+		if sessionFallback {
+			ctxLogger.Warn("Session fallback disabled (need a configured sessionSigningKey)")
+			sessionFallback = false
+		}
+	}
+
+	sessionCookie, fallbackCookie := defaultSessionCookie, defaultFallbackCookie
+	if cookieName := parsedConfig.SessionCookieName; cookieName != "" {
+		sessionCookie, fallbackCookie = cookieName, cookieName+"_fallback"
+	}
+	cookiePrefix := parsedConfig.SessionCookiePrefix
+	sessionCookie, fallbackCookie = cookiePrefix+sessionCookie, cookiePrefix+fallbackCookie
+
+	sessionService := newSessionService(ctxLogger, parsedConfig, sessionTimeOut, dialOptions)
 	templateService := templateclient.New(parsedConfig.TemplateServiceAddr, dialOptions, loggerGetter)
 	settingsService := sessionclient.New(parsedConfig.SettingsServiceAddr, dialOptions)
 	strengthService := strengthclient.New(parsedConfig.PasswordStrengthServiceAddr, dialOptions)
@@ -176,6 +377,40 @@ func Init(serviceName string, version string, parsedConfig parser.ParsedConfig,
 
 	staticPath := retrievePath(ctxLogger, "staticPath", parsedConfig.StaticPath, "static")
 	faviconPath := retrieveWithDefault(ctxLogger, "faviconPath", parsedConfig.FaviconPath, config.DefaultFavicon)
+	staticMaxAge := retrieveDurationWithDefault(ctxLogger, "staticMaxAge", parsedConfig.StaticMaxAge, 0)
+	staticVersion := ""
+	if parsedConfig.StaticFingerprint {
+		var err error
+		if staticVersion, err = hashStaticFolder(os.DirFS(staticPath)); err != nil {
+			ctxLogger.Warn("Failed to compute the static fingerprint, disabling it", zap.Error(err))
+		}
+	}
+	staticAssets := buildAssetManifest(ctxLogger, os.DirFS(staticPath), parsedConfig.StaticAssets, staticVersion)
+
+	iconPaths := make(map[string]string, len(parsedConfig.Icons))
+	var manifestIcons []common.ManifestIcon
+	for _, icon := range parsedConfig.Icons {
+		iconPaths[icon.Url] = icon.Path
+		if icon.Sizes != "" {
+			manifestIcons = append(manifestIcons, common.MakeManifestIcon(icon.Url, icon.Sizes, icon.Purpose))
+		}
+	}
+
+	var manifest []byte
+	if manifestName := parsedConfig.ManifestName; manifestName != "" {
+		manifest, err = common.Manifest{
+			Name:            manifestName,
+			ShortName:       parsedConfig.ManifestShortName,
+			StartUrl:        retrieveWithDefault(ctxLogger, "manifestStartUrl", parsedConfig.ManifestStartUrl, "/"),
+			Display:         retrieveWithDefault(ctxLogger, "manifestDisplay", parsedConfig.ManifestDisplay, "standalone"),
+			ThemeColor:      parsedConfig.ManifestThemeColor,
+			BackgroundColor: parsedConfig.ManifestBackgroundColor,
+			Icons:           manifestIcons,
+		}.Build()
+		if err != nil {
+			ctxLogger.Fatal("Failed to build web app manifest", zap.Error(err))
+		}
+	}
 
 	defaultPicturePath := retrieveWithDefault(
 		ctxLogger, "profileDefaultPicturePath", parsedConfig.ProfileDefaultPicturePath, staticPath+"/images/unknownuser.png",
@@ -185,13 +420,62 @@ func Init(serviceName string, version string, parsedConfig parser.ParsedConfig,
 		ctxLogger.Fatal("Can not read", zap.String("filepath", defaultPicturePath), zap.Error(err))
 	}
 
+	// 2 MB by default and resized to 256 pixels
+	pictureMaxSize := retrieveUintWithDefault(ctxLogger, "profilePictureMaxSize", parsedConfig.ProfilePictureMaxSize, 2<<20)
+	pictureMaxDim := retrieveUintWithDefault(ctxLogger, "profilePictureMaxDimension", parsedConfig.ProfilePictureMaxDim, 256)
+
+	// the blog widget used to display recent posts in profile (optional)
+	var profileBlog parser.WidgetConfig
+	if profileBlogRef := parsedConfig.ProfileBlogRef; profileBlogRef != "" {
+		var ok bool
+		if profileBlog, ok = parsedConfig.WidgetsAsMap()[profileBlogRef]; !ok || profileBlog.Kind != "blog" {
+			ctxLogger.Warn("profileBlogRef does not reference a blog widget", zap.String("widgetRef", profileBlogRef))
+			profileBlog = parser.WidgetConfig{}
+		}
+	}
+	profileBlogUrl := ""
+	if profileBlog.Kind != "" {
+		for _, widgetPage := range parsedConfig.WidgetPages {
+			if widgetPage.WidgetRef == parsedConfig.ProfileBlogRef {
+				profileBlogUrl = "/" + widgetPage.Path
+				break
+			}
+		}
+	}
+
+	deletedContentPolicy := retrieveWithDefault(ctxLogger, "deletedContentPolicy", parsedConfig.DeletedContentPolicy, config.DeletedContentAnonymize)
+	if deletedContentPolicy != config.DeletedContentAnonymize && deletedContentPolicy != config.DeletedContentDelete {
+		ctxLogger.Warn("Unknown deletedContentPolicy, content will be anonymized", zap.String("deletedContentPolicy", deletedContentPolicy))
+		deletedContentPolicy = config.DeletedContentAnonymize
+	}
+
+	accessDenied := retrieveWithDefault(ctxLogger, "accessDenied", parsedConfig.AccessDenied, config.AccessDeniedError)
+	switch accessDenied {
+	case config.AccessDeniedError, config.AccessDeniedLogin, config.AccessDeniedForbidden:
+	default:
+		ctxLogger.Warn("Unknown accessDenied, using error redirect", zap.String("accessDenied", accessDenied))
+		accessDenied = config.AccessDeniedError
+	}
+
+	accessLogLevel := retrieveWithDefault(ctxLogger, "accessLogLevel", parsedConfig.AccessLogLevel, config.AccessLogInfo)
+	switch accessLogLevel {
+	case config.AccessLogDebug, config.AccessLogInfo, config.AccessLogWarn, config.AccessLogNone:
+	default:
+		ctxLogger.Warn("Unknown accessLogLevel, using info", zap.String("accessLogLevel", accessLogLevel))
+		accessLogLevel = config.AccessLogInfo
+	}
+
 	locales := parsedConfig.Locales
 	langNumber := len(locales)
 	allLang := make([]string, 0, langNumber)
 	langPicturePaths := make(map[string]string, langNumber)
+	langDateFormats := map[string]string{}
 	for _, locale := range locales {
 		allLang = append(allLang, locale.Lang)
 		langPicturePaths[locale.Lang] = locale.PicturePath
+		if locale.DateFormat != "" {
+			langDateFormats[locale.Lang] = locale.DateFormat
+		}
 	}
 	ctxLogger.Info("Declared locales", zap.Strings("locales", allLang))
 
@@ -203,12 +487,38 @@ func Init(serviceName string, version string, parsedConfig parser.ParsedConfig,
 
 	globalConfig := &GlobalConfig{
 		Domain: domain, Port: port, AllLang: allLang, SessionTimeOut: sessionTimeOut, ServiceTimeOut: serviceTimeOut,
-		MaxMultipartMemory: maxMultipartMemory, DateFormat: dateFormat, PageSize: pageSize, ExtractSize: extractSize,
-		FeedFormat: feedFormat, FeedSize: feedSize,
+		SessionMaxLifeTime: sessionMaxLifeTime,
+		MaxMultipartMemory: maxMultipartMemory, DateFormat: dateFormat, TimeAgoLimit: timeAgoLimit, TimeZone: timeZone,
+		MaxInFlight: parsedConfig.MaxInFlight, InFlightWait: inFlightWait,
+		PageCacheTTL: pageCacheTTL, PageCacheSize: pageCacheSize, PageSize: pageSize, MaxPageSize: maxPageSize, ExtractSize: extractSize,
+		FeedFormat: feedFormat, PaginationWindow: paginationWindow, FeedSize: feedSize, TrustedProxies: parsedConfig.TrustedProxies,
+		Features: parsedConfig.Features, UserFeatures: parsedConfig.UserFeatures, SessionSigningKey: sessionSigningKey,
+		SessionFallback: sessionFallback, MaxTitleLength: maxTitleLength, MaxContentLength: maxContentLength,
+		MaxCommentLength: maxCommentLength,
+		FormGuard:        formGuard, WordFilter: wordFilter, CaptchaService: captchaService, Mailer: mailer, VerifiedActions: verifiedActions,
+		VerificationTTL: verificationTTL, VerificationResend: parsedConfig.VerificationResend, ProfilePictureMaxSize: pictureMaxSize,
+		ProfilePictureMaxDim: pictureMaxDim, ProfileBlog: profileBlog, ProfileBlogUrl: profileBlogUrl, MaxDraftSize: maxDraftSize,
+		DeletedContentPolicy: deletedContentPolicy, RolePresets: rolePresets, Highlighter: highlighter, HighlightCSS: highlightCSS,
 
 		StaticFileSystem: http.FS(os.DirFS(staticPath)),
 		FaviconPath:      faviconPath,
+		IconPaths:        iconPaths,
+		ServiceWorker:    parsedConfig.ServiceWorkerPath,
+		Manifest:         manifest,
 		Page404Url:       parsedConfig.Page404Url,
+		AccessDenied:     accessDenied,
+		HomeRedirect:     parsedConfig.HomeRedirect,
+
+		StaticMaxAge:  staticMaxAge,
+		StaticVersion: staticVersion,
+		StaticAssets:  staticAssets,
+
+		AccessLogLevel:     accessLogLevel,
+		AccessLogSkipPaths: parsedConfig.AccessLogSkipPaths,
+
+		SessionCookie:         sessionCookie,
+		SessionFallbackCookie: fallbackCookie,
+		SessionHostOnly:       cookiePrefix == parser.CookieHostPrefix,
 
 		InitCtx:        initCtx,
 		Logger:         ctxLogger,
@@ -217,6 +527,7 @@ func Init(serviceName string, version string, parsedConfig parser.ParsedConfig,
 		Tracer:         tracer,
 
 		LangPicturePaths: langPicturePaths,
+		LangDateFormats:  langDateFormats,
 		DialOptions:      dialOptions,
 		SessionService:   sessionService,
 		TemplateService:  templateService,
@@ -240,7 +551,7 @@ func (c *GlobalConfig) loadMarkdown() bool {
 		if !require(c.Logger, "markdownServiceAddr", c.MarkdownServiceAddr) {
 			return false
 		}
-		c.MarkdownService = markdownclient.New(c.MarkdownServiceAddr, c.DialOptions)
+		c.MarkdownService = markdownclient.New(c.MarkdownServiceAddr, c.DialOptions, c.LoggerGetter)
 	}
 	return true
 }
@@ -257,6 +568,28 @@ func (c *GlobalConfig) loadBlog() bool {
 	return c.loadForum() && c.loadMarkdown() && require(c.Logger, "blogServiceAddr", c.BlogServiceAddr)
 }
 
+func (c *GlobalConfig) widgetMarkdownService(widgetConfig parser.WidgetConfig) markdownservice.MarkdownService {
+	if widgetConfig.HighlightCode && c.MarkdownService != nil {
+		return c.Highlighter.Wrap(c.MarkdownService)
+	}
+	return c.MarkdownService
+}
+
+func (c *GlobalConfig) widgetPageSize(widgetConfig parser.WidgetConfig) uint64 {
+	if pageSize := widgetConfig.PageSize; pageSize != 0 {
+		return pageSize
+	}
+	return c.PageSize
+}
+
+func (c *GlobalConfig) widgetMaxPageSize(widgetConfig parser.WidgetConfig) uint64 {
+	maxPageSize := widgetConfig.MaxPageSize
+	if maxPageSize == 0 {
+		maxPageSize = c.MaxPageSize
+	}
+	return max(maxPageSize, c.widgetPageSize(widgetConfig))
+}
+
 func (c *GlobalConfig) GetLogger() log.Logger {
 	return c.Logger
 }
@@ -276,6 +609,7 @@ func (c *GlobalConfig) ExtractAuthConfig() config.AuthConfig {
 func (c *GlobalConfig) ExtractLocalesConfig() config.LocalesConfig {
 	return config.LocalesConfig{
 		Logger: c.Logger, LoggerGetter: c.LoggerGetter, Domain: c.Domain, SessionTimeOut: c.SessionTimeOut, AllLang: c.AllLang,
+		DateFormat: c.DateFormat, DateFormats: c.LangDateFormats,
 	}
 }
 
@@ -283,26 +617,58 @@ func (c *GlobalConfig) ExtractSiteConfig() config.SiteConfig {
 	return config.SiteConfig{
 		ServiceConfig: config.MakeServiceConfig(c, c.SessionService), TemplateService: c.TemplateService,
 		Domain: c.Domain, Port: c.Port, SessionTimeOut: c.SessionTimeOut, MaxMultipartMemory: c.MaxMultipartMemory,
-		StaticFileSystem: c.StaticFileSystem, FaviconPath: c.FaviconPath, LangPicturePaths: c.LangPicturePaths,
-		Page404Url: c.Page404Url,
+		StaticFileSystem: c.StaticFileSystem, FaviconPath: c.FaviconPath, IconPaths: c.IconPaths, Manifest: c.Manifest,
+		StaticMaxAge: c.StaticMaxAge, StaticVersion: c.StaticVersion, StaticAssets: c.StaticAssets,
+		ServiceWorkerPath: c.ServiceWorker, LangPicturePaths: c.LangPicturePaths,
+		Page404Url: c.Page404Url, TrustedProxies: c.TrustedProxies, Features: c.Features, UserFeatures: c.UserFeatures,
+		SessionSigningKey: c.SessionSigningKey, SessionFallback: c.SessionFallback, SessionMaxLifeTime: c.SessionMaxLifeTime,
+		TimeAgoLimit: c.TimeAgoLimit, PageCacheTTL: c.PageCacheTTL, PageCacheSize: int(c.PageCacheSize), AccessDenied: c.AccessDenied,
+		MaxInFlight: int(c.MaxInFlight), InFlightWait: c.InFlightWait, PaginationWindow: c.PaginationWindow, MaxDraftSize: c.MaxDraftSize,
+		TimeZone: c.TimeZone, HighlightCSS: c.HighlightCSS, HomeRedirect: c.HomeRedirect,
+		AccessLogLevel: c.AccessLogLevel, AccessLogSkipPaths: c.AccessLogSkipPaths, SessionCookie: c.SessionCookie,
+		SessionFallbackCookie: c.SessionFallbackCookie, SessionHostOnly: c.SessionHostOnly,
 	}
 }
 
 func (c *GlobalConfig) ExtractLoginConfig() config.LoginConfig {
-	return config.MakeServiceConfig[loginservice.LoginService](c, c.LoginService)
+	return config.LoginConfig{
+		ServiceConfig:  config.MakeServiceConfig[loginservice.LoginService](c, c.LoginService),
+		CaptchaService: c.CaptchaService,
+	}
+}
+
+func (c *GlobalConfig) ExtractVerificationConfig() config.VerificationConfig {
+	return config.VerificationConfig{
+		ServiceConfig: config.MakeServiceConfig(c, c.ProfileService),
+		Mailer:        c.Mailer, TemplateService: c.TemplateService, TokenTTL: c.VerificationTTL,
+		SigningKey: common.DeriveKey(c.SessionSigningKey, common.VerificationKeyPurpose), GatedActions: c.VerifiedActions,
+		Resend: c.VerificationResend,
+	}
 }
 
 func (c *GlobalConfig) ExtractAdminConfig() config.AdminConfig {
 	return config.AdminConfig{
 		ServiceConfig: config.MakeServiceConfig[adminservice.AdminService](c, c.RightClient),
-		UserService:   c.LoginService, ProfileService: c.ProfileService, PageSize: c.PageSize,
+		UserService:   c.LoginService, ProfileService: c.ProfileService, SessionService: c.SessionService,
+		PageSize: c.PageSize, MaxPageSize: c.MaxPageSize, DateFormat: c.DateFormat, RolePresets: c.RolePresets,
 	}
 }
 
 func (c *GlobalConfig) ExtractProfileConfig() config.ProfileConfig {
+	var blogService blogservice.BlogService
+	if profileBlog := c.ProfileBlog; profileBlog.Kind == "blog" && c.loadBlog() {
+		blogService = blogclient.New(
+			c.BlogServiceAddr, c.DialOptions, profileBlog.ObjectId, profileBlog.GroupId, c.DateFormat,
+			c.RightClient, c.ProfileService,
+		)
+	}
+
 	return config.ProfileConfig{
 		ServiceConfig: config.MakeServiceConfig(c, c.ProfileService),
-		AdminService:  c.RightClient, LoginService: c.LoginService,
+		AdminService:  c.RightClient, LoginService: c.LoginService, BlogService: blogService,
+		BlogUrl: c.ProfileBlogUrl, DeletedContentPolicy: c.DeletedContentPolicy,
+		PictureMaxSize: int64(c.ProfilePictureMaxSize), PictureMaxDim: int(c.ProfilePictureMaxDim),
+		PageSize: c.PageSize, MaxPageSize: c.MaxPageSize,
 	}
 }
 
@@ -311,13 +677,17 @@ func (c *GlobalConfig) ExtractSettingsConfig() config.SettingsConfig {
 }
 
 func (c *GlobalConfig) MakeWikiConfig(widgetConfig parser.WidgetConfig) (config.WikiConfig, bool) {
+	loaded := c.loadWiki() // before the use of c.MarkdownService
 	return config.WikiConfig{
 		ServiceConfig: config.MakeServiceConfig(c, wikiclient.New(
 			c.WikiServiceAddr, c.DialOptions, widgetConfig.ObjectId, widgetConfig.GroupId, c.DateFormat,
 			c.RightClient, c.ProfileService, c.LoggerGetter,
 		)),
-		MarkdownService: c.MarkdownService, Args: widgetConfig.Templates,
-	}, c.loadWiki()
+		MarkdownService: c.widgetMarkdownService(widgetConfig), ExtractSize: c.ExtractSize, MaxContentLength: c.MaxContentLength,
+		TitleCaseFold: widgetConfig.TitleCaseFold, TitleSpaceToUnderscore: widgetConfig.TitleSpaceToUnderscore,
+		LinkPattern: widgetConfig.LinkPattern, MissingLinkClass: widgetConfig.MissingLinkClass, Args: widgetConfig.Templates,
+		PageSize: c.widgetPageSize(widgetConfig), MaxPageSize: c.widgetMaxPageSize(widgetConfig),
+	}, loaded
 }
 
 func (c *GlobalConfig) MakeForumConfig(widgetConfig parser.WidgetConfig) (config.ForumConfig, bool) {
@@ -326,23 +696,55 @@ func (c *GlobalConfig) MakeForumConfig(widgetConfig parser.WidgetConfig) (config
 			c.ForumServiceAddr, c.DialOptions, widgetConfig.ObjectId, widgetConfig.GroupId, c.DateFormat,
 			c.RightClient, c.ProfileService, c.LoggerGetter,
 		)),
-		PageSize: c.PageSize, Args: widgetConfig.Templates,
+		PageSize: c.widgetPageSize(widgetConfig), MaxPageSize: c.widgetMaxPageSize(widgetConfig), Args: widgetConfig.Templates,
 	}, c.loadForum()
 }
 
 func (c *GlobalConfig) MakeBlogConfig(widgetConfig parser.WidgetConfig) (config.BlogConfig, bool) {
+	var pendingCommentService forumservice.CommentService
+	if pendingObjectId := widgetConfig.PendingCommentObjectId; pendingObjectId != 0 {
+		pendingCommentService = forumclient.New(
+			c.ForumServiceAddr, c.DialOptions, pendingObjectId, widgetConfig.GroupId, c.DateFormat,
+			c.RightClient, c.ProfileService, c.LoggerGetter,
+		)
+	}
+
+	loaded := c.loadBlog() // before the use of c.MarkdownService
+	var recentPostsTTL time.Duration
+	if widgetConfig.RecentPosts != 0 {
+		recentPostsTTL = retrieveDurationWithDefault(c.Logger, "recentPostsTTL", widgetConfig.RecentPostsTTL, defaultRecentPostsTTL)
+	}
+	commentSort := retrieveWithDefault(c.Logger, "commentSort", widgetConfig.CommentSort, config.CommentSortAsc)
+	if commentSort != config.CommentSortAsc && commentSort != config.CommentSortDesc {
+		c.Logger.Warn("Unknown commentSort, using oldest first", zap.String("commentSort", commentSort))
+		commentSort = config.CommentSortAsc
+	}
+	var anonymousCommentDelay time.Duration
+	if widgetConfig.AllowAnonymousComments {
+		anonymousCommentDelay = retrieveDurationWithDefault(c.Logger, "anonymousCommentDelay", widgetConfig.AnonymousCommentDelay, defaultAnonymousDelay)
+		if c.CaptchaService == nil {
+			c.Logger.Warn("Anonymous comments allowed without captcha", zap.String("widget", widgetConfig.Name))
+		}
+	}
+
 	return config.BlogConfig{
 		ServiceConfig: config.MakeServiceConfig(c, blogclient.New(
 			c.BlogServiceAddr, c.DialOptions, widgetConfig.ObjectId, widgetConfig.GroupId, c.DateFormat,
 			c.RightClient, c.ProfileService,
 		)),
-		MarkdownService: c.MarkdownService, CommentService: forumclient.New(
+		MarkdownService: c.widgetMarkdownService(widgetConfig), CommentService: forumclient.New(
 			c.ForumServiceAddr, c.DialOptions, widgetConfig.ObjectId, widgetConfig.GroupId, c.DateFormat,
 			c.RightClient, c.ProfileService, c.LoggerGetter,
 		),
-		Domain: c.Domain, Port: c.Port, DateFormat: c.DateFormat, PageSize: c.PageSize, ExtractSize: c.ExtractSize,
-		FeedFormat: c.FeedFormat, FeedSize: c.FeedSize, Args: widgetConfig.Templates,
-	}, c.loadBlog()
+		PendingComments: pendingCommentService, DateFormat: c.DateFormat, ExtractSize: c.ExtractSize,
+		PageSize: c.widgetPageSize(widgetConfig), MaxPageSize: c.widgetMaxPageSize(widgetConfig),
+		FeedFormat: c.FeedFormat, FeedSize: c.FeedSize, MaxTitleLength: c.MaxTitleLength,
+		MaxContentLength: c.MaxContentLength, MaxCommentLength: c.MaxCommentLength, FormGuard: c.FormGuard,
+		CaptchaService: c.CaptchaService, Mailer: c.Mailer, TemplateService: c.TemplateService,
+		WordFilter: c.WordFilter, RecentPosts: widgetConfig.RecentPosts, RecentPostsTTL: recentPostsTTL,
+		CommentSort: commentSort, Args: widgetConfig.Templates, AllowAnonymousComments: widgetConfig.AllowAnonymousComments,
+		AnonymousCommentDelay: anonymousCommentDelay,
+	}, loaded
 }
 
 func (c *GlobalConfig) MakeWidgetConfig(widgetConfig parser.WidgetConfig) (config.RemoteWidgetConfig, bool) {
@@ -352,6 +754,61 @@ func (c *GlobalConfig) MakeWidgetConfig(widgetConfig parser.WidgetConfig) (confi
 	)), remoteKind
 }
 
+func newMailer(logger otelzap.LoggerWithCtx, parsedConfig parser.ParsedConfig, loggerGetter log.LoggerGetter) mailservice.Mailer {
+	if parsedConfig.MailLogOnly {
+		logger.Warn("Mails are only logged")
+		return mailclient.NewLogOnly(loggerGetter)
+	}
+
+	smtpHost := parsedConfig.SmtpHost
+	if smtpHost == "" {
+		return nil
+	}
+
+	mailFrom, err := mail.ParseAddress(parsedConfig.MailFrom)
+	if err != nil {
+		logger.Error("Failed to parse mailFrom, mails are disabled", zap.Error(err))
+		return nil
+	}
+
+	smtpTLS := retrieveWithDefault(logger, "smtpTLS", parsedConfig.SmtpTLS, mailclient.TLSStart)
+	switch smtpTLS {
+	case mailclient.TLSNone, mailclient.TLSStart, mailclient.TLSImplicit:
+	default:
+		logger.Error("Unknown smtpTLS mode, mails are disabled", zap.String("smtpTLS", smtpTLS))
+		return nil
+	}
+
+	defaultPort := "587"
+	if smtpTLS == mailclient.TLSImplicit {
+		defaultPort = "465"
+	}
+	smtpPort := retrieveWithDefault(logger, "smtpPort", parsedConfig.SmtpPort, defaultPort)
+	return mailclient.New(smtpHost, smtpPort, parsedConfig.SmtpUsername, parsedConfig.SmtpPassword, mailFrom, smtpTLS)
+}
+
+func newSessionService(logger otelzap.LoggerWithCtx, parsedConfig parser.ParsedConfig, sessionTimeOut int, dialOptions []grpc.DialOption) sessionservice.SessionService {
+	switch sessionStore := parsedConfig.SessionStore; sessionStore {
+	case "", "grpc":
+		return sessionclient.New(parsedConfig.SessionServiceAddr, dialOptions)
+	case "redis":
+		if !require(logger, "redisAddr", parsedConfig.RedisAddr) {
+			logger.Fatal("Can not use redis session store")
+		}
+
+		client := redis.NewClient(&redis.Options{
+			Addr: parsedConfig.RedisAddr, Password: parsedConfig.RedisPassword, DB: parsedConfig.RedisDb,
+		})
+		if err := client.Ping(logger.Context()).Err(); err != nil {
+			logger.Fatal("Failed to ping redis", zap.Error(err))
+		}
+		return redisclient.New(client, time.Duration(sessionTimeOut)*time.Second, parsedConfig.SessionCompressThreshold)
+	default:
+		logger.Fatal("Unknown sessionStore", zap.String("sessionStore", sessionStore))
+	}
+	return nil
+}
+
 func retrieveWithDefault(logger log.Logger, name string, value string, defaultValue string) string {
 	if value == "" {
 		logger.Info(name+" empty, using default", zap.String(defaultName, defaultValue))
@@ -368,6 +825,39 @@ func retrieveUintWithDefault(logger log.Logger, name string, value uint64, defau
 	return value
 }
 
+func retrieveDurationWithDefault(logger log.Logger, name string, value string, defaultValue time.Duration) time.Duration {
+	if value == "" {
+		logger.Info(name+" empty, using default", zap.Duration(defaultName, defaultValue))
+		return defaultValue
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		logger.Warn("Failed to parse "+name+", using default", zap.Duration(defaultName, defaultValue), zap.Error(err))
+		return defaultValue
+	}
+	return duration
+}
+
+func retrieveCodesWithDefault(logger log.Logger, name string, values []string, defaultValue codes.Code) []codes.Code {
+	if len(values) == 0 {
+		logger.Info(name+" empty, using default", zap.Stringer(defaultName, defaultValue))
+		return []codes.Code{defaultValue}
+	}
+
+	res := make([]codes.Code, 0, len(values))
+	for _, value := range values {
+		var code codes.Code
+		// accept the upper case name of the code (like "UNAVAILABLE")
+		if err := code.UnmarshalJSON([]byte(strconv.Quote(value))); err != nil {
+			logger.Warn("Failed to parse a code in "+name, zap.String("code", value), zap.Error(err))
+			continue
+		}
+		res = append(res, code)
+	}
+	return res
+}
+
 func retrievePath(logger log.Logger, name string, path string, defaultPath string) string {
 	path = retrieveWithDefault(logger, name, path, defaultPath)
 	if last := len(path) - 1; path[last] == '/' {
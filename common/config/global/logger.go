@@ -0,0 +1,68 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package globalconfig
+
+import (
+	"time"
+
+	"github.com/dvaumoron/puzzleweb/common"
+	"github.com/dvaumoron/puzzleweb/common/config/parser"
+	"github.com/uptrace/opentelemetry-go-extra/otelzap"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// the listed info and warning messages are written with the debug level (errors are never downgraded)
+type downgradeCore struct {
+	zapcore.Core
+	messages common.Set[string]
+}
+
+func (c downgradeCore) With(fields []zapcore.Field) zapcore.Core {
+	return downgradeCore{Core: c.Core.With(fields), messages: c.messages}
+}
+
+func (c downgradeCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if (entry.Level == zapcore.InfoLevel || entry.Level == zapcore.WarnLevel) && c.messages.Contains(entry.Message) {
+		entry.Level = zapcore.DebugLevel
+	}
+	return c.Core.Check(entry, checked)
+}
+
+// apply the sampling and the downgrade from the configuration
+func tuneLogger(logger *otelzap.Logger, parsedConfig parser.ParsedConfig) *otelzap.Logger {
+	var options []zap.Option
+	if initial := parsedConfig.LogSampleInitial; initial != 0 {
+		// same tick as the zap production config
+		options = append(options, zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return zapcore.NewSamplerWithOptions(core, time.Second, int(initial), int(parsedConfig.LogSampleThereafter))
+		}))
+	}
+	if messages := parsedConfig.LogDebugMessages; len(messages) != 0 {
+		// wrapped last to downgrade before the sampling count
+		options = append(options, zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return downgradeCore{Core: core, messages: common.MakeSet(messages)}
+		}))
+	}
+
+	if len(options) == 0 {
+		return logger
+	}
+	return logger.WithOptions(options...)
+}
@@ -0,0 +1,88 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package globalconfig
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"net/url"
+
+	"github.com/dvaumoron/puzzleweb/common/config"
+	"github.com/dvaumoron/puzzleweb/common/log"
+	"go.uber.org/zap"
+)
+
+const staticVersionLength = 12
+
+// hash of the paths and contents of all the files, computed once at startup
+func hashStaticFolder(fsys fs.FS) (string, error) {
+	hasher := sha256.New()
+	err := fs.WalkDir(fsys, ".", func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		file, err := fsys.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		io.WriteString(hasher, path)
+		hasher.Write([]byte{0})
+		_, err = io.Copy(hasher, file)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil))[:staticVersionLength], nil
+}
+
+// map the matching paths to their url, with the version in query when the fingerprint is enabled
+func buildAssetManifest(logger log.Logger, fsys fs.FS, patterns []string, version string) map[string]string {
+	if len(patterns) == 0 {
+		return nil
+	}
+
+	suffix := ""
+	if version != "" {
+		suffix = "?" + config.StaticVersionQuery + "=" + version
+	}
+	assets := map[string]string{}
+	for _, pattern := range patterns {
+		paths, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			logger.Warn("Failed to parse static asset pattern", zap.String("pattern", pattern), zap.Error(err))
+			continue
+		}
+		if len(paths) == 0 {
+			logger.Warn("Static asset pattern without match", zap.String("pattern", pattern))
+		}
+		for _, path := range paths {
+			if info, err := fs.Stat(fsys, path); err != nil || info.IsDir() {
+				continue
+			}
+			assets[path] = "/static/" + (&url.URL{Path: path}).EscapedPath() + suffix
+		}
+	}
+	return assets
+}
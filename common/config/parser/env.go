@@ -0,0 +1,152 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	EnvPrefix     = "PUZZLEWEB_"
+	envFileSuffix = "_FILE"
+)
+
+// string fields tagged duration:"true" are checked with time.ParseDuration,
+// the ones tagged duration:"seconds" as an integer count of seconds
+const (
+	durationTag     = "duration"
+	durationSeconds = "seconds"
+)
+
+// override a top level value (not the blocks) with the environment variable named by EnvPrefix followed by
+// the name in upper snake case (like PUZZLEWEB_SESSION_SIGNING_KEY), with the _FILE suffix the variable is
+// the path of a file containing the value (for secrets), lists are comma separated
+func (frame *ParsedConfig) applyEnvOverrides() error {
+	var errs []error
+	frameValue := reflect.ValueOf(frame).Elem()
+	frameType := frameValue.Type()
+	for index := 0; index < frameType.NumField(); index++ {
+		field := frameType.Field(index)
+		name := field.Tag.Get("yaml")
+		envName := EnvPrefix + toUpperSnakeCase(name)
+		value, ok, err := lookupEnvOrFile(envName)
+		if err == nil && ok {
+			err = setFromString(frameValue.Field(index), field.Tag.Get(durationTag), value)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", envName, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func lookupEnvOrFile(envName string) (string, bool, error) {
+	if value, ok := os.LookupEnv(envName); ok {
+		return value, true, nil
+	}
+
+	path, ok := os.LookupEnv(envName + envFileSuffix)
+	if !ok {
+		return "", false, nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, err
+	}
+	// editors and secret tools often add a final new line
+	return strings.TrimRight(string(content), "\r\n"), true, nil
+}
+
+func setFromString(fieldValue reflect.Value, duration string, value string) error {
+	switch fieldValue.Kind() {
+	case reflect.String:
+		if err := checkDuration(duration, value); err != nil {
+			return err
+		}
+		fieldValue.SetString(value)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		fieldValue.SetBool(parsed)
+	case reflect.Int, reflect.Int64:
+		parsed, err := strconv.ParseInt(value, 10, fieldValue.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fieldValue.SetInt(parsed)
+	case reflect.Uint64:
+		parsed, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		fieldValue.SetUint(parsed)
+	case reflect.Slice:
+		if fieldValue.Type().Elem().Kind() != reflect.String {
+			return errors.New("blocks can not be overridden")
+		}
+		var values []string
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				values = append(values, part)
+			}
+		}
+		fieldValue.Set(reflect.ValueOf(values))
+	default:
+		return errors.New("unsupported type")
+	}
+	return nil
+}
+
+func checkDuration(duration string, value string) error {
+	if duration == "" || value == "" {
+		return nil
+	}
+	if duration == durationSeconds {
+		_, err := strconv.ParseInt(value, 10, 64)
+		return err
+	}
+	_, err := time.ParseDuration(value)
+	return err
+}
+
+// "pageCacheTTL" give "PAGE_CACHE_TTL"
+func toUpperSnakeCase(name string) string {
+	runes := []rune(name)
+	var builder strings.Builder
+	for index, r := range runes {
+		if index != 0 && unicode.IsUpper(r) {
+			previous := runes[index-1]
+			nextIsLower := index+1 < len(runes) && unicode.IsLower(runes[index+1])
+			if !unicode.IsUpper(previous) || nextIsLower {
+				builder.WriteByte('_')
+			}
+		}
+		builder.WriteRune(unicode.ToUpper(r))
+	}
+	return builder.String()
+}
@@ -0,0 +1,52 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEnvDuration(t *testing.T) {
+	t.Setenv(EnvPrefix+"STATIC_MAX_AGE", "1h")
+	config := ParsedConfig{}
+	if err := config.applyEnvOverrides(); err != nil || config.StaticMaxAge != "1h" {
+		t.Errorf("staticMaxAge not set (error %v): %q", err, config.StaticMaxAge)
+	}
+
+	t.Setenv(EnvPrefix+"STATIC_MAX_AGE", "one hour")
+	config = ParsedConfig{}
+	if err := config.applyEnvOverrides(); err == nil || !strings.Contains(err.Error(), "STATIC_MAX_AGE") {
+		t.Errorf("invalid staticMaxAge accepted (error %v)", err)
+	}
+}
+
+func TestEnvDurationInSeconds(t *testing.T) {
+	t.Setenv(EnvPrefix+"SERVICE_TIME_OUT", "5")
+	config := ParsedConfig{}
+	if err := config.applyEnvOverrides(); err != nil || config.ServiceTimeOut != "5" {
+		t.Errorf("serviceTimeOut not set (error %v): %q", err, config.ServiceTimeOut)
+	}
+
+	t.Setenv(EnvPrefix+"SERVICE_TIME_OUT", "5s")
+	config = ParsedConfig{}
+	if err := config.applyEnvOverrides(); err == nil || !strings.Contains(err.Error(), "SERVICE_TIME_OUT") {
+		t.Errorf("invalid serviceTimeOut accepted (error %v)", err)
+	}
+}
@@ -35,20 +35,145 @@ type ParsedConfig struct {
 	Port   string `hcl:"port,optional" yaml:"port"`
 
 	SessionTimeOut     int    `hcl:"sessionTimeOut,optional" yaml:"sessionTimeOut"`
-	ServiceTimeOut     string `hcl:"serviceTimeOut,optional" yaml:"serviceTimeOut"`
+	SessionMaxLifeTime int    `hcl:"sessionMaxLifeTime,optional" yaml:"sessionMaxLifeTime"` // in seconds since the login, 0 disable
+	ServiceTimeOut     string `hcl:"serviceTimeOut,optional" yaml:"serviceTimeOut" duration:"seconds"`
 	MaxMultipartMemory int64  `hcl:"maxMultipartMemory,optional" yaml:"maxMultipartMemory"`
 	DateFormat         string `hcl:"dateFormat,optional" yaml:"dateFormat"`
+	TimeAgoLimit       string `hcl:"timeAgoLimit,optional" yaml:"timeAgoLimit" duration:"true"`
+	TimeZone           string `hcl:"timeZone,optional" yaml:"timeZone"`
 	PageSize           uint64 `hcl:"pageSize,optional" yaml:"pageSize"`
+	MaxPageSize        uint64 `hcl:"maxPageSize,optional" yaml:"maxPageSize"`
+	PaginationWindow   uint64 `hcl:"paginationWindow,optional" yaml:"paginationWindow"`
 	ExtractSize        uint64 `hcl:"extractSize,optional" yaml:"extractSize"`
 	FeedFormat         string `hcl:"feedFormat,optional" yaml:"feedFormat"`
 	FeedSize           uint64 `hcl:"feedSize,optional" yaml:"feedSize"`
+	MaxTitleLength     uint64 `hcl:"maxTitleLength,optional" yaml:"maxTitleLength"`
+	MaxContentLength   uint64 `hcl:"maxContentLength,optional" yaml:"maxContentLength"`
+	MaxCommentLength   uint64 `hcl:"maxCommentLength,optional" yaml:"maxCommentLength"`
+
+	// drafts are kept in the session (loaded by every request), so their encoded size in bytes is limited
+	MaxDraftSize uint64 `hcl:"maxDraftSize,optional" yaml:"maxDraftSize"`
+
+	// requests over maxInFlight wait inFlightWait then are shed with a 503 (no limit when maxInFlight is empty)
+	MaxInFlight  uint64 `hcl:"maxInFlight,optional" yaml:"maxInFlight"`
+	InFlightWait string `hcl:"inFlightWait,optional" yaml:"inFlightWait" duration:"true"`
+
+	// cache of static pages for anonymous viewers (disabled when pageCacheTTL is empty)
+	PageCacheTTL  string `hcl:"pageCacheTTL,optional" yaml:"pageCacheTTL" duration:"true"`
+	PageCacheSize uint64 `hcl:"pageCacheSize,optional" yaml:"pageCacheSize"`
+
+	HoneypotField   string `hcl:"honeypotField,optional" yaml:"honeypotField"`
+	MinFormFillTime string `hcl:"minFormFillTime,optional" yaml:"minFormFillTime" duration:"true"`
+	MaxFormFillTime string `hcl:"maxFormFillTime,optional" yaml:"maxFormFillTime" duration:"true"`
+	FormSigningKey  string `hcl:"formSigningKey,optional" yaml:"formSigningKey"`
+
+	// blocked words in blog posts and comments, blockedWordsAction is "reject" (default) or "mask"
+	BlockedWords        []string `hcl:"blockedWords,optional" yaml:"blockedWords"`
+	BlockedWordsAction  string   `hcl:"blockedWordsAction,optional" yaml:"blockedWordsAction"`
+	BlockedWordsSpacing bool     `hcl:"blockedWordsSpacing,optional" yaml:"blockedWordsSpacing"`
+
+	// server side highlighting of code blocks, for the widgets with highlightCode (css served at /highlight.css)
+	HighlightStyle       string `hcl:"highlightStyle,optional" yaml:"highlightStyle"`
+	HighlightClassPrefix string `hcl:"highlightClassPrefix,optional" yaml:"highlightClassPrefix"`
+
+	// captcha is enabled when captchaVerifyUrl is setted
+	CaptchaVerifyUrl     string `hcl:"captchaVerifyUrl,optional" yaml:"captchaVerifyUrl"`
+	CaptchaSecret        string `hcl:"captchaSecret,optional" yaml:"captchaSecret"`
+	CaptchaSiteKey       string `hcl:"captchaSiteKey,optional" yaml:"captchaSiteKey"`
+	CaptchaResponseField string `hcl:"captchaResponseField,optional" yaml:"captchaResponseField"`
+
+	// mails are enabled when smtpHost is setted (or with mailLogOnly, for development)
+	SmtpHost     string `hcl:"smtpHost,optional" yaml:"smtpHost"`
+	SmtpPort     string `hcl:"smtpPort,optional" yaml:"smtpPort"`
+	SmtpUsername string `hcl:"smtpUsername,optional" yaml:"smtpUsername"`
+	SmtpPassword string `hcl:"smtpPassword,optional" yaml:"smtpPassword"`
+	SmtpTLS      string `hcl:"smtpTLS,optional" yaml:"smtpTLS"` // "starttls" (default), "tls" or "none"
+	MailFrom     string `hcl:"mailFrom,optional" yaml:"mailFrom"`
+	MailLogOnly  bool   `hcl:"mailLogOnly,optional" yaml:"mailLogOnly"`
+
+	// email verification is enabled when some actions ("comment", "post") are gated (needs the mails)
+	VerifiedActions    []string `hcl:"verifiedActions,optional" yaml:"verifiedActions"`
+	VerificationTTL    string   `hcl:"verificationTTL,optional" yaml:"verificationTTL" duration:"true"`
+	VerificationResend bool     `hcl:"verificationResend,optional" yaml:"verificationResend"`
+
+	RetryMaxAttempts uint64   `hcl:"retryMaxAttempts,optional" yaml:"retryMaxAttempts"`
+	RetryBackoff     string   `hcl:"retryBackoff,optional" yaml:"retryBackoff" duration:"true"`
+	RetryMaxBackoff  string   `hcl:"retryMaxBackoff,optional" yaml:"retryMaxBackoff" duration:"true"`
+	RetryCodes       []string `hcl:"retryCodes,optional" yaml:"retryCodes"`
 
 	StaticPath  string `hcl:"staticPath,optional" yaml:"staticPath"`
 	FaviconPath string `hcl:"faviconPath,optional" yaml:"faviconPath"`
 	Page404Url  string `hcl:"page404Url,optional" yaml:"page404Url"`
 
+	// Cache-Control max-age of the static files and icons (default to none), with staticFingerprint a hash of the static
+	// folder is given to the templates as StaticVersion, an url ending with ?v=StaticVersion is cached one year as immutable
+	StaticMaxAge      string `hcl:"staticMaxAge,optional" yaml:"staticMaxAge" duration:"true"`
+	StaticFingerprint bool   `hcl:"staticFingerprint,optional" yaml:"staticFingerprint"`
+
+	// glob patterns (relative to the static folder) of the files given to the templates in the Assets map,
+	// from their path to their url (fingerprinted when enabled), like {{index .Assets "css/main.css"}}
+	StaticAssets []string `hcl:"staticAssets,optional" yaml:"staticAssets"`
+
+	// when setted, "/" redirect to this local path instead of rendering the index template
+	HomeRedirect string `hcl:"homeRedirect,optional" yaml:"homeRedirect"`
+
+	// one of "error" (default), "login" or "forbidden"
+	AccessDenied string `hcl:"accessDenied,optional" yaml:"accessDenied"`
+
+	// one of "debug", "info" (default), "warn" or "none", the skipped paths are not logged (like a health check)
+	AccessLogLevel     string   `hcl:"accessLogLevel,optional" yaml:"accessLogLevel"`
+	AccessLogSkipPaths []string `hcl:"accessLogSkipPaths,optional" yaml:"accessLogSkipPaths"`
+
+	// by message and per second, the first logSampleInitial entries then one every logSampleThereafter (none when 0)
+	// (sampling disabled when logSampleInitial is 0), the listed info or warning messages are logged at the debug level
+	LogSampleInitial    uint64   `hcl:"logSampleInitial,optional" yaml:"logSampleInitial"`
+	LogSampleThereafter uint64   `hcl:"logSampleThereafter,optional" yaml:"logSampleThereafter"`
+	LogDebugMessages    []string `hcl:"logDebugMessages,optional" yaml:"logDebugMessages"`
+
+	// relative to the static folder, served at the root to control the whole site
+	ServiceWorkerPath string `hcl:"serviceWorkerPath,optional" yaml:"serviceWorkerPath"`
+
+	// the manifest is served when manifestName is setted
+	ManifestName            string `hcl:"manifestName,optional" yaml:"manifestName"`
+	ManifestShortName       string `hcl:"manifestShortName,optional" yaml:"manifestShortName"`
+	ManifestStartUrl        string `hcl:"manifestStartUrl,optional" yaml:"manifestStartUrl"`
+	ManifestDisplay         string `hcl:"manifestDisplay,optional" yaml:"manifestDisplay"`
+	ManifestThemeColor      string `hcl:"manifestThemeColor,optional" yaml:"manifestThemeColor"`
+	ManifestBackgroundColor string `hcl:"manifestBackgroundColor,optional" yaml:"manifestBackgroundColor"`
+
+	TrustedProxies []string `hcl:"trustedProxies,optional" yaml:"trustedProxies"`
+
+	// feature flags, user features can be switched in user settings
+	Features     []string `hcl:"features,optional" yaml:"features"`
+	UserFeatures []string `hcl:"userFeatures,optional" yaml:"userFeatures"`
+
 	ProfileGroupId            uint64 `hcl:"profileGroupId,optional" yaml:"profileGroupId"`
 	ProfileDefaultPicturePath string `hcl:"profileDefaultPicturePath,optional" yaml:"profileDefaultPicturePath"`
+	ProfilePictureMaxSize     uint64 `hcl:"profilePictureMaxSize,optional" yaml:"profilePictureMaxSize"`
+	ProfilePictureMaxDim      uint64 `hcl:"profilePictureMaxDimension,optional" yaml:"profilePictureMaxDimension"`
+	ProfileBlogRef            string `hcl:"profileBlogRef,optional" yaml:"profileBlogRef"`
+
+	// default policy for the content of a deleted user, "anonymize" (default) or "delete"
+	DeletedContentPolicy string `hcl:"deletedContentPolicy,optional" yaml:"deletedContentPolicy"`
+
+	// "grpc" (default, use sessionServiceAddr) or "redis"
+	SessionStore  string `hcl:"sessionStore,optional" yaml:"sessionStore"`
+	RedisAddr     string `hcl:"redisAddr,optional" yaml:"redisAddr"`
+	RedisPassword string `hcl:"redisPassword,optional" yaml:"redisPassword"`
+	RedisDb       int    `hcl:"redisDb,optional" yaml:"redisDb"`
+
+	// session value longer (in bytes) are gzipped by the redis store (0 disable compression)
+	SessionCompressThreshold int `hcl:"sessionCompressThreshold,optional" yaml:"sessionCompressThreshold"`
+
+	// sign session cookies (random when empty, so cookies do not survive a restart)
+	SessionSigningKey string `hcl:"sessionSigningKey,optional" yaml:"sessionSigningKey"`
+	// opt-in, keep the login in a signed cookie when the session store is unavailable
+	SessionFallback bool `hcl:"sessionFallback,optional" yaml:"sessionFallback"`
+	// to avoid collisions between sites of the same domain (default to "pw_session_id", the fallback cookie is named
+	// after it), the optional prefix is "__Host-" (the Domain attribute is then omitted) or "__Secure-"
+	// (the session cookies are always Secure with Path=/)
+	SessionCookieName   string `hcl:"sessionCookieName,optional" yaml:"sessionCookieName"`
+	SessionCookiePrefix string `hcl:"sessionCookiePrefix,optional" yaml:"sessionCookiePrefix"`
 
 	SessionServiceAddr          string `hcl:"sessionServiceAddr,optional" yaml:"sessionServiceAddr"`
 	TemplateServiceAddr         string `hcl:"templateServiceAddr,optional" yaml:"templateServiceAddr"`
@@ -64,7 +189,10 @@ type ParsedConfig struct {
 	WikiServiceAddr             string `hcl:"wikiServiceAddr" yaml:"wikiServiceAddr"`
 
 	Locales          []LocaleConfig          `hcl:"locale,block" yaml:"locales"`
+	Icons            []IconConfig            `hcl:"icon,block" yaml:"icons"`
 	PermissionGroups []PermissionGroupConfig `hcl:"permission,block" yaml:"permissionGroups"`
+	Roles            []RoleConfig            `hcl:"role,block" yaml:"roles"`
+	RolePresets      []RolePresetConfig      `hcl:"rolePreset,block" yaml:"rolePresets"`
 	StaticPages      []StaticPagesConfig     `hcl:"staticPages,block" yaml:"staticPages"`
 	Widgets          []WidgetConfig          `hcl:"widget,block" yaml:"widgets"`
 	WidgetPages      []WidgetPageConfig      `hcl:"widgetPage,block" yaml:"widgetPages"`
@@ -81,6 +209,15 @@ func (frame *ParsedConfig) WidgetsAsMap() map[string]WidgetConfig {
 type LocaleConfig struct {
 	Lang        string `hcl:"lang,label" yaml:"lang"`
 	PicturePath string `hcl:"picturePath" yaml:"picturePath"`
+	DateFormat  string `hcl:"dateFormat,optional" yaml:"dateFormat"`
+}
+
+// the path is relative to the static folder, icons with sizes are listed in the manifest
+type IconConfig struct {
+	Url     string `hcl:"url,label" yaml:"url"`
+	Path    string `hcl:"path" yaml:"path"`
+	Sizes   string `hcl:"sizes,optional" yaml:"sizes"`
+	Purpose string `hcl:"purpose,optional" yaml:"purpose"`
 }
 
 type PermissionGroupConfig struct {
@@ -88,6 +225,19 @@ type PermissionGroupConfig struct {
 	Id   uint64 `hcl:"groupId" yaml:"id"`
 }
 
+// created at startup when missing (an existing role is not modified)
+type RoleConfig struct {
+	Name    string   `hcl:"name,label" yaml:"name"`
+	Group   string   `hcl:"group" yaml:"group"`
+	Actions []string `hcl:"actions" yaml:"actions"`
+}
+
+// roles are written "role/group", like in the user edit form
+type RolePresetConfig struct {
+	Name  string   `hcl:"name,label" yaml:"name"`
+	Roles []string `hcl:"roles" yaml:"roles"`
+}
+
 type StaticPagesConfig struct {
 	GroupId   uint64   `hcl:"groupId" yaml:"groupId"`
 	Hidden    bool     `hcl:"hidden,optional" yaml:"hidden"`
@@ -101,6 +251,36 @@ type WidgetConfig struct {
 	GroupId     uint64   `hcl:"groupId" yaml:"groupId"`
 	ServiceAddr string   `hcl:"serviceAddr,optional" yaml:"serviceAddr"`
 	Templates   []string `hcl:"templates,optional" yaml:"templates"`
+
+	// listing widgets (blog, forum and wiki versions), default to the global values
+	PageSize    uint64 `hcl:"pageSize,optional" yaml:"pageSize"`
+	MaxPageSize uint64 `hcl:"maxPageSize,optional" yaml:"maxPageSize"`
+
+	// wiki title normalization
+	TitleCaseFold          bool `hcl:"titleCaseFold,optional" yaml:"titleCaseFold"`
+	TitleSpaceToUnderscore bool `hcl:"titleSpaceToUnderscore,optional" yaml:"titleSpaceToUnderscore"`
+
+	// wiki internal links, the pattern captures the title then an optional label (default to [[Title|label]])
+	LinkPattern      string `hcl:"linkPattern,optional" yaml:"linkPattern"`
+	MissingLinkClass string `hcl:"missingLinkClass,optional" yaml:"missingLinkClass"`
+
+	// blog and wiki, opt-in server side highlighting of code blocks
+	HighlightCode bool `hcl:"highlightCode,optional" yaml:"highlightCode"`
+
+	// blog posts added to the data of every page (0 disable), refreshed after recentPostsTTL
+	RecentPosts    uint64 `hcl:"recentPosts,optional" yaml:"recentPosts"`
+	RecentPostsTTL string `hcl:"recentPostsTTL,optional" yaml:"recentPostsTTL"`
+
+	// blog comment moderation, pending comments are kept in this other comment container (0 disable moderation)
+	PendingCommentObjectId uint64 `hcl:"pendingCommentObjectId,optional" yaml:"pendingCommentObjectId"`
+
+	// blog default comment order, "asc" (oldest first, default) or "desc", the reader can change it with ?sort=
+	CommentSort string `hcl:"commentSort,optional" yaml:"commentSort"`
+
+	// blog comments by anonymous users with a name and an optional email (captcha advised),
+	// one comment by client ip during anonymousCommentDelay (default to 1m)
+	AllowAnonymousComments bool   `hcl:"allowAnonymousComments,optional" yaml:"allowAnonymousComments"`
+	AnonymousCommentDelay  string `hcl:"anonymousCommentDelay,optional" yaml:"anonymousCommentDelay"`
 }
 
 type WidgetPageConfig struct {
@@ -108,6 +288,8 @@ type WidgetPageConfig struct {
 	WidgetRef string `hcl:"widgetRef" yaml:"widgetRef"`
 }
 
+// a path ending with ".hcl" is decoded as hcl, any other (".yaml", ".json", ...) as yaml (json being a subset of yaml),
+// then the environment overrides are applied (see applyEnvOverrides)
 func ParseConfig(path string) (ParsedConfig, error) {
 	var err error
 	var frameConfig ParsedConfig
@@ -120,6 +302,12 @@ func ParseConfig(path string) (ParsedConfig, error) {
 			err = yaml.Unmarshal(frameConfigBody, &frameConfig)
 		}
 	}
+	if err == nil {
+		err = frameConfig.applyEnvOverrides()
+	}
+	if err == nil {
+		err = frameConfig.Validate()
+	}
 	return frameConfig, err
 }
 
@@ -0,0 +1,151 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	adminservice "github.com/dvaumoron/puzzleweb/admin/service"
+	"github.com/dvaumoron/puzzleweb/common"
+)
+
+type configChecker struct {
+	errs []error
+}
+
+func (checker *configChecker) require(place string, name string, value string) {
+	if value == "" {
+		checker.errs = append(checker.errs, fmt.Errorf("%s%s is required", place, name))
+	}
+}
+
+func (checker *configChecker) fail(place string, format string, args ...any) {
+	checker.errs = append(checker.errs, errors.New(place+fmt.Sprintf(format, args...)))
+}
+
+const (
+	CookieHostPrefix   = "__Host-"
+	CookieSecurePrefix = "__Secure-"
+)
+
+// token as defined by RFC 7230 (required for a cookie name)
+func isToken(value string) bool {
+	for index := 0; index < len(value); index++ {
+		if char := value[index]; char <= ' ' || char >= 0x7F || strings.IndexByte("()<>@,;:\\\"/[]?={}", char) != -1 {
+			return false
+		}
+	}
+	return value != ""
+}
+
+func blockPlace(blockName string, index int) string {
+	return blockName + "[" + strconv.Itoa(index) + "]: "
+}
+
+// the yaml (and json) decoding does not enforce the attributes required by the hcl one,
+// all the problems are reported together
+func (frame *ParsedConfig) Validate() error {
+	var checker configChecker
+	checker.require("", "forumServiceAddr", frame.ForumServiceAddr)
+	checker.require("", "markdownServiceAddr", frame.MarkdownServiceAddr)
+	checker.require("", "blogServiceAddr", frame.BlogServiceAddr)
+	checker.require("", "wikiServiceAddr", frame.WikiServiceAddr)
+	if homeRedirect := frame.HomeRedirect; homeRedirect != "" {
+		if !common.IsLocalPath(homeRedirect) {
+			checker.fail("", "homeRedirect must be a local path")
+		} else if parsed, _ := url.Parse(homeRedirect); parsed.Path == "/" {
+			checker.fail("", "homeRedirect can not target the home page")
+		}
+	}
+	// the redirect helpers replace a target outside the site by "/"
+	if page404Url := frame.Page404Url; page404Url != "" && !common.IsLocalPath(page404Url) {
+		checker.fail("", "page404Url must be a local path")
+	}
+
+	if name := frame.SessionCookieName; name != "" {
+		if !isToken(name) {
+			checker.fail("", "sessionCookieName %q is not a valid cookie name", name)
+		} else if strings.HasPrefix(name, "__") {
+			checker.fail("", "sessionCookieName can not start with \"__\" (use sessionCookiePrefix)")
+		}
+	}
+	if prefix := frame.SessionCookiePrefix; prefix != "" && prefix != CookieHostPrefix && prefix != CookieSecurePrefix {
+		checker.fail("", "sessionCookiePrefix must be %q or %q", CookieHostPrefix, CookieSecurePrefix)
+	}
+
+	for index, locale := range frame.Locales {
+		place := blockPlace("locales", index)
+		checker.require(place, "lang", locale.Lang)
+		checker.require(place, "picturePath", locale.PicturePath)
+	}
+	for index, icon := range frame.Icons {
+		place := blockPlace("icons", index)
+		checker.require(place, "url", icon.Url)
+		checker.require(place, "path", icon.Path)
+	}
+	for index, group := range frame.PermissionGroups {
+		checker.require(blockPlace("permissionGroups", index), "name", group.Name)
+	}
+	for index, role := range frame.Roles {
+		place := blockPlace("roles", index)
+		checker.require(place, "name", role.Name)
+		checker.require(place, "group", role.Group)
+		for _, action := range role.Actions {
+			if !slices.Contains(adminservice.AllActions, action) {
+				checker.fail(place, "unknown action %q", action)
+			}
+		}
+	}
+	for index, preset := range frame.RolePresets {
+		place := blockPlace("rolePresets", index)
+		checker.require(place, "name", preset.Name)
+		if len(preset.Roles) == 0 {
+			checker.fail(place, "roles is required")
+		}
+	}
+	for index, pageGroup := range frame.StaticPages {
+		if len(pageGroup.Locations) == 0 {
+			checker.fail(blockPlace("staticPages", index), "locations is required")
+		}
+	}
+
+	widgetNames := make(map[string]struct{}, len(frame.Widgets))
+	for index, widget := range frame.Widgets {
+		place := blockPlace("widgets", index)
+		checker.require(place, "name", widget.Name)
+		checker.require(place, "kind", widget.Kind)
+		if _, duplicate := widgetNames[widget.Name]; duplicate {
+			checker.fail(place, "duplicate widget name %q", widget.Name)
+		}
+		widgetNames[widget.Name] = struct{}{}
+	}
+	for index, widgetPage := range frame.WidgetPages {
+		place := blockPlace("widgetPages", index)
+		checker.require(place, "path", widgetPage.Path)
+		if _, ok := widgetNames[widgetPage.WidgetRef]; !ok {
+			checker.fail(place, "unknown widgetRef %q", widgetPage.WidgetRef)
+		}
+	}
+	return errors.Join(checker.errs...)
+}
@@ -0,0 +1,49 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func makeValidConfig() ParsedConfig {
+	return ParsedConfig{
+		ForumServiceAddr: "forum:50051", MarkdownServiceAddr: "markdown:50051", BlogServiceAddr: "blog:50051",
+		WikiServiceAddr: "wiki:50051",
+	}
+}
+
+func TestValidatePage404Url(t *testing.T) {
+	for _, page404Url := range []string{"", "/", "/notFound"} {
+		config := makeValidConfig()
+		config.Page404Url = page404Url
+		if err := config.Validate(); err != nil {
+			t.Errorf("page404Url %q rejected: %v", page404Url, err)
+		}
+	}
+
+	for _, page404Url := range []string{"https://host/404", "//host", "notFound"} {
+		config := makeValidConfig()
+		config.Page404Url = page404Url
+		if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "page404Url") {
+			t.Errorf("page404Url %q accepted (error %v)", page404Url, err)
+		}
+	}
+}
@@ -59,6 +59,14 @@ func MapToValueSlice[K comparable, V any](objects map[K]V) []V {
 	return res
 }
 
+func MapToKeySlice[K comparable, V any](objects map[K]V) []K {
+	res := make([]K, 0, len(objects))
+	for key := range objects {
+		res = append(res, key)
+	}
+	return res
+}
+
 type Stack[T any] struct {
 	inner []T
 }
@@ -0,0 +1,83 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package common
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// keys in the gin context
+const (
+	DateFormatName   = "DateFormat" // format of the current locale
+	TimeAgoLimitName = "TimeAgoLimit"
+	TimeZoneName     = "TimeZone" // *time.Location of the user (or of the site)
+)
+
+const defaultDateFormat = "2/1/2006 15:04:05"
+
+const day = 24 * time.Hour
+
+// Key is a message key (translated by the templates with Count), it is empty beyond
+// the configured limit and Date (always filled) should be displayed instead
+type RelativeTime struct {
+	Key   string
+	Count int64
+	Date  string
+}
+
+// server time zone when none is setted
+func GetTimeZone(c *gin.Context) *time.Location {
+	if location, _ := c.Value(TimeZoneName).(*time.Location); location != nil {
+		return location
+	}
+	return time.Local
+}
+
+// format according to the conventions of the current locale, in the time zone of the user
+func FormatDate(t time.Time, c *gin.Context) string {
+	dateFormat := c.GetString(DateFormatName)
+	if dateFormat == "" {
+		dateFormat = defaultDateFormat
+	}
+	return t.In(GetTimeZone(c)).Format(dateFormat)
+}
+
+func TimeAgo(t time.Time, c *gin.Context) RelativeTime {
+	res := RelativeTime{Date: FormatDate(t, c)}
+	elapsed := time.Since(t)
+	if limit := c.GetDuration(TimeAgoLimitName); limit != 0 && elapsed > limit {
+		return res
+	}
+
+	switch {
+	case elapsed < time.Minute:
+		res.Key = "TimeJustNow"
+	case elapsed < time.Hour:
+		res.Key, res.Count = "TimeMinutesAgo", int64(elapsed/time.Minute)
+	case elapsed < day:
+		res.Key, res.Count = "TimeHoursAgo", int64(elapsed/time.Hour)
+	case elapsed < 2*day:
+		res.Key = "TimeYesterday"
+	default:
+		res.Key, res.Count = "TimeDaysAgo", int64(elapsed/day)
+	}
+	return res
+}
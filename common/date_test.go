@@ -0,0 +1,83 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package common
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func makeDateContext(dateFormat string, location *time.Location, limit time.Duration) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Set(DateFormatName, dateFormat)
+	c.Set(TimeZoneName, location)
+	c.Set(TimeAgoLimitName, limit)
+	return c
+}
+
+func TestTimeAgo(t *testing.T) {
+	c := makeDateContext("", time.UTC, 0)
+	now := time.Now()
+	tests := []struct {
+		elapsed time.Duration
+		key     string
+		count   int64
+	}{
+		{10 * time.Second, "TimeJustNow", 0},
+		{5 * time.Minute, "TimeMinutesAgo", 5},
+		{3 * time.Hour, "TimeHoursAgo", 3},
+		{30 * time.Hour, "TimeYesterday", 0},
+		{5 * day, "TimeDaysAgo", 5},
+	}
+	for _, tt := range tests {
+		if got := TimeAgo(now.Add(-tt.elapsed), c); got.Key != tt.key || got.Count != tt.count || got.Date == "" {
+			t.Errorf("TimeAgo(-%v) = %+v, want %s and %d", tt.elapsed, got, tt.key, tt.count)
+		}
+	}
+}
+
+func TestTimeAgoLocales(t *testing.T) {
+	created := time.Date(2023, 3, 4, 22, 30, 0, 0, time.UTC)
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skip("no time zone database :", err)
+	}
+
+	tests := []struct {
+		name       string
+		dateFormat string
+		location   *time.Location
+		want       string
+	}{
+		{"default", "", time.UTC, "4/3/2023 22:30:00"},
+		{"en", "01/02/2006 3:04 PM", time.UTC, "03/04/2023 10:30 PM"},
+		{"fr", "02/01/2006 15:04", paris, "04/03/2023 23:30"},
+		{"ja", "2006年1月2日 15:04", time.FixedZone("JST", 9*60*60), "2023年3月5日 07:30"},
+	}
+	for _, tt := range tests {
+		// beyond the limit, only the date is displayed
+		got := TimeAgo(created, makeDateContext(tt.dateFormat, tt.location, time.Hour))
+		if got.Key != "" || got.Date != tt.want {
+			t.Errorf("%s : got %+v, want the date %q without key", tt.name, got, tt.want)
+		}
+	}
+}
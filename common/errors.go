@@ -20,6 +20,7 @@ package common
 
 import (
 	"errors"
+	"net/http"
 	"strings"
 
 	"github.com/dvaumoron/puzzleweb/common/log"
@@ -40,38 +41,114 @@ const (
 
 // error displayed to user
 const (
-	ErrorBadRoleNameKey          = "ErrorBadRoleName"
-	ErrorBaseVersionKey          = "BaseVersionOutdated"
-	ErrorEmptyCommentKey         = "EmptyComment"
-	ErrorEmptyLoginKey           = "EmptyLogin"
-	ErrorEmptyPasswordKey        = "EmptyPassword"
-	ErrorExistingLoginKey        = "ExistingLogin"
-	ErrorNotAuthorizedKey        = "ErrorNotAuthorized"
-	ErrorTechnicalKey            = "ErrorTechnicalProblem"
-	ErrorUpdateKey               = "ErrorUpdate"
-	ErrorWeakPasswordKey         = "WeakPassword"
-	ErrorWrongConfirmPasswordKey = "WrongConfirmPassword"
-	ErrorWrongLangKey            = "WrongLang"
-	ErrorWrongLoginKey           = "WrongLogin"
+	ErrorBadRoleNameKey            = "ErrorBadRoleName"
+	ErrorBaseVersionKey            = "BaseVersionOutdated"
+	ErrorBlockedWordKey            = "BlockedWord"
+	ErrorCaptchaFailedKey          = "CaptchaFailed"
+	ErrorCommentTooLongKey         = "CommentTooLong"
+	ErrorCommentTooSoonKey         = "CommentTooSoon"
+	ErrorContentTooLongKey         = "ContentTooLong"
+	ErrorDraftTooBigKey            = "DraftTooBig"
+	ErrorEmailNotVerifiedKey       = "EmailNotVerified"
+	ErrorEmptyCommentKey           = "EmptyComment"
+	ErrorEmptyLoginKey             = "EmptyLogin"
+	ErrorEmptyNameKey              = "EmptyName"
+	ErrorEmptyPasswordKey          = "EmptyPassword"
+	ErrorExistingLoginKey          = "ExistingLogin"
+	ErrorNameTooLongKey            = "NameTooLong"
+	ErrorNotAuthorizedKey          = "ErrorNotAuthorized"
+	ErrorNotFoundKey               = "ErrorNotFound"
+	ErrorPictureTooBigKey          = "PictureTooBig"
+	ErrorSessionListUnsupportedKey = "SessionListUnsupported"
+	ErrorTechnicalKey              = "ErrorTechnicalProblem"
+	ErrorTitleTooLongKey           = "TitleTooLong"
+	ErrorUnknownActionKey          = "UnknownAction"
+	ErrorUnknownRolePresetKey      = "UnknownRolePreset"
+	ErrorUpdateKey                 = "ErrorUpdate"
+	ErrorWeakPasswordKey           = "WeakPassword"
+	ErrorWrongConfirmPasswordKey   = "WrongConfirmPassword"
+	ErrorWrongConfirmationKey      = "WrongConfirmation"
+	ErrorWrongEmailKey             = "WrongEmail"
+	ErrorWrongImportFormatKey      = "WrongImportFormat"
+	ErrorWrongLangKey              = "WrongLang"
+	ErrorWrongLoginKey             = "WrongLogin"
+	ErrorWrongPictureFormatKey     = "WrongPictureFormat"
+	ErrorWrongTimeZoneKey          = "WrongTimeZone"
+	ErrorWrongVerificationKey      = "WrongVerification"
 )
 
 const originalErrorMsg = "Original error"
 
+// result of DefaultErrorRedirect with ErrorNotAuthorizedKey
+const notAuthorizedTarget = PathQueryError + ErrorNotAuthorizedKey
+
+var displayedErrorKeys = MakeSet([]string{
+	ErrorBadRoleNameKey, ErrorBaseVersionKey, ErrorBlockedWordKey, ErrorCaptchaFailedKey, ErrorCommentTooLongKey, ErrorCommentTooSoonKey,
+	ErrorContentTooLongKey, ErrorDraftTooBigKey, ErrorEmailNotVerifiedKey, ErrorEmptyCommentKey, ErrorEmptyLoginKey, ErrorEmptyNameKey,
+	ErrorEmptyPasswordKey, ErrorExistingLoginKey, ErrorNameTooLongKey, ErrorNotAuthorizedKey, ErrorNotFoundKey, ErrorPictureTooBigKey, ErrorSessionListUnsupportedKey, ErrorTechnicalKey, ErrorTitleTooLongKey,
+	ErrorUnknownActionKey, ErrorUnknownRolePresetKey, ErrorUpdateKey, ErrorWeakPasswordKey, ErrorWrongConfirmPasswordKey,
+	ErrorWrongConfirmationKey, ErrorWrongEmailKey, ErrorWrongImportFormatKey, ErrorWrongLangKey, ErrorWrongLoginKey, ErrorWrongPictureFormatKey,
+	ErrorWrongTimeZoneKey, ErrorWrongVerificationKey,
+})
+
 var (
-	ErrBadRoleName   = errors.New(ErrorBadRoleNameKey)
-	ErrBaseVersion   = errors.New(ErrorBaseVersionKey)
-	ErrEmptyComment  = errors.New(ErrorEmptyCommentKey)
-	ErrEmptyLogin    = errors.New(ErrorEmptyLoginKey)
-	ErrEmptyPassword = errors.New(ErrorEmptyPasswordKey)
-	ErrExistingLogin = errors.New(ErrorExistingLoginKey)
-	ErrNotAuthorized = errors.New(ErrorNotAuthorizedKey)
-	ErrTechnical     = errors.New(ErrorTechnicalKey)
-	ErrUpdate        = errors.New(ErrorUpdateKey)
-	ErrWeakPassword  = errors.New(ErrorWeakPasswordKey)
-	ErrWrongConfirm  = errors.New(ErrorWrongConfirmPasswordKey)
-	ErrWrongLogin    = errors.New(ErrorWrongLoginKey)
+	ErrBadRoleName            error = NewAppError(ErrorBadRoleNameKey, http.StatusBadRequest)
+	ErrBaseVersion            error = NewAppError(ErrorBaseVersionKey, http.StatusConflict)
+	ErrBlockedWord            error = NewAppError(ErrorBlockedWordKey, http.StatusBadRequest)
+	ErrCaptchaFailed          error = NewAppError(ErrorCaptchaFailedKey, http.StatusBadRequest)
+	ErrCommentTooLong         error = NewAppError(ErrorCommentTooLongKey, http.StatusBadRequest)
+	ErrCommentTooSoon         error = NewAppError(ErrorCommentTooSoonKey, http.StatusTooManyRequests)
+	ErrContentTooLong         error = NewAppError(ErrorContentTooLongKey, http.StatusBadRequest)
+	ErrDraftTooBig            error = NewAppError(ErrorDraftTooBigKey, http.StatusRequestEntityTooLarge)
+	ErrEmailNotVerified       error = NewAppError(ErrorEmailNotVerifiedKey, http.StatusForbidden)
+	ErrEmptyComment           error = NewAppError(ErrorEmptyCommentKey, http.StatusBadRequest)
+	ErrEmptyLogin             error = NewAppError(ErrorEmptyLoginKey, http.StatusBadRequest)
+	ErrEmptyName              error = NewAppError(ErrorEmptyNameKey, http.StatusBadRequest)
+	ErrEmptyPassword          error = NewAppError(ErrorEmptyPasswordKey, http.StatusBadRequest)
+	ErrExistingLogin          error = NewAppError(ErrorExistingLoginKey, http.StatusConflict)
+	ErrNameTooLong            error = NewAppError(ErrorNameTooLongKey, http.StatusBadRequest)
+	ErrNotAuthorized          error = NewAppError(ErrorNotAuthorizedKey, http.StatusForbidden)
+	ErrNotFound               error = NewAppError(ErrorNotFoundKey, http.StatusNotFound)
+	ErrPictureTooBig          error = NewAppError(ErrorPictureTooBigKey, http.StatusRequestEntityTooLarge)
+	ErrSessionListUnsupported error = NewAppError(ErrorSessionListUnsupportedKey, http.StatusNotImplemented)
+	ErrTechnical              error = NewAppError(ErrorTechnicalKey, http.StatusInternalServerError)
+	ErrTitleTooLong           error = NewAppError(ErrorTitleTooLongKey, http.StatusBadRequest)
+	ErrUnknownAction          error = NewAppError(ErrorUnknownActionKey, http.StatusBadRequest)
+	ErrUnknownRolePreset      error = NewAppError(ErrorUnknownRolePresetKey, http.StatusBadRequest)
+	ErrUpdate                 error = NewAppError(ErrorUpdateKey, http.StatusInternalServerError)
+	ErrWeakPassword           error = NewAppError(ErrorWeakPasswordKey, http.StatusBadRequest)
+	ErrWrongConfirm           error = NewAppError(ErrorWrongConfirmPasswordKey, http.StatusBadRequest)
+	ErrWrongConfirmation      error = NewAppError(ErrorWrongConfirmationKey, http.StatusBadRequest)
+	ErrWrongEmail             error = NewAppError(ErrorWrongEmailKey, http.StatusBadRequest)
+	ErrWrongLogin             error = NewAppError(ErrorWrongLoginKey, http.StatusUnauthorized)
+	ErrWrongPictureFormat     error = NewAppError(ErrorWrongPictureFormatKey, http.StatusUnsupportedMediaType)
+	ErrWrongTimeZone          error = NewAppError(ErrorWrongTimeZoneKey, http.StatusBadRequest)
+	ErrWrongVerification      error = NewAppError(ErrorWrongVerificationKey, http.StatusBadRequest)
 )
 
+// error with a localization key (the message) and the http status to respond with
+type AppError struct {
+	Key    string
+	Status int
+}
+
+func NewAppError(key string, status int) *AppError {
+	return &AppError{Key: key, Status: status}
+}
+
+func (err *AppError) Error() string {
+	return err.Key
+}
+
+// internal server error when err is not an AppError
+func ErrorStatus(err error) int {
+	var appErr *AppError
+	if errors.As(err, &appErr) {
+		return appErr.Status
+	}
+	return http.StatusInternalServerError
+}
+
 func LogOriginalError(logger log.Logger, err error) {
 	logger.Warn(originalErrorMsg, zap.Error(err))
 }
@@ -86,11 +163,7 @@ func DefaultErrorRedirect(logger log.Logger, errorMsg string) string {
 }
 
 func FilterErrorMsg(logger log.Logger, errorMsg string) string {
-	if errorMsg == ErrorBadRoleNameKey || errorMsg == ErrorBaseVersionKey || errorMsg == ErrorEmptyCommentKey ||
-		errorMsg == ErrorEmptyLoginKey || errorMsg == ErrorEmptyPasswordKey || errorMsg == ErrorExistingLoginKey ||
-		errorMsg == ErrorNotAuthorizedKey || errorMsg == ErrorTechnicalKey || errorMsg == ErrorUpdateKey ||
-		errorMsg == ErrorWeakPasswordKey || errorMsg == ErrorWrongConfirmPasswordKey || errorMsg == ErrorWrongLangKey ||
-		errorMsg == ErrorWrongLoginKey {
+	if displayedErrorKeys.Contains(errorMsg) {
 		return errorMsg
 	}
 	logger.Error(originalErrorMsg, zap.String(ErrorKey, errorMsg))
@@ -0,0 +1,30 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package common
+
+import "github.com/gin-gonic/gin"
+
+const FeaturesName = "Features"
+
+// features must have been resolved for the request (done in a site middleware)
+func FeatureEnabled(c *gin.Context, name string) bool {
+	featuresUntyped, _ := c.Get(FeaturesName)
+	features, _ := featuresUntyped.(map[string]bool)
+	return features[name]
+}
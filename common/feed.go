@@ -0,0 +1,70 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package common
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gorilla/feeds"
+)
+
+var errFeedFormat = errors.New("unrecognized feed format")
+
+type FeedItem struct {
+	Title       string
+	Link        string
+	Description string
+	Author      string
+	Created     time.Time
+}
+
+// dates are emitted with the offset of location
+func BuildFeed(feedTitle string, feedLink string, items []FeedItem, feedFormat string, location *time.Location) ([]byte, error) {
+	feedData := feeds.Feed{
+		Title:   feedTitle,
+		Link:    &feeds.Link{Href: feedLink},
+		Created: time.Now().In(location),
+		Items:   make([]*feeds.Item, 0, len(items)),
+	}
+
+	for _, item := range items {
+		feedData.Items = append(feedData.Items, &feeds.Item{
+			Title:       item.Title,
+			Link:        &feeds.Link{Href: item.Link},
+			Description: item.Description,
+			Author:      &feeds.Author{Name: item.Author},
+			Created:     item.Created.In(location),
+		})
+	}
+
+	data := ""
+	var err error
+	switch feedFormat {
+	case "atom":
+		data, err = feedData.ToAtom()
+	case "json":
+		data, err = feedData.ToJSON()
+	case "rss":
+		data, err = feedData.ToRss()
+	default:
+		return nil, errFeedFormat
+	}
+	return []byte(data), err
+}
@@ -0,0 +1,76 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package common
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildFeed(t *testing.T) {
+	location := time.FixedZone("UTC+2", 2*60*60)
+	items := []FeedItem{{
+		Title: "First post", Link: "https://example.com/blog/view/1", Description: "desc", Author: "bob",
+		Created: time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC),
+	}}
+
+	for _, format := range []string{"atom", "rss"} {
+		data, err := BuildFeed("Blog", "https://example.com/blog", items, format, location)
+		if err != nil {
+			t.Fatalf("%s : %v", format, err)
+		}
+		if err = xml.Unmarshal(data, new(struct{})); err != nil {
+			t.Errorf("%s is not valid xml : %v", format, err)
+		}
+		content := string(data)
+		if !strings.Contains(content, "First post") || !strings.Contains(content, "https://example.com/blog/view/1") {
+			t.Errorf("%s misses the item : %s", format, content)
+		}
+	}
+
+	data, err := BuildFeed("Blog", "https://example.com/blog", items, "json", location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var jsonFeed struct {
+		Items []struct {
+			Title         string `json:"title"`
+			Url           string `json:"url"`
+			DatePublished string `json:"date_published"`
+		} `json:"items"`
+	}
+	if err = json.Unmarshal(data, &jsonFeed); err != nil {
+		t.Fatal(err)
+	}
+	if len(jsonFeed.Items) != 1 || jsonFeed.Items[0].Title != "First post" {
+		t.Fatalf("unexpected items : %+v", jsonFeed.Items)
+	}
+	// dates use the offset of the location
+	if published := jsonFeed.Items[0].DatePublished; !strings.HasSuffix(published, "+02:00") {
+		t.Errorf("got the date %q, want the +02:00 offset", published)
+	}
+
+	if _, err = BuildFeed("Blog", "https://example.com/blog", items, "csv", location); !errors.Is(err, errFeedFormat) {
+		t.Errorf("got %v, want errFeedFormat", err)
+	}
+}
@@ -0,0 +1,83 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package common
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	FormTimestampName = "FormTimestamp"
+	HoneypotFieldName = "HoneypotField"
+)
+
+// anti-bot checks for form without captcha
+type FormGuard struct {
+	HoneypotField string
+	MinFillTime   time.Duration
+	MaxFillTime   time.Duration // zero for no limit
+	SigningKey    []byte
+}
+
+// add to data what the form template need
+func (g FormGuard) InitForm(data gin.H) {
+	if g.HoneypotField != "" {
+		data[HoneypotFieldName] = g.HoneypotField
+	}
+	if g.MinFillTime != 0 {
+		data[FormTimestampName] = g.signTimestamp(time.Now())
+	}
+}
+
+// return false when the submission look automated
+func (g FormGuard) Check(c *gin.Context) bool {
+	if g.HoneypotField != "" && c.PostForm(g.HoneypotField) != "" {
+		return false
+	}
+	if g.MinFillTime != 0 {
+		formTime, ok := g.verifyTimestamp(c.PostForm(FormTimestampName))
+		return ok && g.checkFillTime(time.Since(formTime))
+	}
+	return true
+}
+
+// a negative fill time come from a timestamp in the future
+func (g FormGuard) checkFillTime(fillTime time.Duration) bool {
+	return fillTime >= g.MinFillTime && (g.MaxFillTime == 0 || fillTime <= g.MaxFillTime)
+}
+
+func (g FormGuard) signTimestamp(t time.Time) string {
+	return SignValue(g.SigningKey, strconv.FormatInt(t.Unix(), 10))
+}
+
+func (g FormGuard) verifyTimestamp(signed string) (time.Time, bool) {
+	timestamp, ok := VerifySignedValue(g.SigningKey, signed)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	unixTime, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(unixTime, 0), true
+}
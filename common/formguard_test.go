@@ -0,0 +1,56 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package common
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func checkFormAt(guard FormGuard, formTime time.Time) bool {
+	form := url.Values{FormTimestampName: {guard.signTimestamp(formTime)}}
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+	c.Request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return guard.Check(c)
+}
+
+func TestFormGuardFillTime(t *testing.T) {
+	guard := FormGuard{MinFillTime: 5 * time.Second, MaxFillTime: time.Hour, SigningKey: []byte("test key")}
+	now := time.Now()
+	tests := []struct {
+		formTime time.Time
+		want     bool
+	}{
+		{now, false},                     // too fast
+		{now.Add(-time.Minute), true},    // in range
+		{now.Add(-2 * time.Hour), false}, // too old
+		{now.Add(time.Hour), false},      // in the future
+	}
+	for _, tt := range tests {
+		if got := checkFormAt(guard, tt.formTime); got != tt.want {
+			t.Errorf("Check(%v) = %v, want %v", now.Sub(tt.formTime), got, tt.want)
+		}
+	}
+}
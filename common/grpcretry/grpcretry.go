@@ -0,0 +1,85 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package grpcretry
+
+import (
+	"context"
+	"time"
+
+	"github.com/dvaumoron/puzzleweb/common"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type Policy struct {
+	MaxAttempts uint64
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+	Codes       []codes.Code
+}
+
+// marker option, a call is only retried when it is present
+type retryCallOption struct {
+	grpc.EmptyCallOption
+}
+
+// only for idempotent call (mutation should not be retried blindly)
+func WithRetry() grpc.CallOption {
+	return retryCallOption{}
+}
+
+func UnaryClientInterceptor(policy Policy) grpc.UnaryClientInterceptor {
+	retryableCodes := common.MakeSet(policy.Codes)
+	return func(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if policy.MaxAttempts < 2 || !hasRetryOption(opts) {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		backoff := policy.Backoff
+		for attempt := uint64(1); ; attempt++ {
+			err := invoker(ctx, method, req, reply, cc, opts...)
+			if err == nil || attempt >= policy.MaxAttempts || !retryableCodes.Contains(status.Code(err)) {
+				return err
+			}
+
+			timer := time.NewTimer(backoff)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return err
+			case <-timer.C:
+			}
+
+			backoff *= 2
+			if maxBackoff := policy.MaxBackoff; maxBackoff != 0 && backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+		}
+	}
+}
+
+func hasRetryOption(opts []grpc.CallOption) bool {
+	for _, opt := range opts {
+		if _, ok := opt.(retryCallOption); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package grpcretry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fail with the given codes, then succeed
+func makeInvoker(calls *int, failures ...codes.Code) grpc.UnaryInvoker {
+	return func(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		*calls++
+		if *calls <= len(failures) {
+			return status.Error(failures[*calls-1], "failure")
+		}
+		return nil
+	}
+}
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	policy := Policy{MaxAttempts: 3, Backoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond, Codes: []codes.Code{codes.Unavailable}}
+	interceptor := UnaryClientInterceptor(policy)
+	ctx := context.Background()
+	tests := []struct {
+		name      string
+		failures  []codes.Code
+		opts      []grpc.CallOption
+		wantCalls int
+		wantCode  codes.Code
+	}{
+		{"success", nil, []grpc.CallOption{WithRetry()}, 1, codes.OK},
+		{"retried", []codes.Code{codes.Unavailable, codes.Unavailable}, []grpc.CallOption{WithRetry()}, 3, codes.OK},
+		{"attempts exhausted", []codes.Code{codes.Unavailable, codes.Unavailable, codes.Unavailable}, []grpc.CallOption{WithRetry()}, 3, codes.Unavailable},
+		{"not retryable", []codes.Code{codes.InvalidArgument}, []grpc.CallOption{WithRetry()}, 1, codes.InvalidArgument},
+		{"without option", []codes.Code{codes.Unavailable}, nil, 1, codes.Unavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := interceptor(ctx, "/test", nil, nil, nil, makeInvoker(&calls, tt.failures...), tt.opts...)
+			if calls != tt.wantCalls || status.Code(err) != tt.wantCode {
+				t.Errorf("got %d calls and %v, want %d calls and %v", calls, status.Code(err), tt.wantCalls, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestUnaryClientInterceptorCanceled(t *testing.T) {
+	interceptor := UnaryClientInterceptor(Policy{MaxAttempts: 5, Backoff: time.Hour, Codes: []codes.Code{codes.Unavailable}})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	calls := 0
+	failures := []codes.Code{codes.Unavailable, codes.Unavailable}
+	if err := interceptor(ctx, "/test", nil, nil, nil, makeInvoker(&calls, failures...), WithRetry()); status.Code(err) != codes.Unavailable {
+		t.Errorf("got %v, want the last failure", err)
+	}
+	if calls != 1 {
+		t.Errorf("got %d calls, the backoff should stop with the context", calls)
+	}
+}
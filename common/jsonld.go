@@ -0,0 +1,80 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package common
+
+import (
+	"encoding/json"
+	"html"
+	"html/template"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const JsonLdName = "JsonLd"
+
+type jsonLdPerson struct {
+	Type string `json:"@type"`
+	Name string `json:"name"`
+}
+
+type blogPostingJsonLd struct {
+	Context       string       `json:"@context"`
+	Type          string       `json:"@type"`
+	Headline      string       `json:"headline"`
+	Url           string       `json:"url"`
+	DatePublished string       `json:"datePublished"`
+	Author        jsonLdPerson `json:"author"`
+	Description   string       `json:"description,omitempty"`
+	Image         string       `json:"image,omitempty"`
+}
+
+// schema.org BlogPosting, to put in a <script type="application/ld+json"> of the page
+// (json.Marshal escapes '<', '>' and '&' so the content can not close the script)
+func InitBlogPostingJsonLd(data gin.H, title string, htmlContent string, author string, published time.Time, extractSize uint64, c *gin.Context) {
+	// only strings, can not fail
+	jsonLd, _ := json.Marshal(blogPostingJsonLd{
+		Context: "https://schema.org", Type: "BlogPosting", Headline: title, Url: GetAbsoluteUrl(c),
+		DatePublished: published.In(GetTimeZone(c)).Format(time.RFC3339),
+		Author:        jsonLdPerson{Type: "Person", Name: author},
+		Description:   extractText(FilterExtractHtml(htmlContent, extractSize)),
+		Image:         extractFirstImage(htmlContent, c),
+	})
+	data[JsonLdName] = template.HTML(jsonLd)
+}
+
+// the tags are removed and the entities decoded
+func extractText(htmlContent string) string {
+	var builder strings.Builder
+	for index := 0; index < len(htmlContent); {
+		if htmlContent[index] == '<' {
+			_, _, index, _ = readTag(htmlContent, index+1)
+			continue
+		}
+
+		textEnd := strings.IndexByte(htmlContent[index:], '<')
+		if textEnd == -1 {
+			textEnd = len(htmlContent) - index
+		}
+		builder.WriteString(htmlContent[index : index+textEnd])
+		index += textEnd
+	}
+	return html.UnescapeString(builder.String())
+}
@@ -0,0 +1,52 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package common
+
+import (
+	"encoding/json"
+	"mime"
+	"path"
+)
+
+type ManifestIcon struct {
+	Src     string `json:"src"`
+	Sizes   string `json:"sizes,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Purpose string `json:"purpose,omitempty"`
+}
+
+// web app manifest, allowing the installation as a PWA
+type Manifest struct {
+	Name            string         `json:"name"`
+	ShortName       string         `json:"short_name,omitempty"`
+	StartUrl        string         `json:"start_url"`
+	Display         string         `json:"display"`
+	ThemeColor      string         `json:"theme_color,omitempty"`
+	BackgroundColor string         `json:"background_color,omitempty"`
+	Icons           []ManifestIcon `json:"icons,omitempty"`
+}
+
+// the type is deduced from the extension of the url
+func MakeManifestIcon(url string, sizes string, purpose string) ManifestIcon {
+	return ManifestIcon{Src: url, Sizes: sizes, Type: mime.TypeByExtension(path.Ext(url)), Purpose: purpose}
+}
+
+func (m Manifest) Build() ([]byte, error) {
+	return json.Marshal(m)
+}
@@ -0,0 +1,59 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package common
+
+import (
+	"html"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	OgTitleName       = "OgTitle"
+	OgDescriptionName = "OgDescription"
+	OgUrlName         = "OgUrl"
+	OgImageName       = "OgImage"
+)
+
+var imgSrcRegexp = regexp.MustCompile(`<img[^>]*\ssrc\s*=\s*["']([^"']+)["']`)
+
+// htmlContent must be well formed (see FilterExtractHtml)
+func InitOpenGraph(data gin.H, title string, htmlContent string, extractSize uint64, c *gin.Context) {
+	data[OgTitleName] = title
+	data[OgDescriptionName] = extractText(FilterExtractHtml(htmlContent, extractSize))
+	data[OgUrlName] = GetAbsoluteUrl(c)
+	if image := extractFirstImage(htmlContent, c); image != "" {
+		data[OgImageName] = image
+	}
+}
+
+// the src attribute is unescaped and made absolute when local
+func extractFirstImage(htmlContent string, c *gin.Context) string {
+	match := imgSrcRegexp.FindStringSubmatch(htmlContent)
+	if len(match) < 2 {
+		return ""
+	}
+
+	image := html.UnescapeString(match[1])
+	if IsLocalPath(image) {
+		image = GetAbsolutePathUrl(image, c)
+	}
+	return image
+}
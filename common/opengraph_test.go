@@ -0,0 +1,41 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package common
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestInitOpenGraph(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "http://example.com/blog/view/1", nil)
+	data := gin.H{}
+
+	InitOpenGraph(data, "title", `<p>Tom &amp; <b>Jerry</b></p><img src="/static/a.png?w=1&amp;h=2">`, 100, c)
+
+	if got := data[OgDescriptionName]; got != "Tom & Jerry" {
+		t.Errorf("description = %q, want plain text", got)
+	}
+	if got := data[OgImageName]; got != "http://example.com/static/a.png?w=1&h=2" {
+		t.Errorf("image = %q, want an absolute and unescaped url", got)
+	}
+}
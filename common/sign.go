@@ -0,0 +1,111 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package common
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrTokenInvalid = errors.New("invalid token")
+	ErrTokenExpired = errors.New("expired token")
+)
+
+// signed payload with an expiration, usable in an url
+type SignedToken struct {
+	Key []byte
+	// tolerance on the expiration, for clocks of different instances
+	ClockSkew time.Duration
+}
+
+func (t SignedToken) Encode(payload string, ttl time.Duration) string {
+	expiration := strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+	return SignValue(t.Key, base64.RawURLEncoding.EncodeToString([]byte(expiration+":"+payload)))
+}
+
+// the expiration is only checked when the signature matches
+func (t SignedToken) Decode(token string) (string, error) {
+	encoded, ok := VerifySignedValue(t.Key, token)
+	if !ok {
+		return "", ErrTokenInvalid
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", ErrTokenInvalid
+	}
+
+	expirationStr, payload, ok := strings.Cut(string(decoded), ":")
+	if !ok {
+		return "", ErrTokenInvalid
+	}
+	expiration, err := strconv.ParseInt(expirationStr, 10, 64)
+	if err != nil {
+		return "", ErrTokenInvalid
+	}
+	if time.Now().Add(-t.ClockSkew).After(time.Unix(expiration, 0)) {
+		return "", ErrTokenExpired
+	}
+	return payload, nil
+}
+
+const (
+	SessionIdKeyPurpose       = "sessionId"
+	SessionFallbackKeyPurpose = "sessionFallback"
+	VerificationKeyPurpose    = "verification"
+)
+
+// derive an independent key for each use of a secret, so a value signed for one purpose is not valid for another
+func DeriveKey(key []byte, purpose string) []byte {
+	hash := hmac.New(sha256.New, key)
+	hash.Write([]byte(purpose))
+	return hash.Sum(nil)
+}
+
+// append an HMAC signature to the value
+func SignValue(key []byte, value string) string {
+	return value + "." + computeMac(key, value)
+}
+
+// return the value without its signature, ok is false when the signature does not match
+func VerifySignedValue(key []byte, signed string) (string, bool) {
+	index := strings.LastIndexByte(signed, '.')
+	if index == -1 {
+		return "", false
+	}
+
+	value := signed[:index]
+	if !hmac.Equal([]byte(signed[index+1:]), []byte(computeMac(key, value))) {
+		return "", false
+	}
+	return value, true
+}
+
+func computeMac(key []byte, value string) string {
+	hash := hmac.New(sha256.New, key)
+	hash.Write([]byte(value))
+	return hex.EncodeToString(hash.Sum(nil))
+}
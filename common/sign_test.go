@@ -0,0 +1,86 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package common
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeriveKey(t *testing.T) {
+	key := []byte("secret")
+	sessionKey := DeriveKey(key, SessionIdKeyPurpose)
+	verificationKey := DeriveKey(key, VerificationKeyPurpose)
+	if bytes.Equal(sessionKey, verificationKey) || bytes.Equal(sessionKey, key) {
+		t.Fatal("derived keys should differ between purposes and from the secret")
+	}
+	if !bytes.Equal(sessionKey, DeriveKey(key, SessionIdKeyPurpose)) {
+		t.Fatal("derivation should be stable")
+	}
+
+	signed := SignValue(sessionKey, "42")
+	if _, ok := VerifySignedValue(verificationKey, signed); ok {
+		t.Error("a value signed for the session id is valid for the verification")
+	}
+	if value, ok := VerifySignedValue(sessionKey, signed); !ok || value != "42" {
+		t.Errorf("got %q, %v, want 42, true", value, ok)
+	}
+}
+
+func TestSignedToken(t *testing.T) {
+	signer := SignedToken{Key: []byte("secret")}
+	token := signer.Encode("user=1:2", time.Minute)
+	if payload, err := signer.Decode(token); err != nil || payload != "user=1:2" {
+		t.Fatalf("got %q, %v, want the payload", payload, err)
+	}
+
+	value, signature, _ := strings.Cut(token, ".")
+	tampered := []string{
+		"", "nosignature", value + ".", "x" + token, value + "." + strings.Repeat("0", len(signature)),
+		SignValue(signer.Key, "%%%"), SignValue(signer.Key, "bm9jb2xvbg"), // not base64, no ':'
+	}
+	for _, token := range tampered {
+		if _, err := signer.Decode(token); !errors.Is(err, ErrTokenInvalid) {
+			t.Errorf("Decode(%q) : got %v, want ErrTokenInvalid", token, err)
+		}
+	}
+	if _, err := (SignedToken{Key: []byte("other")}).Decode(token); !errors.Is(err, ErrTokenInvalid) {
+		t.Errorf("a token signed with another key : got %v, want ErrTokenInvalid", err)
+	}
+}
+
+func TestSignedTokenExpiration(t *testing.T) {
+	signer := SignedToken{Key: []byte("secret")}
+	expired := signer.Encode("payload", -time.Minute)
+	if _, err := signer.Decode(expired); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("got %v, want ErrTokenExpired", err)
+	}
+
+	// the skew tolerates a token expired recently on a late clock
+	signer.ClockSkew = 2 * time.Minute
+	if payload, err := signer.Decode(expired); err != nil || payload != "payload" {
+		t.Errorf("got %q, %v, want the payload within the skew", payload, err)
+	}
+	if _, err := signer.Decode(signer.Encode("payload", -3*time.Minute)); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("got %v, want ErrTokenExpired beyond the skew", err)
+	}
+}
@@ -0,0 +1,153 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package common
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// the id must not be the end of another attribute name (like data-id)
+var idAttributeRegexp = regexp.MustCompile(`(?i)(?:^|\s)id\s*=\s*"([^"]*)"`)
+
+type TocEntry struct {
+	Id       string
+	Title    string
+	Level    int
+	Children []*TocEntry
+}
+
+type headingInProgress struct {
+	level      int
+	attributes string
+	content    strings.Builder
+	text       strings.Builder
+}
+
+// html must be well formed, heading without id receive one derived from their text,
+// the returned entries are nested following the heading levels
+func BuildToc(htmlContent string) (string, []*TocEntry) {
+	var builder strings.Builder
+	builder.Grow(len(htmlContent))
+
+	root := &TocEntry{}
+	parentStack := NewStack[*TocEntry]()
+	parentStack.Push(root)
+	usedIds := Set[string]{}
+	var current *headingInProgress
+	for index := 0; index < len(htmlContent); {
+		if htmlContent[index] != '<' {
+			// copy the text up to the next tag at once
+			textEnd := strings.IndexByte(htmlContent[index:], '<')
+			if textEnd == -1 {
+				textEnd = len(htmlContent)
+			} else {
+				textEnd += index
+			}
+			text := htmlContent[index:textEnd]
+			index = textEnd
+			if current == nil {
+				builder.WriteString(text)
+			} else {
+				current.content.WriteString(text)
+				current.text.WriteString(text)
+			}
+			continue
+		}
+
+		var tagName, attributes string
+		var ok bool
+		tagName, attributes, index, ok = readTag(htmlContent, index+1)
+		if !ok {
+			break
+		}
+
+		if current == nil {
+			if level := headingLevel(tagName); level != 0 {
+				current = &headingInProgress{level: level, attributes: attributes}
+				continue
+			}
+			writeTag(&builder, tagName, attributes)
+			continue
+		}
+
+		if closingName, closing := strings.CutPrefix(tagName, "/"); closing && headingLevel(closingName) == current.level {
+			entry := &TocEntry{Title: strings.TrimSpace(html.UnescapeString(current.text.String())), Level: current.level}
+			attributesStr := current.attributes
+			if match := idAttributeRegexp.FindStringSubmatch(attributesStr); match != nil {
+				entry.Id = match[1]
+			} else {
+				entry.Id = uniqueHeadingId(usedIds, entry.Title)
+				attributesStr = strings.TrimSpace(attributesStr + ` id="` + entry.Id + `"`)
+			}
+			usedIds.Add(entry.Id)
+
+			writeTag(&builder, closingName, attributesStr)
+			builder.WriteString(current.content.String())
+			writeTag(&builder, tagName, "")
+
+			for parentStack.Peek().Level >= entry.Level {
+				parentStack.Pop()
+			}
+			parent := parentStack.Peek()
+			parent.Children = append(parent.Children, entry)
+			parentStack.Push(entry)
+			current = nil
+			continue
+		}
+		// tag inside heading, kept in content but not in text
+		writeTag(&current.content, tagName, attributes)
+	}
+	return builder.String(), root.Children
+}
+
+func writeTag(builder *strings.Builder, tagName string, attributes string) {
+	builder.WriteByte('<')
+	builder.WriteString(tagName)
+	if attributes != "" {
+		builder.WriteByte(' ')
+		builder.WriteString(attributes)
+	}
+	builder.WriteByte('>')
+}
+
+// return 0 when the tag is not a heading
+func headingLevel(tagName string) int {
+	if len(tagName) == 2 && (tagName[0] == 'h' || tagName[0] == 'H') && tagName[1] >= '1' && tagName[1] <= '6' {
+		return int(tagName[1] - '0')
+	}
+	return 0
+}
+
+func uniqueHeadingId(usedIds Set[string], title string) string {
+	id := Slugify(title)
+	if id == "" {
+		id = "section"
+	}
+	if !usedIds.Contains(id) {
+		return id
+	}
+	for i := 2; ; i++ {
+		if candidate := id + "-" + strconv.Itoa(i); !usedIds.Contains(candidate) {
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,48 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package common
+
+import "testing"
+
+func TestBuildToc(t *testing.T) {
+	content, entries := BuildToc(`<h1>Intro</h1><p>text</p><h2 data-id="x">Part <em>one</em></h2><h2 id="custom">Intro</h2><h1>Intro</h1>`)
+
+	want := `<h1 id="intro">Intro</h1><p>text</p><h2 data-id="x" id="part-one">Part <em>one</em></h2>` +
+		`<h2 id="custom">Intro</h2><h1 id="intro-2">Intro</h1>`
+	if content != want {
+		t.Errorf("got content %q, want %q", content, want)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("got %d root entries, want 2", len(entries))
+	}
+	first := entries[0]
+	if first.Id != "intro" || len(first.Children) != 2 {
+		t.Fatalf("got first entry %+v, want intro with 2 children", first)
+	}
+	if child := first.Children[0]; child.Id != "part-one" || child.Title != "Part one" || child.Level != 2 {
+		t.Errorf("got child %+v, want part-one", child)
+	}
+	if child := first.Children[1]; child.Id != "custom" {
+		t.Errorf("got child id %q, want custom", child.Id)
+	}
+	if second := entries[1]; second.Id != "intro-2" {
+		t.Errorf("got second id %q, want intro-2", second.Id)
+	}
+}
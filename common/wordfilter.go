@@ -0,0 +1,122 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package common
+
+import (
+	"cmp"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// what happens to a text with a blocked word
+const (
+	BlockedWordsReject = "reject"
+	BlockedWordsMask   = "mask"
+)
+
+// characters ignored between the letters of a blocked word when spacing is checked
+const obfuscationPattern = `[\s.\-_*]*`
+
+// check texts against a list of blocked words (case insensitive), the zero value accepts everything
+type WordFilter struct {
+	pattern *regexp.Regexp
+	mask    bool
+}
+
+// with spacing, "b a d" or "b.a.d" match the blocked word "bad"
+func NewWordFilter(words []string, mask bool, spacing bool) WordFilter {
+	patterns := make([]string, 0, len(words))
+	for _, word := range words {
+		if word = strings.TrimSpace(word); word == "" {
+			continue
+		}
+
+		var patternBuilder strings.Builder
+		for i, char := range word {
+			if spacing && i != 0 {
+				patternBuilder.WriteString(obfuscationPattern)
+			}
+			patternBuilder.WriteString(regexp.QuoteMeta(string(char)))
+		}
+		patterns = append(patterns, patternBuilder.String())
+	}
+	if len(patterns) == 0 {
+		return WordFilter{}
+	}
+	// the alternation stops at the first match, a longer word must be tried before its prefix
+	slices.SortStableFunc(patterns, func(a string, b string) int {
+		return cmp.Compare(len(b), len(a))
+	})
+	return WordFilter{pattern: regexp.MustCompile("(?i)" + strings.Join(patterns, "|")), mask: mask}
+}
+
+// return the text, with the blocked words masked when configured so,
+// or ErrBlockedWord when the text is rejected
+func (f WordFilter) Check(text string) (string, error) {
+	if f.pattern == nil {
+		return text, nil
+	}
+
+	found := false
+	var resBuilder strings.Builder
+	previous := 0
+	for _, indexes := range f.pattern.FindAllStringIndex(text, -1) {
+		start, end := indexes[0], indexes[1]
+		// only whole words (avoid rejecting a longer word containing a blocked one)
+		if !wordBoundary(text, start, end) {
+			continue
+		}
+		if !f.mask {
+			return "", ErrBlockedWord
+		}
+
+		found = true
+		resBuilder.WriteString(text[previous:start])
+		for _, char := range text[start:end] {
+			if unicode.IsSpace(char) {
+				resBuilder.WriteRune(char)
+			} else {
+				resBuilder.WriteByte('*')
+			}
+		}
+		previous = end
+	}
+	if !found {
+		return text, nil
+	}
+	resBuilder.WriteString(text[previous:])
+	return resBuilder.String(), nil
+}
+
+func wordBoundary(text string, start int, end int) bool {
+	if before, _ := utf8.DecodeLastRuneInString(text[:start]); start != 0 && isWordChar(before) {
+		return false
+	}
+	if after, _ := utf8.DecodeRuneInString(text[end:]); end != len(text) && isWordChar(after) {
+		return false
+	}
+	return true
+}
+
+func isWordChar(char rune) bool {
+	return unicode.IsLetter(char) || unicode.IsDigit(char)
+}
@@ -0,0 +1,46 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package common
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestWordFilterLongestFirst(t *testing.T) {
+	filter := NewWordFilter([]string{"bad", "badword"}, true, false)
+	if got, err := filter.Check("a badword here"); err != nil || got != "a ******* here" {
+		t.Errorf("got %q (error %v)", got, err)
+	}
+
+	filter = NewWordFilter([]string{"bad", "badword"}, false, false)
+	if _, err := filter.Check("a badword here"); !errors.Is(err, ErrBlockedWord) {
+		t.Errorf("expected ErrBlockedWord, got %v", err)
+	}
+	if got, err := filter.Check("a badwords here"); err != nil || got != "a badwords here" {
+		t.Errorf("got %q (error %v)", got, err)
+	}
+}
+
+func TestWordFilterSpacing(t *testing.T) {
+	filter := NewWordFilter([]string{"bad"}, true, true)
+	if got, err := filter.Check("so b.a d"); err != nil || got != "so *** *" {
+		t.Errorf("got %q (error %v)", got, err)
+	}
+}
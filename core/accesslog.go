@@ -0,0 +1,81 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package puzzleweb
+
+import (
+	"time"
+
+	"github.com/dvaumoron/puzzleweb/common"
+	"github.com/dvaumoron/puzzleweb/common/config"
+	"github.com/dvaumoron/puzzleweb/common/log"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type accessLogger struct {
+	loggerGetter log.LoggerGetter
+	logAt        func(log.Logger, string, ...zapcore.Field)
+	skipPaths    common.Set[string]
+}
+
+// return nil when disabled
+func newAccessLogger(loggerGetter log.LoggerGetter, level string, skipPaths []string) *accessLogger {
+	var logAt func(log.Logger, string, ...zapcore.Field)
+	switch level {
+	case config.AccessLogDebug:
+		logAt = log.Logger.Debug
+	case config.AccessLogInfo:
+		logAt = log.Logger.Info
+	case config.AccessLogWarn:
+		logAt = log.Logger.Warn
+	default:
+		return nil
+	}
+	return &accessLogger{loggerGetter: loggerGetter, logAt: logAt, skipPaths: common.MakeSet(skipPaths)}
+}
+
+func (l *accessLogger) log(c *gin.Context) {
+	path := c.Request.URL.Path
+	if l.skipPaths.Contains(path) {
+		return
+	}
+
+	start := time.Now()
+	c.Next()
+
+	// the query is not logged, it can contain a token
+	ctx := c.Request.Context()
+	fields := []zapcore.Field{
+		zap.String("method", c.Request.Method), zap.String("path", path), zap.Int("status", c.Writer.Status()),
+		zap.Duration("duration", time.Since(start)), zap.Int("size", max(0, c.Writer.Size())),
+		zap.String("clientIp", c.ClientIP()),
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.HasTraceID() {
+		fields = append(fields, zap.String("traceId", spanContext.TraceID().String()))
+	}
+	// no GetSessionUserId, it logs when there is no user
+	if session, ok := c.Value(SessionName).(*Session); ok {
+		if userId, ok := session.LoadUint64(userIdName); ok {
+			fields = append(fields, zap.Uint64(userIdName, userId))
+		}
+	}
+	l.logAt(l.loggerGetter.Logger(ctx), "Request served", fields...)
+}
@@ -0,0 +1,95 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package puzzleweb
+
+import (
+	"context"
+
+	adminservice "github.com/dvaumoron/puzzleweb/admin/service"
+	blogservice "github.com/dvaumoron/puzzleweb/blog/service"
+	"github.com/dvaumoron/puzzleweb/common"
+	"github.com/dvaumoron/puzzleweb/common/config"
+	loginservice "github.com/dvaumoron/puzzleweb/login/service"
+	profileservice "github.com/dvaumoron/puzzleweb/profile/service"
+)
+
+const (
+	confirmationName  = "Confirmation"
+	contentPolicyName = "ContentPolicy"
+)
+
+// shared by the admin and the self-service deletion
+type accountDeleter struct {
+	adminService   adminservice.AdminService
+	profileService profileservice.AdvancedProfileService
+	loginService   loginservice.FullLoginService
+	blogService    blogservice.BlogService // nil when no blog is linked to profiles
+	contentPolicy  string
+}
+
+func newAccountDeleter(profileConfig config.ProfileConfig) accountDeleter {
+	return accountDeleter{
+		adminService: profileConfig.AdminService, profileService: profileConfig.Service,
+		loginService: profileConfig.LoginService, blogService: profileConfig.BlogService,
+		contentPolicy: profileConfig.DeletedContentPolicy,
+	}
+}
+
+// return the configured policy when the asked one is unknown
+func (d accountDeleter) choosePolicy(askedPolicy string) string {
+	switch askedPolicy {
+	case config.DeletedContentAnonymize, config.DeletedContentDelete:
+		return askedPolicy
+	}
+	return d.contentPolicy
+}
+
+// only the first service call do a right check (a user can always delete its own account),
+// there is no reassignment of authorship : with the anonymize policy, the content is kept
+// and the profile service display a placeholder in place of the deleted author
+func (d accountDeleter) deleteAccount(ctx context.Context, adminId uint64, userId uint64, contentPolicy string) error {
+	// an empty slice delete the user right
+	err := d.adminService.UpdateUser(ctx, adminId, userId, []adminservice.Group{})
+	if err != nil {
+		return err
+	}
+
+	if d.choosePolicy(contentPolicy) == config.DeletedContentDelete && d.blogService != nil {
+		if err = d.blogService.DeletePostsByAuthor(ctx, adminId, userId); err != nil {
+			return err
+		}
+	}
+
+	if err = d.profileService.Delete(ctx, userId); err != nil {
+		return err
+	}
+	return d.loginService.Delete(ctx, userId)
+}
+
+// the password is checked again before a self-service deletion
+func (d accountDeleter) deleteOwnAccount(ctx context.Context, userId uint64, login string, password string, contentPolicy string) error {
+	verifiedId, err := d.loginService.Verify(ctx, login, password)
+	if err != nil {
+		return err
+	}
+	if verifiedId != userId {
+		return common.ErrWrongLogin
+	}
+	return d.deleteAccount(ctx, userId, userId, contentPolicy)
+}
@@ -28,6 +28,8 @@ import (
 	"github.com/dvaumoron/puzzleweb/common/config"
 	"github.com/dvaumoron/puzzleweb/locale"
 	"github.com/gin-gonic/gin"
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
 )
 
 const (
@@ -35,11 +37,9 @@ const (
 	groupName     = "Group"
 	groupsName    = "Groups"
 	viewAdminName = "ViewAdmin"
+	actionsName   = "Actions"
 
-	accessKey = "AccessLabel"
-	createKey = "CreateLabel"
-	updateKey = "UpdateLabel"
-	deleteKey = "DeleteLabel"
+	actionLabelSuffix = "Label"
 )
 
 type GroupDisplay struct {
@@ -54,6 +54,12 @@ func NewGroupDisplay(id uint64, name string) *GroupDisplay {
 	return &GroupDisplay{Id: id, Name: name, DisplayName: getGroupDisplayNameKey(name)}
 }
 
+type ActionDisplay struct {
+	Name    string
+	Label   string
+	Checked bool
+}
+
 type RoleDisplay struct {
 	Name    string
 	Actions []string
@@ -67,20 +73,29 @@ func cmpGroupAsc(a *GroupDisplay, b *GroupDisplay) int {
 	return cmp.Compare(a.Id, b.Id)
 }
 
-func cmpRoleAsc(a RoleDisplay, b RoleDisplay) int {
-	return cmp.Compare(a.Name, b.Name)
+// role names are compared with the collation of the current language (accented names are correctly ordered)
+func makeCmpRoleAsc(c *gin.Context) func(RoleDisplay, RoleDisplay) int {
+	collator := collate.New(language.Make(GetLocalesManager(c).GetLang(c)))
+	return func(a RoleDisplay, b RoleDisplay) int {
+		return collator.CompareString(a.Name, b.Name)
+	}
 }
 
 type adminWidget struct {
-	displayHandler    gin.HandlerFunc
-	listUserHandler   gin.HandlerFunc
-	viewUserHandler   gin.HandlerFunc
-	editUserHandler   gin.HandlerFunc
-	saveUserHandler   gin.HandlerFunc
-	deleteUserHandler gin.HandlerFunc
-	listRoleHandler   gin.HandlerFunc
-	editRoleHandler   gin.HandlerFunc
-	saveRoleHandler   gin.HandlerFunc
+	displayHandler       gin.HandlerFunc
+	listUserHandler      gin.HandlerFunc
+	viewUserHandler      gin.HandlerFunc
+	editUserHandler      gin.HandlerFunc
+	saveUserHandler      gin.HandlerFunc
+	deleteUserHandler    gin.HandlerFunc
+	importUserHandler    gin.HandlerFunc
+	listRoleHandler      gin.HandlerFunc
+	editRoleHandler      gin.HandlerFunc
+	saveRoleHandler      gin.HandlerFunc
+	maintenanceHandler   gin.HandlerFunc
+	clearCacheHandler    gin.HandlerFunc
+	listSessionHandler   gin.HandlerFunc
+	revokeSessionHandler gin.HandlerFunc
 }
 
 func (w adminWidget) LoadInto(router gin.IRouter) {
@@ -90,16 +105,22 @@ func (w adminWidget) LoadInto(router gin.IRouter) {
 	router.GET("/user/edit/:UserId", w.editUserHandler)
 	router.POST("/user/save/:UserId", w.saveUserHandler)
 	router.GET("/user/delete/:UserId", w.deleteUserHandler)
+	router.POST("/user/import", w.importUserHandler)
 	router.GET("/role/list", w.listRoleHandler)
 	router.GET("/role/edit/:RoleName/:Group", w.editRoleHandler)
 	router.POST("/role/save", w.saveRoleHandler)
+	router.POST("/maintenance", w.maintenanceHandler)
+	router.POST("/cache/clear", w.clearCacheHandler)
+	router.GET("/session/list", w.listSessionHandler)
+	router.POST("/session/revoke/:SessionId", w.revokeSessionHandler)
 }
 
-func newAdminPage(adminConfig config.AdminConfig) Page {
+func newAdminPage(adminConfig config.AdminConfig, lister sessionLister, deleter accountDeleter) Page {
 	adminService := adminConfig.Service
 	userService := adminConfig.UserService
-	profileService := adminConfig.ProfileService
 	defaultPageSize := adminConfig.PageSize
+	maxPageSize := adminConfig.MaxPageSize
+	rolePresets := adminConfig.RolePresets
 
 	p := MakeHiddenPage("admin")
 	p.Widget = adminWidget{
@@ -108,6 +129,9 @@ func newAdminPage(adminConfig config.AdminConfig) Page {
 			if !viewAdmin {
 				return "", common.DefaultErrorRedirect(GetLogger(c), common.ErrorNotAuthorizedKey)
 			}
+			site := getSite(c)
+			data["InMaintenance"] = site.InMaintenance()
+			data["PageCacheEnabled"] = site.pageCache != nil
 			return "admin/index", ""
 		}),
 		listUserHandler: CreateTemplate(func(data gin.H, c *gin.Context) (string, string) {
@@ -117,14 +141,18 @@ func newAdminPage(adminConfig config.AdminConfig) Page {
 				return "", common.DefaultErrorRedirect(logger, common.ErrorNotAuthorizedKey)
 			}
 
-			pageNumber, start, end, filter := common.GetPagination(defaultPageSize, c)
+			pageNumber, pageSize, start, end, filter := common.GetPagination(defaultPageSize, maxPageSize, c)
 
 			total, users, err := userService.ListUsers(c.Request.Context(), start, end, filter)
 			if err != nil {
 				return "", common.DefaultErrorRedirect(logger, err.Error())
 			}
 
-			common.InitPagination(data, filter, pageNumber, end, total)
+			for index := range users {
+				users[index].RegistredAt = common.FormatDate(users[index].RegistredTime, c)
+			}
+
+			common.InitPagination(data, filter, pageNumber, pageSize, total, c)
 			data["Users"] = users
 			InitNoELementMsg(data, len(users), c)
 			return "admin/user/list", ""
@@ -149,9 +177,10 @@ func newAdminPage(adminConfig config.AdminConfig) Page {
 			}
 
 			user := users[userId]
+			user.RegistredAt = common.FormatDate(user.RegistredTime, c)
 			data[common.ViewedUserName] = user
 			data[common.AllowedToUpdateName] = updateRight
-			data[groupsName] = displayGroups(groups)
+			data[groupsName] = displayGroups(groups, c)
 			return "admin/user/view", ""
 		}),
 		editUserHandler: CreateTemplate(func(data gin.H, c *gin.Context) (string, string) {
@@ -173,29 +202,26 @@ func newAdminPage(adminConfig config.AdminConfig) Page {
 				return "", common.DefaultErrorRedirect(logger, err.Error())
 			}
 
-			data[common.ViewedUserName] = userIdToLogin[userId]
-			data[groupsName] = displayEditGroups(userRoles, allRoles)
+			user := userIdToLogin[userId]
+			user.RegistredAt = common.FormatDate(user.RegistredTime, c)
+			data[common.ViewedUserName] = user
+			data[groupsName] = displayEditGroups(userRoles, allRoles, c)
+			data[rolePresetsName] = sortedPresetNames(rolePresets)
 			return "admin/user/edit", ""
 		}),
 		saveUserHandler: common.CreateRedirect(func(c *gin.Context) string {
 			userId := GetRequestedUserId(c)
 			err := common.ErrTechnical
 			if userId != 0 {
-				rolesStr := c.PostFormArray("roles")
-				nameToGroup := make(map[string]adminservice.Group, len(rolesStr))
-				for _, roleStr := range rolesStr {
-					splitted := strings.Split(roleStr, "/")
-					if len(splitted) > 1 {
-						groupName := splitted[1]
-						group, ok := nameToGroup[groupName]
-						if !ok {
-							group = adminservice.Group{Name: groupName}
-						}
-						group.Roles = append(group.Roles, adminservice.Role{Name: splitted[0]})
-						nameToGroup[groupName] = group
-					}
+				ctx := c.Request.Context()
+				adminId := GetSessionUserId(c)
+				var rolesStr []string
+				rolesStr, err = expandRolePreset(
+					ctx, adminService, rolePresets, adminId, userId, c.PostForm(rolePresetName), c.PostFormArray("roles"),
+				)
+				if err == nil {
+					err = adminService.UpdateUser(ctx, adminId, userId, parseRoles(rolesStr))
 				}
-				err = adminService.UpdateUser(c.Request.Context(), GetSessionUserId(c), userId, common.MapToValueSlice(nameToGroup))
 			}
 
 			targetBuilder := userListUrlBuilder()
@@ -208,16 +234,7 @@ func newAdminPage(adminConfig config.AdminConfig) Page {
 			userId := GetRequestedUserId(c)
 			err := common.ErrTechnical
 			if userId != 0 {
-				// an empty slice delete the user right
-				// only the first service call do a right check
-				ctx := c.Request.Context()
-				err = adminService.UpdateUser(ctx, GetSessionUserId(c), userId, []adminservice.Group{})
-				if err == nil {
-					err = profileService.Delete(ctx, userId)
-					if err == nil {
-						err = userService.Delete(ctx, userId)
-					}
-				}
+				err = deleter.deleteAccount(c.Request.Context(), GetSessionUserId(c), userId, c.Query(contentPolicyName))
 			}
 
 			targetBuilder := userListUrlBuilder()
@@ -226,13 +243,37 @@ func newAdminPage(adminConfig config.AdminConfig) Page {
 			}
 			return targetBuilder.String()
 		}),
+		importUserHandler: CreateTemplate(func(data gin.H, c *gin.Context) (string, string) {
+			logger := GetLogger(c)
+			adminId, _ := data[common.UserIdName].(uint64)
+			fileHeader, err := c.FormFile(importFileName)
+			if err != nil {
+				common.LogOriginalError(logger, err)
+				return "", common.DefaultErrorRedirect(logger, common.ErrorWrongImportFormatKey)
+			}
+
+			file, err := fileHeader.Open()
+			if err != nil {
+				return "", common.DefaultErrorRedirect(logger, err.Error())
+			}
+			defer file.Close()
+
+			results, err := importUsers(c.Request.Context(), logger, adminService, userService, adminId, file)
+			if err != nil {
+				return "", common.DefaultErrorRedirect(logger, err.Error())
+			}
+
+			data[importedUsersName] = results
+			InitNoELementMsg(data, len(results), c)
+			return "admin/user/import", ""
+		}),
 		listRoleHandler: CreateTemplate(func(data gin.H, c *gin.Context) (string, string) {
 			adminId, _ := data[common.UserIdName].(uint64)
 			allGroups, err := adminService.GetAllGroups(c.Request.Context(), adminId)
 			if err != nil {
 				return "", common.DefaultErrorRedirect(GetLogger(c), err.Error())
 			}
-			data[groupsName] = displayGroups(allGroups)
+			data[groupsName] = displayGroups(allGroups, c)
 			return "admin/role/list", ""
 		}),
 		editRoleHandler: CreateTemplate(func(data gin.H, c *gin.Context) (string, string) {
@@ -243,20 +284,28 @@ func newAdminPage(adminConfig config.AdminConfig) Page {
 			data[groupName] = group
 			data["GroupDisplayName"] = getGroupDisplayNameKey(group)
 
+			actionSet := common.Set[string]{}
 			if roleName != "new" {
 				adminId, _ := data[common.UserIdName].(uint64)
 				actions, err := adminService.GetActions(c.Request.Context(), adminId, roleName, group)
 				if err != nil {
 					return "", common.DefaultErrorRedirect(GetLogger(c), err.Error())
 				}
-
-				actionSet := common.MakeSet(actions)
-				setActionChecked(data, actionSet, adminservice.ActionAccess, "Access")
-				setActionChecked(data, actionSet, adminservice.ActionCreate, "Create")
-				setActionChecked(data, actionSet, adminservice.ActionUpdate, "Update")
-				setActionChecked(data, actionSet, adminservice.ActionDelete, "Delete")
+				actionSet = common.MakeSet(actions)
 			}
 
+			actionDisplays := make([]ActionDisplay, 0, len(adminservice.AllActions))
+			for _, action := range adminservice.AllActions {
+				checked := actionSet.Contains(action)
+				actionDisplays = append(actionDisplays, ActionDisplay{
+					Name: action, Label: getActionLabelKey(action), Checked: checked,
+				})
+				if checked {
+					// keep the flags used by existing templates ("Access", "Create", ...)
+					data[locale.CamelCase(action)] = true
+				}
+			}
+			data[actionsName] = actionDisplays
 			return "admin/role/edit", ""
 		}),
 		saveRoleHandler: common.CreateRedirect(func(c *gin.Context) string {
@@ -264,8 +313,10 @@ func newAdminPage(adminConfig config.AdminConfig) Page {
 			err := common.ErrBadRoleName
 			if roleName != "new" {
 				group := c.PostForm(groupName)
-				actions := c.PostFormArray("actions")
-				err = adminService.UpdateRole(c.Request.Context(), GetSessionUserId(c), roleName, group, actions)
+				var actions []string
+				if actions, err = checkActions(c.PostFormArray("actions")); err == nil {
+					err = adminService.UpdateRole(c.Request.Context(), GetSessionUserId(c), roleName, group, actions)
+				}
 			}
 
 			var targetBuilder strings.Builder
@@ -275,6 +326,62 @@ func newAdminPage(adminConfig config.AdminConfig) Page {
 			}
 			return targetBuilder.String()
 		}),
+		maintenanceHandler: common.CreateRedirect(func(c *gin.Context) string {
+			logger := GetLogger(c)
+			adminId := GetSessionUserId(c)
+			err := adminService.AuthQuery(c.Request.Context(), adminId, adminservice.AdminGroupId, adminservice.ActionUpdate)
+			if err != nil {
+				return common.DefaultErrorRedirect(logger, err.Error())
+			}
+
+			getSite(c).SetMaintenance(c.PostForm("enabled") == "true", c.PostForm("message"))
+			return "/admin"
+		}),
+		clearCacheHandler: common.CreateRedirect(func(c *gin.Context) string {
+			logger := GetLogger(c)
+			adminId := GetSessionUserId(c)
+			err := adminService.AuthQuery(c.Request.Context(), adminId, adminservice.AdminGroupId, adminservice.ActionUpdate)
+			if err != nil {
+				return common.DefaultErrorRedirect(logger, err.Error())
+			}
+
+			getSite(c).ClearPageCache()
+			return "/admin"
+		}),
+		listSessionHandler: CreateTemplate(func(data gin.H, c *gin.Context) (string, string) {
+			logger := GetLogger(c)
+			viewAdmin, _ := data[viewAdminName].(bool)
+			if !viewAdmin {
+				return "", common.DefaultErrorRedirect(logger, common.ErrorNotAuthorizedKey)
+			}
+
+			sessions, err := lister.list(c, 0)
+			if err != nil {
+				return "", common.DefaultErrorRedirect(logger, err.Error())
+			}
+
+			data[sessionsName] = sessions
+			InitNoELementMsg(data, len(sessions), c)
+			return "admin/session/list", ""
+		}),
+		revokeSessionHandler: common.CreateRedirect(func(c *gin.Context) string {
+			logger := GetLogger(c)
+			ctx := c.Request.Context()
+			err := adminService.AuthQuery(ctx, GetSessionUserId(c), adminservice.AdminGroupId, adminservice.ActionDelete)
+			if err == nil {
+				err = common.ErrTechnical
+				if sessionId := getRequestedSessionId(c); sessionId != 0 {
+					err = lister.revoke(ctx, 0, sessionId)
+				}
+			}
+
+			var targetBuilder strings.Builder
+			targetBuilder.WriteString("/admin/session/list")
+			if err != nil {
+				common.WriteError(&targetBuilder, logger, err.Error())
+			}
+			return targetBuilder.String()
+		}),
 	}
 	return p
 }
@@ -283,10 +390,10 @@ func getGroupDisplayNameKey(name string) string {
 	return "GroupLabel" + locale.CamelCase(name)
 }
 
-func displayGroups(groups []adminservice.Group) []*GroupDisplay {
+func displayGroups(groups []adminservice.Group, c *gin.Context) []*GroupDisplay {
 	nameToGroup := map[string]*GroupDisplay{}
 	populateGroup(nameToGroup, groups, rolesAppender)
-	return sortGroups(nameToGroup)
+	return sortGroups(nameToGroup, c)
 }
 
 func populateGroup(nameToGroup map[string]*GroupDisplay, groups []adminservice.Group, appender func(*GroupDisplay, adminservice.Role)) {
@@ -306,29 +413,39 @@ func rolesAppender(group *GroupDisplay, role adminservice.Role) {
 	group.Roles = append(group.Roles, MakeRoleDisplay(role))
 }
 
+// reject the actions not in adminservice.AllActions, the result is sorted without duplicate
+func checkActions(actions []string) ([]string, error) {
+	for _, action := range actions {
+		if !slices.Contains(adminservice.AllActions, action) {
+			return nil, common.ErrUnknownAction
+		}
+	}
+	actions = slices.Clone(actions)
+	slices.Sort(actions)
+	return slices.Compact(actions), nil
+}
+
+func getActionLabelKey(action string) string {
+	return locale.CamelCase(action) + actionLabelSuffix
+}
+
 // convert a string slice of codes in a displayable key slice,
-// always in the same order : access, create, update, delete
+// always in the order of adminservice.AllActions (access, create, update, delete)
 func displayActions(actions []string) []string {
 	actionSet := common.MakeSet(actions)
 	res := make([]string, 0, len(actionSet))
-	if actionSet.Contains(adminservice.ActionAccess) {
-		res = append(res, accessKey)
-	}
-	if actionSet.Contains(adminservice.ActionCreate) {
-		res = append(res, createKey)
-	}
-	if actionSet.Contains(adminservice.ActionUpdate) {
-		res = append(res, updateKey)
-	}
-	if actionSet.Contains(adminservice.ActionDelete) {
-		res = append(res, deleteKey)
+	for _, action := range adminservice.AllActions {
+		if actionSet.Contains(action) {
+			res = append(res, getActionLabelKey(action))
+		}
 	}
 	return res
 }
 
-func sortGroups(nameToGroup map[string]*GroupDisplay) []*GroupDisplay {
+func sortGroups(nameToGroup map[string]*GroupDisplay, c *gin.Context) []*GroupDisplay {
 	groupRoles := common.MapToValueSlice(nameToGroup)
 	slices.SortFunc(groupRoles, cmpGroupAsc)
+	cmpRoleAsc := makeCmpRoleAsc(c)
 	for _, group := range groupRoles {
 		slices.SortFunc(group.Roles, cmpRoleAsc)
 		slices.SortFunc(group.AddableRoles, cmpRoleAsc)
@@ -336,11 +453,11 @@ func sortGroups(nameToGroup map[string]*GroupDisplay) []*GroupDisplay {
 	return groupRoles
 }
 
-func displayEditGroups(userRoles []adminservice.Group, allRoles []adminservice.Group) []*GroupDisplay {
+func displayEditGroups(userRoles []adminservice.Group, allRoles []adminservice.Group, c *gin.Context) []*GroupDisplay {
 	nameToGroup := map[string]*GroupDisplay{}
 	populateGroup(nameToGroup, userRoles, rolesAppender)
 	populateGroup(nameToGroup, allRoles, addableRolesAppender)
-	return sortGroups(nameToGroup)
+	return sortGroups(nameToGroup, c)
 }
 
 func addableRolesAppender(group *GroupDisplay, role adminservice.Role) {
@@ -354,12 +471,6 @@ func addableRolesAppender(group *GroupDisplay, role adminservice.Role) {
 	}
 }
 
-func setActionChecked(data gin.H, actionSet common.Set[string], toTest string, name string) {
-	if actionSet.Contains(toTest) {
-		data[name] = true
-	}
-}
-
 func userListUrlBuilder() *strings.Builder {
 	targetBuilder := new(strings.Builder)
 	targetBuilder.WriteString("/admin/user/list")
@@ -0,0 +1,58 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package puzzleweb
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	adminservice "github.com/dvaumoron/puzzleweb/admin/service"
+	"github.com/dvaumoron/puzzleweb/common"
+)
+
+func TestDisplayActions(t *testing.T) {
+	tests := []struct {
+		actions []string
+		want    []string
+	}{
+		{nil, []string{}},
+		{[]string{adminservice.ActionDelete, adminservice.ActionAccess}, []string{"AccessLabel", "DeleteLabel"}},
+		{adminservice.AllActions, []string{"AccessLabel", "CreateLabel", "UpdateLabel", "DeleteLabel"}},
+		{[]string{adminservice.ActionUpdate, adminservice.ActionUpdate}, []string{"UpdateLabel"}},
+	}
+	for _, tt := range tests {
+		if got := displayActions(tt.actions); !slices.Equal(got, tt.want) {
+			t.Errorf("displayActions(%v) = %q, want %q", tt.actions, got, tt.want)
+		}
+	}
+}
+
+func TestCheckActions(t *testing.T) {
+	actions, err := checkActions([]string{adminservice.ActionDelete, adminservice.ActionAccess, adminservice.ActionDelete})
+	if err != nil || !slices.Equal(actions, []string{adminservice.ActionAccess, adminservice.ActionDelete}) {
+		t.Errorf("got %v (error %v), want sorted actions without duplicate", actions, err)
+	}
+
+	for _, actions := range [][]string{{"bogus"}, {adminservice.ActionAccess, "Access"}, {""}} {
+		if _, err := checkActions(actions); !errors.Is(err, common.ErrUnknownAction) {
+			t.Errorf("checkActions(%q) : got %v, want ErrUnknownAction", actions, err)
+		}
+	}
+}
@@ -68,6 +68,15 @@ func GetLocalesManager(c *gin.Context) common.LocalesManager {
 	return getSite(c).localesManager
 }
 
+func GetSettingsManager(c *gin.Context) *SettingsManager {
+	return getSite(c).settingsManager
+}
+
+// for a page rendered again with the error instead of a redirect
+func InitErrorMsg(data gin.H, errorKey string) {
+	data[errorMsgName] = errorKey
+}
+
 func InitNoELementMsg(data gin.H, size int, c *gin.Context) {
 	if size == 0 {
 		data[errorMsgName] = "NoElement"
@@ -116,14 +125,22 @@ func initData(c *gin.Context) gin.H {
 	localesManager := site.localesManager
 	currentUrl := common.GetCurrentUrl(c)
 	page, path := site.extractArianeInfoFromUrl(currentUrl)
+	lang := localesManager.GetLang(c)
+	c.Set(common.DateFormatName, localesManager.GetDateFormat(lang))
 	data := gin.H{
-		locale.LangName: localesManager.GetLang(c),
+		locale.LangName: lang,
 		"PageTitle":     getPageTitleKey(page.name),
 		"CurrentUrl":    currentUrl,
 		"Ariane":        buildAriane(path),
 		"SubPages":      page.extractSubPageNames(currentUrl, c),
 		errorMsgName:    c.Query("error"),
 	}
+	if site.staticVersion != "" {
+		data[staticVersionName] = site.staticVersion
+	}
+	if site.staticAssets != nil {
+		data[staticAssetsName] = site.staticAssets
+	}
 	escapedUrl := url.QueryEscape(c.Request.URL.Path)
 	if localesManager.GetMultipleLang() {
 		data["LangSelectorUrl"] = "/changeLang?Redirect=" + escapedUrl
@@ -138,12 +155,13 @@ func initData(c *gin.Context) gin.H {
 		data[loginName] = login
 		data[common.UserIdName] = currentUserId
 		data[loginUrlName] = "/login/logout?Redirect=" + escapedUrl
+		site.settingsManager.setUserTimeZone(currentUserId, c)
 	}
 	data[viewAdminName] = site.authService.AuthQuery(
 		c.Request.Context(), currentUserId, adminservice.AdminGroupId, adminservice.ActionAccess,
 	) == nil
-	for _, adder := range site.adders {
-		adder(data, c)
+	for _, prioritized := range site.adders {
+		prioritized.adder(data, c)
 	}
 	return data
 }
@@ -0,0 +1,55 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package puzzleweb
+
+import (
+	captchaservice "github.com/dvaumoron/puzzleweb/captcha/service"
+	"github.com/dvaumoron/puzzleweb/common"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	captchaSiteKeyName       = "CaptchaSiteKey"
+	captchaResponseFieldName = "CaptchaResponseField"
+)
+
+// do nothing when captcha is disabled (nil service)
+func InitCaptcha(data gin.H, captchaService captchaservice.CaptchaService) {
+	if captchaService != nil {
+		data[captchaSiteKeyName] = captchaService.SiteKey()
+		data[captchaResponseFieldName] = captchaService.ResponseField()
+	}
+}
+
+// always succeed when captcha is disabled (nil service)
+func CheckCaptcha(captchaService captchaservice.CaptchaService, c *gin.Context) error {
+	if captchaService == nil {
+		return nil
+	}
+
+	success, err := captchaService.Verify(c.Request.Context(), c.PostForm(captchaService.ResponseField()), common.ClientIP(c))
+	if err != nil {
+		common.LogOriginalError(GetLogger(c), err)
+		return common.ErrTechnical
+	}
+	if !success {
+		return common.ErrCaptchaFailed
+	}
+	return nil
+}
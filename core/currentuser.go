@@ -0,0 +1,67 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package puzzleweb
+
+import (
+	"strconv"
+
+	"github.com/dvaumoron/puzzleweb/common"
+	profileservice "github.com/dvaumoron/puzzleweb/profile/service"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const currentUserName = "CurrentUser"
+
+type CurrentUser struct {
+	Id         uint64
+	Login      string
+	Desc       string
+	PictureUrl string
+}
+
+// load the profile of the connected user (at most once by request), does nothing for anonymous request
+func NewCurrentUserAdder(profileService profileservice.ProfileService) common.DataAdder {
+	return func(data gin.H, c *gin.Context) {
+		if currentUser, ok := c.Get(currentUserName); ok {
+			data[currentUserName] = currentUser
+			return
+		}
+
+		userId, _ := data[common.UserIdName].(uint64)
+		if userId == 0 {
+			return
+		}
+
+		// the page is displayed even without the profile
+		profiles, err := profileService.GetProfiles(c.Request.Context(), []uint64{userId})
+		if err != nil {
+			GetLogger(c).Warn("Failed to retrieve current user profile", zap.Error(err))
+			return
+		}
+
+		profile := profiles[userId]
+		currentUser := CurrentUser{
+			Id: userId, Login: profile.Login, Desc: profile.Desc,
+			PictureUrl: "/profile/picture/" + strconv.FormatUint(userId, 10),
+		}
+		c.Set(currentUserName, currentUser)
+		data[currentUserName] = currentUser
+	}
+}
@@ -0,0 +1,72 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package puzzleweb
+
+import (
+	"github.com/dvaumoron/puzzleweb/common"
+	"github.com/dvaumoron/puzzleweb/common/config"
+	"github.com/gin-gonic/gin"
+)
+
+type dashboardWidget struct {
+	displayHandler gin.HandlerFunc
+}
+
+func (w dashboardWidget) LoadInto(router gin.IRouter) {
+	router.GET("/", w.displayHandler)
+}
+
+// aggregate the content authored by the current user
+// (the wiki service does not allow to list edits by user, so only blog posts are displayed)
+func newDashboardPage(profileConfig config.ProfileConfig) Page {
+	blogService := profileConfig.BlogService
+	blogUrl := profileConfig.BlogUrl
+	defaultPageSize := profileConfig.PageSize
+	maxPageSize := profileConfig.MaxPageSize
+
+	p := MakeHiddenPage("dashboard")
+	p.Widget = dashboardWidget{
+		displayHandler: CreateTemplate(func(data gin.H, c *gin.Context) (string, string) {
+			userId, _ := data[common.UserIdName].(uint64)
+			if userId == 0 {
+				loginUrl, _ := data[loginUrlName].(string)
+				return "", loginUrl
+			}
+
+			if blogService != nil {
+				logger := GetLogger(c)
+				ctx := c.Request.Context()
+				pageNumber, pageSize, start, end, _ := common.GetPagination(defaultPageSize, maxPageSize, c)
+
+				total, posts, err := blogService.GetPostsByAuthor(ctx, userId, userId, start, end)
+				if err != nil {
+					return "", common.DefaultErrorRedirect(logger, err.Error())
+				}
+
+				common.InitPagination(data, "", pageNumber, pageSize, total, c)
+				data["Posts"] = posts
+				data["BlogUrl"] = blogUrl
+				data[common.AllowedToDeleteName] = blogService.DeleteRight(ctx, userId)
+				InitNoELementMsg(data, len(posts), c)
+			}
+			return "dashboard", ""
+		}),
+	}
+	return p
+}
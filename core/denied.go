@@ -0,0 +1,66 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package puzzleweb
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/dvaumoron/puzzleweb/common"
+	"github.com/dvaumoron/puzzleweb/common/config"
+	"github.com/dvaumoron/puzzleweb/templates"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+)
+
+const forbiddenTmpl = "forbidden"
+
+// return nil with the error mode (the not authorized redirect is kept)
+func newAccessDeniedHandler(mode string) gin.HandlerFunc {
+	switch mode {
+	case config.AccessDeniedLogin:
+		return makeAccessDeniedHandler(func(c *gin.Context) {
+			c.Redirect(http.StatusFound, common.DefaultErrorRedirect(GetLogger(c), common.ErrorNotAuthorizedKey))
+		})
+	case config.AccessDeniedForbidden:
+		return makeAccessDeniedHandler(func(c *gin.Context) {
+			otelgin.HTML(c, http.StatusForbidden, forbiddenTmpl, templates.ContextAndData{
+				Ctx: c.Request.Context(), Data: initData(c),
+			})
+		})
+	}
+	return nil
+}
+
+// anonymous user are sent to the login page, which will bring them back after the connection
+func makeAccessDeniedHandler(loggedHandler gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if GetSessionUserId(c) != 0 {
+			loggedHandler(c)
+			return
+		}
+
+		loginUrl := "/login"
+		// a form submission can not be replayed, so only a read is brought back
+		if c.Request.Method == http.MethodGet {
+			loginUrl += "?Redirect=" + url.QueryEscape(c.Request.URL.RequestURI())
+		}
+		c.Redirect(http.StatusFound, loginUrl)
+	}
+}
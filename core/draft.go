@@ -0,0 +1,87 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package puzzleweb
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/dvaumoron/puzzleweb/common"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const draftPrefix = "Draft/"
+
+// the user id is part of the session key, so a draft is never restored for another account
+func draftUserPrefix(userId uint64) string {
+	return draftPrefix + strconv.FormatUint(userId, 10) + "/"
+}
+
+// store the values of an unsaved edit form in the session of the connected user,
+// the size limit applies to all the drafts of the user (the session is loaded by every request)
+func SaveDraft(c *gin.Context, key string, values url.Values) error {
+	userId := GetSessionUserId(c)
+	if userId == 0 {
+		return common.ErrNotAuthorized
+	}
+
+	userPrefix := draftUserPrefix(userId)
+	sessionKey := userPrefix + key
+	encoded := values.Encode()
+	session := GetSession(c)
+	size := len(encoded)
+	for otherKey, value := range session.AsMap() {
+		if otherKey != sessionKey && strings.HasPrefix(otherKey, userPrefix) {
+			size += len(value)
+		}
+	}
+	if uint64(size) > getSite(c).maxDraftSize {
+		return common.ErrDraftTooBig
+	}
+	session.Store(sessionKey, encoded)
+	return nil
+}
+
+// nil when there is no draft
+func LoadDraft(c *gin.Context, key string) url.Values {
+	userId := GetSessionUserId(c)
+	if userId == 0 {
+		return nil
+	}
+
+	encoded := GetSession(c).Load(draftUserPrefix(userId) + key)
+	if encoded == "" {
+		return nil
+	}
+
+	values, err := url.ParseQuery(encoded)
+	if err != nil {
+		GetLogger(c).Warn("Failed to decode draft", zap.String("key", key), zap.Error(err))
+		return nil
+	}
+	return values
+}
+
+func ClearDraft(c *gin.Context, key string) {
+	if userId := GetSessionUserId(c); userId != 0 {
+		GetSession(c).Delete(draftUserPrefix(userId) + key)
+	}
+}
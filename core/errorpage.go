@@ -0,0 +1,45 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package puzzleweb
+
+import (
+	"github.com/dvaumoron/puzzleweb/common"
+	"github.com/dvaumoron/puzzleweb/templates"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+)
+
+const errorTmpl = "error"
+
+// write the localization key of err with its status (see common.AppError),
+// in json when the client prefers it, with the error template otherwise
+func WriteAppError(c *gin.Context, err error) {
+	status := common.ErrorStatus(err)
+	errorMsg := common.FilterErrorMsg(GetLogger(c), err.Error())
+	if c.NegotiateFormat(gin.MIMEHTML, gin.MIMEJSON) == gin.MIMEJSON {
+		c.JSON(status, gin.H{common.ErrorKey: errorMsg})
+		return
+	}
+
+	data := initData(c)
+	data[errorMsgName] = errorMsg
+	otelgin.HTML(c, status, errorTmpl, templates.ContextAndData{
+		Ctx: c.Request.Context(), Data: data,
+	})
+}
@@ -0,0 +1,142 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package puzzleweb
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	adminservice "github.com/dvaumoron/puzzleweb/admin/service"
+	blogservice "github.com/dvaumoron/puzzleweb/blog/service"
+	"github.com/dvaumoron/puzzleweb/common"
+	"github.com/dvaumoron/puzzleweb/common/config"
+	"github.com/dvaumoron/puzzleweb/common/log"
+	profileservice "github.com/dvaumoron/puzzleweb/profile/service"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const exportBatchSize = 100
+
+const exportDisposition = "attachment; filename=\"personal-data.json\""
+
+const commentsNotSupported = "the comment service does not allow a listing by author"
+
+// a failing section carry an error note instead of failing the whole export
+type ExportSection struct {
+	Data  any    `json:"data,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// the error note is filtered (the original one is logged)
+func makeExportSection(logger log.Logger, data any, err error) ExportSection {
+	if err != nil {
+		return ExportSection{Error: common.FilterErrorMsg(logger, err.Error())}
+	}
+	return ExportSection{Data: data}
+}
+
+type UserExport struct {
+	UserId     uint64        `json:"userId"`
+	ExportedAt time.Time     `json:"exportedAt"`
+	Profile    ExportSection `json:"profile"`
+	Settings   ExportSection `json:"settings"`
+	Posts      ExportSection `json:"posts"`
+	Comments   ExportSection `json:"comments"`
+	Roles      ExportSection `json:"roles"`
+}
+
+type ExportedPost struct {
+	PostId  uint64 `json:"postId"`
+	Date    string `json:"date"`
+	Title   string `json:"title"`
+	Content string `json:"content"`
+}
+
+type dataExporter struct {
+	profileService  profileservice.ProfileService
+	adminService    adminservice.AdminService
+	blogService     blogservice.BlogService // nil when no blog is linked to profiles
+	settingsManager *SettingsManager
+}
+
+func newDataExporter(profileConfig config.ProfileConfig, settingsManager *SettingsManager) dataExporter {
+	return dataExporter{
+		profileService: profileConfig.Service, adminService: profileConfig.AdminService,
+		blogService: profileConfig.BlogService, settingsManager: settingsManager,
+	}
+}
+
+func (e dataExporter) export(ctx context.Context, logger log.Logger, userId uint64) UserExport {
+	res := UserExport{UserId: userId, ExportedAt: time.Now()}
+
+	profiles, err := e.profileService.GetProfiles(ctx, []uint64{userId})
+	res.Profile = makeExportSection(logger, profiles[userId], err)
+
+	settings, err := e.settingsManager.Service.Get(ctx, userId)
+	res.Settings = makeExportSection(logger, settings, err)
+
+	posts, err := e.exportPosts(ctx, userId)
+	res.Posts = makeExportSection(logger, posts, err)
+	res.Comments = ExportSection{Error: commentsNotSupported}
+
+	// asking for its own roles does not need the admin right
+	roles, err := e.adminService.GetUserRoles(ctx, userId, userId)
+	res.Roles = makeExportSection(logger, roles, err)
+	return res
+}
+
+func (e dataExporter) exportPosts(ctx context.Context, userId uint64) ([]ExportedPost, error) {
+	if e.blogService == nil {
+		return []ExportedPost{}, nil
+	}
+
+	var posts []ExportedPost
+	for start := uint64(0); ; start += exportBatchSize {
+		total, batch, err := e.blogService.GetPostsByAuthor(ctx, userId, userId, start, start+exportBatchSize)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, post := range batch {
+			posts = append(posts, ExportedPost{PostId: post.PostId, Date: post.Date, Title: post.Title, Content: post.Content})
+		}
+		if len(batch) == 0 || start+exportBatchSize >= total {
+			return posts, nil
+		}
+	}
+}
+
+func (e dataExporter) exportHandler(c *gin.Context) {
+	logger := GetLogger(c)
+	userId := GetSessionUserId(c)
+	if userId == 0 {
+		c.Redirect(http.StatusFound, common.DefaultErrorRedirect(logger, unknownUserKey))
+		return
+	}
+
+	c.Header("Content-Disposition", exportDisposition)
+	c.Header("Content-Type", "application/json; charset=utf-8")
+	c.Status(http.StatusOK)
+	if err := json.NewEncoder(c.Writer).Encode(e.export(c.Request.Context(), logger, userId)); err != nil {
+		logger.Error("Failed to write personal data export", zap.Error(err))
+	}
+}
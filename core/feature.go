@@ -0,0 +1,68 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package puzzleweb
+
+import (
+	"maps"
+
+	"github.com/dvaumoron/puzzleweb/common"
+	"github.com/gin-gonic/gin"
+)
+
+// setting key prefix for the user choice on a feature
+const userFeaturePrefix = "feature."
+
+type featureResolver struct {
+	features        map[string]bool // shared, must not be modified
+	userFeatures    []string
+	settingsManager *SettingsManager
+}
+
+func newFeatureResolver(features []string, userFeatures []string, settingsManager *SettingsManager) featureResolver {
+	featureMap := make(map[string]bool, len(features))
+	for _, name := range features {
+		featureMap[name] = true
+	}
+	return featureResolver{features: featureMap, userFeatures: userFeatures, settingsManager: settingsManager}
+}
+
+func (r featureResolver) resolve(c *gin.Context) {
+	features := r.features
+	// settings are only loaded when some features can be choosen by user
+	if userId := GetSessionUserId(c); userId != 0 && len(r.userFeatures) != 0 {
+		// keep the features of the site when the settings can not be read
+		if userSettings, err := r.settingsManager.lookup(c.Request.Context(), userId, c); err == nil {
+			features = maps.Clone(features)
+			for _, name := range r.userFeatures {
+				switch userSettings[userFeaturePrefix+name] {
+				case "true":
+					features[name] = true
+				case "false":
+					delete(features, name)
+				}
+			}
+		}
+	}
+	c.Set(common.FeaturesName, features)
+}
+
+func addFeatures(data gin.H, c *gin.Context) {
+	featuresUntyped, _ := c.Get(common.FeaturesName)
+	data[common.FeaturesName] = featuresUntyped
+}
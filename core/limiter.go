@@ -0,0 +1,83 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package puzzleweb
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// seconds advised to the shed clients
+const shedRetryAfter = "1"
+
+// cap the concurrent requests to protect the slow backends
+type loadLimiter struct {
+	slots    chan struct{}
+	wait     time.Duration
+	inFlight atomic.Int64
+	shed     atomic.Uint64
+}
+
+// return nil when maxInFlight is 0
+func newLoadLimiter(maxInFlight int, wait time.Duration) *loadLimiter {
+	if maxInFlight <= 0 {
+		return nil
+	}
+	return &loadLimiter{slots: make(chan struct{}, maxInFlight), wait: wait}
+}
+
+func (l *loadLimiter) limit(c *gin.Context) {
+	select {
+	case l.slots <- struct{}{}:
+	default:
+		// saturated, queue briefly before shedding
+		timer := time.NewTimer(l.wait)
+		select {
+		case l.slots <- struct{}{}:
+			timer.Stop()
+		case <-timer.C:
+			l.shed.Add(1)
+			c.Header("Retry-After", shedRetryAfter)
+			c.AbortWithStatus(http.StatusServiceUnavailable)
+			return
+		case <-c.Request.Context().Done():
+			timer.Stop()
+			c.Abort()
+			return
+		}
+	}
+
+	l.inFlight.Add(1)
+	defer func() {
+		l.inFlight.Add(-1)
+		<-l.slots
+	}()
+	c.Next()
+}
+
+// current in flight requests and total of shed requests (always 0 when the limit is disabled)
+func (site *Site) LoadStats() (int64, uint64) {
+	if l := site.loadLimiter; l != nil {
+		return l.inFlight.Load(), l.shed.Load()
+	}
+	return 0, 0
+}
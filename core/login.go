@@ -20,17 +20,19 @@ package puzzleweb
 
 import (
 	"net/url"
-	"strconv"
+	"time"
 
 	"github.com/dvaumoron/puzzleweb/common"
 	"github.com/dvaumoron/puzzleweb/common/config"
 	"github.com/dvaumoron/puzzleweb/locale"
+	profileservice "github.com/dvaumoron/puzzleweb/profile/service"
 	"github.com/gin-gonic/gin"
 )
 
 const (
 	userIdName           = "UserId"
 	loginName            = "Login" // current connected user login
+	loginTimeName        = "LoginTime"
 	passwordName         = "Password"
 	confirmPasswordName  = "ConfirmPassword"
 	loginUrlName         = "LoginUrl"
@@ -41,21 +43,36 @@ type loginWidget struct {
 	displayHandler gin.HandlerFunc
 	submitHandler  gin.HandlerFunc
 	logoutHandler  gin.HandlerFunc
+	verifyHandler  gin.HandlerFunc // nil when the email verification is disabled
+	resendHandler  gin.HandlerFunc // nil when the resend is disabled
 }
 
 func (w loginWidget) LoadInto(router gin.IRouter) {
 	router.GET("/", w.displayHandler)
 	router.POST("/submit", w.submitHandler)
 	router.GET("/logout", w.logoutHandler)
+	if w.verifyHandler != nil {
+		router.GET("/verify", w.verifyHandler)
+	}
+	if w.resendHandler != nil {
+		router.POST("/verify/resend", w.resendHandler)
+	}
 }
 
-func newLoginPage(loginConfig config.LoginConfig, settingsManager *SettingsManager) Page {
+func newLoginPage(loginConfig config.LoginConfig, settingsManager *SettingsManager, verifier *emailVerifier) Page {
 	loginService := loginConfig.Service
+	captchaService := loginConfig.CaptchaService
+
+	var verifyHandler, resendHandler gin.HandlerFunc
+	if verifier != nil {
+		verifyHandler = verifier.createVerifyHandler()
+		resendHandler = verifier.createResendHandler()
+	}
 
 	p := MakeHiddenPage("login")
 	p.Widget = loginWidget{
 		displayHandler: CreateTemplate(func(data gin.H, c *gin.Context) (string, string) {
-			data[common.RedirectName] = c.Query(common.RedirectName)
+			data[common.RedirectName] = common.SafeRedirectTarget(c.Query(common.RedirectName))
 
 			currentUrl := c.Request.URL
 			errorKey := common.AddQueryError
@@ -66,6 +83,7 @@ func newLoginPage(loginConfig config.LoginConfig, settingsManager *SettingsManag
 
 			// To hide the connection link
 			delete(data, loginUrlName)
+			InitCaptcha(data, captchaService)
 
 			return "login", ""
 		}),
@@ -88,8 +106,14 @@ func newLoginPage(loginConfig config.LoginConfig, settingsManager *SettingsManag
 				if c.PostForm(confirmPasswordName) != password {
 					return c.PostForm(prevUrlWithErrorName) + common.ErrorWrongConfirmPasswordKey
 				}
+				if err := CheckCaptcha(captchaService, c); err != nil {
+					return c.PostForm(prevUrlWithErrorName) + err.Error()
+				}
 
 				userId, err = loginService.Register(ctx, login, password)
+				if email := c.PostForm(profileservice.EmailInfoName); err == nil && verifier != nil && email != "" {
+					verifier.registerEmail(c, userId, email)
+				}
 			} else {
 				userId, err = loginService.Verify(ctx, login, password)
 			}
@@ -100,18 +124,20 @@ func newLoginPage(loginConfig config.LoginConfig, settingsManager *SettingsManag
 
 			s := GetSession(c)
 			s.Store(loginName, login)
-			s.Store(userIdName, strconv.FormatUint(userId, 10))
+			s.StoreUint64(userIdName, userId)
+			s.StoreUint64(loginTimeName, uint64(time.Now().Unix()))
 
 			GetLocalesManager(c).SetLangCookie(settingsManager.Get(ctx, userId, c)[locale.LangName], c)
 
+			// come back to the page which required the connection
 			return c.PostForm(common.RedirectName)
 		}),
 		logoutHandler: common.CreateRedirect(func(c *gin.Context) string {
-			s := GetSession(c)
-			s.Delete(loginName)
-			s.Delete(userIdName)
+			logOut(GetSession(c))
 			return c.Query(common.RedirectName)
 		}),
+		verifyHandler: verifyHandler,
+		resendHandler: resendHandler,
 	}
 	return p
 }
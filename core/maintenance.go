@@ -0,0 +1,86 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package puzzleweb
+
+import (
+	"net/http"
+	"strings"
+
+	adminservice "github.com/dvaumoron/puzzleweb/admin/service"
+	"github.com/dvaumoron/puzzleweb/common/config"
+	"github.com/dvaumoron/puzzleweb/templates"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+)
+
+const maintenanceMsgName = "MaintenanceMsg"
+
+// path prefixes still served during maintenance (admin can login and disable it)
+var maintenanceAllowedPrefixes = []string{
+	"/admin", "/login", "/static", "/langPicture", config.DefaultFavicon, config.ManifestUrl, config.ServiceWorkerUrl,
+	config.HighlightCSSUrl,
+}
+
+type maintenanceState struct {
+	message string
+}
+
+// an empty message use the default one of the template
+func (site *Site) SetMaintenance(enabled bool, message string) {
+	if enabled {
+		site.maintenance.Store(&maintenanceState{message: message})
+	} else {
+		site.maintenance.Store(nil)
+	}
+}
+
+func (site *Site) InMaintenance() bool {
+	return site.maintenance.Load() != nil
+}
+
+func (site *Site) checkMaintenance(c *gin.Context) {
+	state := site.maintenance.Load()
+	if state == nil || maintenanceAllowed(c.Request.URL.Path) {
+		return
+	}
+
+	err := site.authService.AuthQuery(
+		c.Request.Context(), GetSessionUserId(c), adminservice.AdminGroupId, adminservice.ActionAccess,
+	)
+	if err == nil {
+		return
+	}
+
+	data := initData(c)
+	data[maintenanceMsgName] = state.message
+	otelgin.HTML(c, http.StatusServiceUnavailable, "maintenance", templates.ContextAndData{
+		Ctx: c.Request.Context(), Data: data,
+	})
+	c.Abort()
+}
+
+func maintenanceAllowed(path string) bool {
+	for _, prefix := range maintenanceAllowedPrefixes {
+		// the prefix must end on a path segment ("/administrator" is not allowed)
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package puzzleweb
+
+import "testing"
+
+func TestMaintenanceAllowed(t *testing.T) {
+	cases := map[string]bool{
+		"/admin":             true,
+		"/admin/user/list":   true,
+		"/login":             true,
+		"/static/style.css":  true,
+		"/favicon.ico":       true,
+		"/langPicture/fr":    true,
+		"/administrator":     false,
+		"/admin-foo":         false,
+		"/loginx":            false,
+		"/favicon.ico.evil":  false,
+		"/blog/admin":        false,
+		"/":                  false,
+		"/staticfile/../x":   false,
+		"/static-evil/x.css": false,
+	}
+	for path, want := range cases {
+		if got := maintenanceAllowed(path); got != want {
+			t.Errorf("maintenanceAllowed(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
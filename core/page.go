@@ -20,6 +20,7 @@ package puzzleweb
 
 import (
 	"net/http"
+	"slices"
 	"strings"
 
 	adminservice "github.com/dvaumoron/puzzleweb/admin/service"
@@ -36,10 +37,56 @@ type Widget interface {
 	LoadInto(gin.IRouter)
 }
 
+// return an empty string to allow the access or the redirect target
+type AccessChecker func(*gin.Context) string
+
+// evaluated in order, the first redirect wins
+func ComposeAccessCheckers(checkers ...AccessChecker) AccessChecker {
+	checkers = slices.DeleteFunc(slices.Clone(checkers), func(checker AccessChecker) bool {
+		return checker == nil
+	})
+
+	switch len(checkers) {
+	case 0:
+		return nil
+	case 1:
+		return checkers[0]
+	}
+	return func(c *gin.Context) string {
+		for _, checker := range checkers {
+			if redirect := checker(c); redirect != "" {
+				return redirect
+			}
+		}
+		return ""
+	}
+}
+
+// same check as the static pages
+func GroupAccessChecker(groupId uint64) AccessChecker {
+	return func(c *gin.Context) string {
+		site := getSite(c)
+		err := site.authService.AuthQuery(c.Request.Context(), GetSessionUserId(c), groupId, adminservice.ActionAccess)
+		if err != nil {
+			return common.DefaultErrorRedirect(GetLogger(c), err.Error())
+		}
+		return ""
+	}
+}
+
+func (checker AccessChecker) handle(c *gin.Context) {
+	if redirect := checker(c); redirect != "" {
+		common.WriteRedirect(c, redirect)
+		c.Abort()
+	}
+}
+
 type Page struct {
 	name    string
 	visible bool
 	Widget  Widget
+	// optional, evaluated before any route of the page (and its sub pages)
+	AccessChecker AccessChecker
 }
 
 func MakePage(name string) Page {
@@ -59,15 +106,38 @@ func (w *staticWidget) addSubPage(page Page) {
 	w.subPages = append(w.subPages, page)
 }
 
+func (w *staticWidget) removeSubPage(name string) bool {
+	size := len(w.subPages)
+	w.subPages = slices.DeleteFunc(w.subPages, func(page Page) bool {
+		return page.name == name
+	})
+	return len(w.subPages) != size
+}
+
+func (w *staticWidget) replaceSubPage(page Page) bool {
+	index := slices.IndexFunc(w.subPages, func(sub Page) bool {
+		return sub.name == page.name
+	})
+	if index == -1 {
+		return false
+	}
+	w.subPages[index] = page
+	return true
+}
+
 func (w *staticWidget) LoadInto(router gin.IRouter) {
 	router.GET("/", w.displayHandler)
 	for _, page := range w.subPages {
-		page.Widget.LoadInto(router.Group("/" + page.name))
+		group := router.Group("/" + page.name)
+		if checker := page.AccessChecker; checker != nil {
+			group.Use(checker.handle)
+		}
+		page.Widget.LoadInto(group)
 	}
 }
 
 func newStaticWidget(groupId uint64, templateName string) *staticWidget {
-	return &staticWidget{displayHandler: CreateTemplate(func(data gin.H, c *gin.Context) (string, string) {
+	return &staticWidget{displayHandler: cacheAnonymous(CreateTemplate(func(data gin.H, c *gin.Context) (string, string) {
 		site := getSite(c)
 		ctx := c.Request.Context()
 		logger := site.loggerGetter.Logger(ctx)
@@ -86,7 +156,7 @@ func newStaticWidget(groupId uint64, templateName string) *staticWidget {
 			return builder.String(), ""
 		}
 		return templateName, ""
-	})}
+	}))}
 }
 
 func MakeStaticPage(name string, groupId uint64, templateName string) Page {
@@ -101,14 +171,34 @@ func MakeHiddenStaticPage(name string, groupId uint64, templateName string) Page
 	return p
 }
 
-func (p Page) AddSubPage(page Page) bool {
+// the optional checkers are added to the one of the sub page
+func (p Page) AddSubPage(page Page, checkers ...AccessChecker) bool {
 	sw, ok := p.Widget.(*staticWidget)
 	if ok {
+		page.AccessChecker = ComposeAccessCheckers(append([]AccessChecker{page.AccessChecker}, checkers...)...)
 		sw.addSubPage(page)
 	}
 	return ok
 }
 
+// like AddSubPage, must be called before Site.Run (the page tree is not synchronized
+// and the routes are registered when the site starts), return true when a sub page with that name existed
+func (p Page) RemoveSubPage(name string) bool {
+	sw, ok := p.Widget.(*staticWidget)
+	return ok && sw.removeSubPage(name)
+}
+
+// replace the sub page with the same name (same restriction as RemoveSubPage), return true when it existed,
+// the optional checkers are added like with AddSubPage
+func (p Page) ReplaceSubPage(page Page, checkers ...AccessChecker) bool {
+	sw, ok := p.Widget.(*staticWidget)
+	if !ok {
+		return false
+	}
+	page.AccessChecker = ComposeAccessCheckers(append([]AccessChecker{page.AccessChecker}, checkers...)...)
+	return sw.replaceSubPage(page)
+}
+
 func (p Page) AddStaticPages(pageGroup parser.StaticPagesConfig) bool {
 	for _, pagePath := range pageGroup.Locations {
 		subPage, pageName, templateName, ok := p.extractSubPageAndNamesFromPath(pagePath)
@@ -185,7 +275,7 @@ func CreateTemplate(redirecter common.TemplateRedirecter) gin.HandlerFunc {
 				Ctx: c.Request.Context(), Data: data,
 			})
 		} else {
-			c.Redirect(http.StatusFound, redirect)
+			common.WriteRedirect(c, common.SafeRedirectTarget(redirect))
 		}
 	}
 }
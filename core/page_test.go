@@ -0,0 +1,94 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package puzzleweb
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/dvaumoron/puzzleweb/common/config/parser"
+	"github.com/gin-gonic/gin"
+)
+
+func TestReplaceSubPageCheckers(t *testing.T) {
+	root := MakeStaticPage("root", 0, "root")
+	if !root.AddSubPage(MakePage("sub")) {
+		t.Fatal("failed to add the sub page")
+	}
+
+	var calls []string
+	makeChecker := func(name string, redirect string) AccessChecker {
+		return func(*gin.Context) string {
+			calls = append(calls, name)
+			return redirect
+		}
+	}
+	replacement := MakePage("sub")
+	replacement.AccessChecker = makeChecker("own", "")
+	if !root.ReplaceSubPage(replacement, makeChecker("first", ""), makeChecker("second", "/login")) {
+		t.Fatal("the sub page should exist")
+	}
+
+	sub, _ := root.GetSubPage("sub")
+	if sub.AccessChecker == nil {
+		t.Fatal("missing access checker")
+	}
+	if redirect := sub.AccessChecker(nil); redirect != "/login" {
+		t.Errorf("unexpected redirect %q", redirect)
+	}
+	if !slices.Equal(calls, []string{"own", "first", "second"}) {
+		t.Errorf("unexpected calls %v", calls)
+	}
+
+	if root.ReplaceSubPage(MakePage("unknown"), makeChecker("other", "")) {
+		t.Error("an unknown sub page should not be replaced")
+	}
+}
+
+// static pages come from configured paths, a deep tree is resolved without recursion
+func TestAddStaticPagesDeepTree(t *testing.T) {
+	const depth = 1000
+	root := MakeStaticPage("root", 0, "root")
+	locations := make([]string, 0, depth)
+	var pathBuilder strings.Builder
+	for i := 0; i < depth; i++ {
+		if i != 0 {
+			pathBuilder.WriteByte('/')
+		}
+		pathBuilder.WriteString("level")
+		locations = append(locations, pathBuilder.String())
+	}
+	if !root.AddStaticPages(parser.StaticPagesConfig{Locations: locations}) {
+		t.Fatal("failed to add the deep tree")
+	}
+
+	deepest, ok := root.GetSubPageWithPath(locations[depth-1])
+	if !ok || deepest.name != "level" {
+		t.Fatal("the deepest page is not found")
+	}
+	if _, ok = root.GetSubPageWithPath(locations[depth-1] + "/missing"); ok {
+		t.Error("a missing page should not be found")
+	}
+
+	// the parent of a location must exist
+	if root.AddStaticPages(parser.StaticPagesConfig{Locations: []string{"unknown/page"}}) {
+		t.Error("a page under a missing parent should be rejected")
+	}
+}
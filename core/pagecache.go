@@ -0,0 +1,140 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package puzzleweb
+
+import (
+	"bytes"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type cachedPage struct {
+	contentType string
+	body        []byte
+	expiration  time.Time
+}
+
+// in memory cache of rendered pages, only for anonymous viewer
+type pageCache struct {
+	ttl        time.Duration
+	maxEntries int
+	mutex      sync.RWMutex
+	pages      map[string]cachedPage
+}
+
+// return nil (disabled cache) when ttl is 0
+func newPageCache(ttl time.Duration, maxEntries int) *pageCache {
+	if ttl == 0 {
+		return nil
+	}
+	return &pageCache{ttl: ttl, maxEntries: maxEntries, pages: map[string]cachedPage{}}
+}
+
+func (pc *pageCache) get(key string) (cachedPage, bool) {
+	pc.mutex.RLock()
+	defer pc.mutex.RUnlock()
+
+	page, ok := pc.pages[key]
+	if ok && time.Now().After(page.expiration) {
+		return cachedPage{}, false
+	}
+	return page, ok
+}
+
+func (pc *pageCache) set(key string, contentType string, body []byte) {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+
+	now := time.Now()
+	if len(pc.pages) >= pc.maxEntries {
+		for pageKey, page := range pc.pages {
+			if now.After(page.expiration) {
+				delete(pc.pages, pageKey)
+			}
+		}
+		// still full, the page is not cached
+		if len(pc.pages) >= pc.maxEntries {
+			return
+		}
+	}
+	pc.pages[key] = cachedPage{contentType: contentType, body: body, expiration: now.Add(pc.ttl)}
+}
+
+func (pc *pageCache) clear() {
+	if pc == nil {
+		return
+	}
+
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+	clear(pc.pages)
+}
+
+// keep a copy of what is written
+type captureWriter struct {
+	gin.ResponseWriter
+	buffer bytes.Buffer
+}
+
+func (w *captureWriter) Write(data []byte) (int, error) {
+	w.buffer.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *captureWriter) WriteString(s string) (int, error) {
+	w.buffer.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// the rendered page depends on the access right of the viewer,
+// so the cache is only used for anonymous request without query
+// (the key ignores the rights of the anonymous viewer, a page stays served
+// as cached after a change of the public roles until the ttl or ClearPageCache)
+func cacheAnonymous(handler gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		pc := getSite(c).pageCache
+		if pc == nil || c.Request.URL.RawQuery != "" {
+			handler(c)
+			return
+		}
+		if _, logged := GetSession(c).LoadUint64(userIdName); logged {
+			handler(c)
+			return
+		}
+
+		// also refresh the lang cookie
+		key := GetLocalesManager(c).GetLang(c) + ":" + c.Request.URL.Path
+		if page, ok := pc.get(key); ok {
+			c.Data(http.StatusOK, page.contentType, page.body)
+			return
+		}
+
+		writer := &captureWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		handler(c)
+		c.Writer = writer.ResponseWriter
+
+		if writer.Status() == http.StatusOK {
+			pc.set(key, writer.Header().Get("Content-Type"), writer.buffer.Bytes())
+		}
+	}
+}
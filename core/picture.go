@@ -0,0 +1,152 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package puzzleweb
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+
+	"github.com/dvaumoron/puzzleweb/common"
+)
+
+const (
+	gifType  = "image/gif"
+	jpegType = "image/jpeg"
+	pngType  = "image/png"
+
+	// bound the memory of the decoding (8 bytes per pixel at worst) when the configured dimension is smaller,
+	// the picture is resized to that dimension after
+	maxPicturePixels uint64 = 4096 * 4096
+)
+
+// read, check and resize the uploaded picture, the result is encoded in jpeg or png
+func readPicture(picture *multipart.FileHeader, maxSize int64, maxDimension int) ([]byte, error) {
+	if picture.Size > maxSize {
+		return nil, common.ErrPictureTooBig
+	}
+
+	pictureFile, err := picture.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer pictureFile.Close()
+
+	// read one more byte to detect a lying header
+	pictureData, err := io.ReadAll(io.LimitReader(pictureFile, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(pictureData)) > maxSize {
+		return nil, common.ErrPictureTooBig
+	}
+
+	var decodeConfig func(io.Reader) (image.Config, error)
+	var decode func(io.Reader) (image.Image, error)
+	contentType := http.DetectContentType(pictureData)
+	switch contentType {
+	case gifType:
+		decodeConfig, decode = gif.DecodeConfig, gif.Decode
+	case jpegType:
+		decodeConfig, decode = jpeg.DecodeConfig, jpeg.Decode
+	case pngType:
+		decodeConfig, decode = png.DecodeConfig, png.Decode
+	default:
+		return nil, common.ErrWrongPictureFormat
+	}
+
+	// check the header before decoding, a small compressed file can declare huge dimensions
+	pictureConfig, err := decodeConfig(bytes.NewReader(pictureData))
+	if err != nil {
+		return nil, common.ErrWrongPictureFormat
+	}
+	if pictureConfig.Width <= 0 || pictureConfig.Height <= 0 {
+		return nil, common.ErrWrongPictureFormat
+	}
+	maxPixels := max(maxPicturePixels, uint64(maxDimension)*uint64(maxDimension))
+	if uint64(pictureConfig.Width)*uint64(pictureConfig.Height) > maxPixels {
+		return nil, common.ErrPictureTooBig
+	}
+
+	img, err := decode(bytes.NewReader(pictureData))
+	if err != nil {
+		return nil, common.ErrWrongPictureFormat
+	}
+
+	resized, changed := resizePicture(img, maxDimension)
+	if !changed && contentType != gifType {
+		return pictureData, nil
+	}
+
+	var buffer bytes.Buffer
+	if contentType == jpegType {
+		err = jpeg.Encode(&buffer, resized, nil)
+	} else {
+		err = png.Encode(&buffer, resized)
+	}
+	return buffer.Bytes(), err
+}
+
+// area averaging downscale keeping the ratio
+func resizePicture(img image.Image, maxDimension int) (image.Image, bool) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= maxDimension && height <= maxDimension {
+		return img, false
+	}
+
+	newWidth, newHeight := maxDimension, maxDimension
+	if width > height {
+		newHeight = max(1, height*maxDimension/width)
+	} else {
+		newWidth = max(1, width*maxDimension/height)
+	}
+
+	resized := image.NewRGBA64(image.Rect(0, 0, newWidth, newHeight))
+	for y := 0; y < newHeight; y++ {
+		srcMinY := bounds.Min.Y + y*height/newHeight
+		srcMaxY := max(srcMinY+1, bounds.Min.Y+(y+1)*height/newHeight)
+		for x := 0; x < newWidth; x++ {
+			srcMinX := bounds.Min.X + x*width/newWidth
+			srcMaxX := max(srcMinX+1, bounds.Min.X+(x+1)*width/newWidth)
+
+			var r, g, b, a, count uint64
+			for srcY := srcMinY; srcY < srcMaxY; srcY++ {
+				for srcX := srcMinX; srcX < srcMaxX; srcX++ {
+					cr, cg, cb, ca := img.At(srcX, srcY).RGBA()
+					r += uint64(cr)
+					g += uint64(cg)
+					b += uint64(cb)
+					a += uint64(ca)
+					count++
+				}
+			}
+			resized.SetRGBA64(x, y, color.RGBA64{
+				R: uint16(r / count), G: uint16(g / count), B: uint16(b / count), A: uint16(a / count),
+			})
+		}
+	}
+	return resized, true
+}
@@ -0,0 +1,90 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package puzzleweb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"testing"
+
+	"github.com/dvaumoron/puzzleweb/common"
+)
+
+func pictureHeader(t *testing.T, data []byte) *multipart.FileHeader {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("picture", "picture.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(data)
+	writer.Close()
+
+	form, err := multipart.NewReader(&body, writer.Boundary()).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return form.File["picture"][0]
+}
+
+func encodePng(t *testing.T, width int, height int) []byte {
+	var buffer bytes.Buffer
+	if err := png.Encode(&buffer, image.NewGray(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestReadPictureResize(t *testing.T) {
+	data, err := readPicture(pictureHeader(t, encodePng(t, 300, 150)), 1<<20, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Width != 100 || config.Height != 50 {
+		t.Errorf("got %dx%d, want 100x50", config.Width, config.Height)
+	}
+}
+
+func TestReadPictureBomb(t *testing.T) {
+	data := encodePng(t, 1, 1)
+	// the IHDR chunk follows the 8 bytes signature, its data start with the width and the height
+	ihdr := data[8+8 : 8+8+13]
+	binary.BigEndian.PutUint32(ihdr[0:], 100000)
+	binary.BigEndian.PutUint32(ihdr[4:], 100000)
+	binary.BigEndian.PutUint32(data[8+8+13:], crc32.ChecksumIEEE(data[8+4:8+8+13]))
+
+	if _, err := readPicture(pictureHeader(t, data), 1<<20, 256); !errors.Is(err, common.ErrPictureTooBig) {
+		t.Errorf("got %v, want %v", err, common.ErrPictureTooBig)
+	}
+}
+
+func TestReadPictureWrongFormat(t *testing.T) {
+	if _, err := readPicture(pictureHeader(t, []byte("not a picture")), 1<<20, 256); !errors.Is(err, common.ErrWrongPictureFormat) {
+		t.Errorf("got %v, want %v", err, common.ErrWrongPictureFormat)
+	}
+}
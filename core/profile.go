@@ -19,24 +19,30 @@
 package puzzleweb
 
 import (
-	"io"
-	"mime/multipart"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
 
 	"github.com/dvaumoron/puzzleweb/common"
 	"github.com/dvaumoron/puzzleweb/common/config"
+	profileservice "github.com/dvaumoron/puzzleweb/profile/service"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
 
+// picture can change, so the client must revalidate with the ETag
+const pictureCacheControl = "public, max-age=3600, must-revalidate"
+
 type profileWidget struct {
 	defaultHandler        gin.HandlerFunc
 	viewHandler           gin.HandlerFunc
 	linkHandler           gin.HandlerFunc
 	editHandler           gin.HandlerFunc
 	saveHandler           gin.HandlerFunc
+	pictureSaveHandler    gin.HandlerFunc
 	changeLoginHandler    gin.HandlerFunc
 	changePasswordHandler gin.HandlerFunc
 	pictureHandler        gin.HandlerFunc
@@ -56,15 +62,20 @@ func (w profileWidget) LoadInto(router gin.IRouter) {
 	router.GET("/link/*Login", w.linkHandler)
 	router.GET("/edit", w.editHandler)
 	router.POST("/save", w.saveHandler)
+	router.POST("/picture/save", w.pictureSaveHandler)
 	router.POST("/changeLogin", w.changeLoginHandler)
 	router.POST("/changePassword", w.changePasswordHandler)
 	router.GET("/picture/:UserId", w.pictureHandler)
 }
 
-func newProfilePage(profileConfig config.ProfileConfig) Page {
+func newProfilePage(profileConfig config.ProfileConfig, settingsManager *SettingsManager, verifier *emailVerifier) Page {
 	profileService := profileConfig.Service
 	adminService := profileConfig.AdminService
 	loginService := profileConfig.LoginService
+	blogService := profileConfig.BlogService
+	pageSize := profileConfig.PageSize
+	pictureMaxSize := profileConfig.PictureMaxSize
+	pictureMaxDim := profileConfig.PictureMaxDim
 
 	p := MakeHiddenPage("profile")
 	p.Widget = profileWidget{
@@ -79,6 +90,7 @@ func newProfilePage(profileConfig config.ProfileConfig) Page {
 
 			currentUserId, _ := data[common.UserIdName].(uint64)
 			updateRight := viewedUserId == currentUserId
+			viewAdmin, _ := data[viewAdminName].(bool)
 			if !updateRight {
 				if err := profileService.ViewRight(ctx, currentUserId); err != nil {
 					return "", common.DefaultErrorRedirect(logger, err.Error())
@@ -90,17 +102,43 @@ func newProfilePage(profileConfig config.ProfileConfig) Page {
 				return "", common.DefaultErrorRedirect(logger, err.Error())
 			}
 
-			userRoles, err := adminService.GetUserRoles(ctx, currentUserId, viewedUserId)
-			// ignore ErrNotAuthorized
-			if err == common.ErrTechnical {
-				return "", common.DefaultErrorRedirect(logger, common.ErrorTechnicalKey)
-			}
-			if err == nil {
-				data["UserRight"] = displayGroups(userRoles)
+			userProfile, ok := profiles[viewedUserId]
+			if !ok {
+				return "", common.DefaultErrorRedirect(logger, unknownUserKey)
 			}
 
-			userProfile := profiles[viewedUserId]
 			data[common.AllowedToUpdateName] = updateRight
+			if !(updateRight || viewAdmin || settingsManager.IsPublicProfile(ctx, viewedUserId)) {
+				// private profile, only a minimal card
+				data[common.ViewedUserName] = profileservice.UserProfile{User: userProfile.User}
+				data["MinimalProfile"] = true
+				return "profile/view", ""
+			}
+
+			if updateRight || viewAdmin {
+				userRoles, err := adminService.GetUserRoles(ctx, currentUserId, viewedUserId)
+				// ignore ErrNotAuthorized
+				if errors.Is(err, common.ErrTechnical) {
+					return "", common.DefaultErrorRedirect(logger, common.ErrorTechnicalKey)
+				}
+				if err == nil {
+					data["UserRight"] = displayGroups(userRoles, c)
+				}
+			}
+
+			if blogService != nil {
+				// use 0, pageSize because we just need the most recent ones
+				_, posts, err := blogService.GetPostsByAuthor(ctx, currentUserId, viewedUserId, 0, pageSize)
+				switch {
+				case err == nil:
+					data["RecentPosts"] = posts
+				case errors.Is(err, common.ErrNotAuthorized):
+				default:
+					// the profile stay displayable without the posts
+					common.LogOriginalError(logger, err)
+				}
+			}
+
 			data[common.ViewedUserName] = userProfile
 			return "profile/view", ""
 		}),
@@ -140,35 +178,21 @@ func newProfilePage(profileConfig config.ProfileConfig) Page {
 			desc := c.PostForm("userDesc")
 			info := c.PostFormMap("userInfo")
 
-			picture, err := c.FormFile("picture")
-			if err != nil {
-				logger.Error("Failed to retrieve picture file", zap.Error(err))
-				return common.DefaultErrorRedirect(logger, common.ErrorTechnicalKey)
-			}
-
 			ctx := c.Request.Context()
-			if picture != nil {
-				var pictureFile multipart.File
-				pictureFile, err = picture.Open()
-				if err != nil {
-					logger.Error("Failed to open retrieve picture file ", zap.Error(err))
-					return common.DefaultErrorRedirect(logger, common.ErrorTechnicalKey)
-				}
-				defer pictureFile.Close()
-
-				var pictureData []byte
-				pictureData, err = io.ReadAll(pictureFile)
-				if err != nil {
-					logger.Error("Failed to read picture file ", zap.Error(err))
-					return common.DefaultErrorRedirect(logger, common.ErrorTechnicalKey)
-				}
-
-				err = profileService.UpdatePicture(ctx, userId, pictureData)
+			oldEmail := ""
+			if verifier != nil {
+				// a failure only cause a new verification mail
+				oldEmail, _ = verifier.getEmail(ctx, userId)
 			}
 
+			// the picture is optional in this form
+			err := updatePicture(c, profileService, userId, pictureMaxSize, pictureMaxDim, false)
 			if err == nil {
 				err = profileService.UpdateProfile(ctx, userId, desc, info)
 			}
+			if email := info[profileservice.EmailInfoName]; err == nil && verifier != nil && email != "" && email != oldEmail {
+				verifier.sendVerification(c, userId, email)
+			}
 
 			targetBuilder := profileUrlBuilder(userId)
 			if err != nil {
@@ -176,6 +200,19 @@ func newProfilePage(profileConfig config.ProfileConfig) Page {
 			}
 			return targetBuilder.String()
 		}),
+		pictureSaveHandler: common.CreateRedirect(func(c *gin.Context) string {
+			logger := GetLogger(c)
+			userId := GetSessionUserId(c)
+			if userId == 0 {
+				return common.DefaultErrorRedirect(logger, unknownUserKey)
+			}
+
+			targetBuilder := profileUrlBuilder(userId)
+			if err := updatePicture(c, profileService, userId, pictureMaxSize, pictureMaxDim, true); err != nil {
+				common.WriteError(targetBuilder, logger, err.Error())
+			}
+			return targetBuilder.String()
+		}),
 		changeLoginHandler: common.CreateRedirect(func(c *gin.Context) string {
 			logger := GetLogger(c)
 			session := GetSession(c)
@@ -236,6 +273,13 @@ func newProfilePage(profileConfig config.ProfileConfig) Page {
 			}
 
 			data := profileService.GetPicture(c.Request.Context(), userId)
+			etag := pictureEtag(data)
+			c.Header("Cache-Control", pictureCacheControl)
+			c.Header("ETag", etag)
+			if c.GetHeader("If-None-Match") == etag {
+				c.Status(http.StatusNotModified)
+				return
+			}
 			c.Data(http.StatusOK, http.DetectContentType(data), data)
 		},
 	}
@@ -257,3 +301,30 @@ func profileUrlBuilder(userId uint64) *strings.Builder {
 	targetBuilder.WriteString(strconv.FormatUint(userId, 10))
 	return targetBuilder
 }
+
+func updatePicture(c *gin.Context, profileService profileservice.AdvancedProfileService, userId uint64, maxSize int64, maxDim int, mandatory bool) error {
+	picture, err := c.FormFile("picture")
+	if err != nil {
+		if !mandatory && errors.Is(err, http.ErrMissingFile) {
+			return nil
+		}
+		GetLogger(c).Error("Failed to retrieve picture file", zap.Error(err))
+		return common.ErrTechnical
+	}
+
+	pictureData, err := readPicture(picture, maxSize, maxDim)
+	switch {
+	case err == nil:
+	case errors.Is(err, common.ErrPictureTooBig), errors.Is(err, common.ErrWrongPictureFormat):
+		return err
+	default:
+		GetLogger(c).Error("Failed to read picture file", zap.Error(err))
+		return common.ErrTechnical
+	}
+	return profileService.UpdatePicture(c.Request.Context(), userId, pictureData)
+}
+
+func pictureEtag(data []byte) string {
+	hash := sha256.Sum256(data)
+	return "\"" + hex.EncodeToString(hash[:16]) + "\""
+}
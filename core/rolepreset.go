@@ -0,0 +1,73 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package puzzleweb
+
+import (
+	"context"
+	"slices"
+
+	adminservice "github.com/dvaumoron/puzzleweb/admin/service"
+	"github.com/dvaumoron/puzzleweb/common"
+)
+
+const (
+	rolePresetName  = "RolePreset"
+	rolePresetsName = "RolePresets"
+)
+
+func sortedPresetNames(rolePresets map[string][]string) []string {
+	names := common.MapToKeySlice(rolePresets)
+	slices.Sort(names)
+	return names
+}
+
+// add the roles of the preset (when one is choosen) after checking they still exist
+func expandRolePreset(ctx context.Context, adminService adminservice.AdminService, rolePresets map[string][]string, adminId uint64, userId uint64, presetName string, rolesStr []string) ([]string, error) {
+	if presetName == "" {
+		return rolesStr, nil
+	}
+
+	presetRoles, ok := rolePresets[presetName]
+	if !ok {
+		return nil, common.ErrUnknownRolePreset
+	}
+
+	userRoles, allRoles, err := adminService.EditUserRoles(ctx, adminId, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	existingRoles := common.Set[string]{}
+	for _, groups := range [][]adminservice.Group{userRoles, allRoles} {
+		for _, group := range groups {
+			for _, role := range group.Roles {
+				existingRoles.Add(role.Name + "/" + group.Name)
+			}
+		}
+	}
+	for _, roleStr := range presetRoles {
+		if !existingRoles.Contains(roleStr) {
+			return nil, common.ErrUnknownRolePreset
+		}
+	}
+
+	rolesStr = append(rolesStr, presetRoles...)
+	slices.Sort(rolesStr)
+	return slices.Compact(rolesStr), nil
+}
@@ -19,20 +19,27 @@
 package puzzleweb
 
 import (
+	"context"
 	"encoding/base64"
 	"errors"
 	"net/http"
+	"net/url"
 	"strconv"
+	"time"
 
+	"github.com/dvaumoron/puzzleweb/common"
 	"github.com/dvaumoron/puzzleweb/common/config"
 	"github.com/dvaumoron/puzzleweb/common/log"
+	sessionservice "github.com/dvaumoron/puzzleweb/session/service"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
 
 const (
-	cookieName  = "pw_session_id"
-	SessionName = "Session"
+	SessionName  = "Session"
+	lastSeenName = "LastSeen" // unix time, only for a connected user
+	// in seconds, to limit the writes on the session service
+	lastSeenPeriod = 60
 )
 
 var errDecodeTooShort = errors.New("the result from base64 decoding is too short")
@@ -44,12 +51,18 @@ func makeSessionManager(sessionConfig config.SessionConfig) sessionManager {
 }
 
 func (m sessionManager) getSessionId(logger log.Logger, c *gin.Context) (uint64, error) {
-	cookie, err := c.Cookie(cookieName)
+	cookie, err := c.Cookie(m.CookieName)
 	if err != nil {
 		logger.Info("Failed to retrieve session cookie", zap.Error(err))
 		return m.generateSessionCookie(c)
 	}
-	sessionId, err := decodeFromBase64(cookie)
+	// the signature prevent forged or enumerated ids
+	encodedId, ok := common.VerifySignedValue(m.SigningKey, cookie)
+	if !ok {
+		logger.Warn("Invalid signature in session cookie")
+		return m.generateSessionCookie(c)
+	}
+	sessionId, err := decodeFromBase64(encodedId)
 	if err != nil {
 		logger.Info("Failed to parse session cookie", zap.Error(err))
 		return m.generateSessionCookie(c)
@@ -68,7 +81,7 @@ func (m sessionManager) generateSessionCookie(c *gin.Context) (uint64, error) {
 }
 
 func (m sessionManager) setSessionCookie(sessionId uint64, c *gin.Context) {
-	c.SetCookie(cookieName, encodeToBase64(sessionId), m.TimeOut, "/", m.Domain, true, true)
+	c.SetCookie(m.CookieName, common.SignValue(m.SigningKey, encodeToBase64(sessionId)), m.TimeOut, "/", m.Domain, true, true)
 }
 
 func encodeToBase64(i uint64) string {
@@ -111,8 +124,13 @@ func decodeFromBase64(s string) (uint64, error) {
 }
 
 type Session struct {
-	session map[string]string
-	change  bool
+	session     map[string]string
+	dirtyKeys   common.Set[string]
+	deletedKeys common.Set[string]
+}
+
+func newSession(session map[string]string) *Session {
+	return &Session{session: session, dirtyKeys: common.Set[string]{}, deletedKeys: common.Set[string]{}}
 }
 
 func (s *Session) Load(key string) string {
@@ -120,21 +138,61 @@ func (s *Session) Load(key string) string {
 }
 
 func (s *Session) Store(key string, value string) {
+	if value == "" {
+		s.Delete(key)
+		return
+	}
+
 	oldValue := s.session[key]
 	if oldValue != value {
 		s.session[key] = value
-		s.change = true
+		s.dirtyKeys.Add(key)
+		s.deletedKeys.Remove(key)
 	}
 }
 
 func (s *Session) Delete(key string) {
-	_, present := s.session[key]
-	if present {
+	if value := s.session[key]; value != "" {
 		s.session[key] = "" // to allow a deletion in the service
-		s.change = true
+		s.deletedKeys.Add(key)
+		s.dirtyKeys.Remove(key)
 	}
 }
 
+// ok is false when the key is missing or the value is not an uint64
+func (s *Session) LoadUint64(key string) (uint64, bool) {
+	value, err := strconv.ParseUint(s.session[key], 10, 64)
+	return value, err == nil
+}
+
+func (s *Session) StoreUint64(key string, value uint64) {
+	s.Store(key, strconv.FormatUint(value, 10))
+}
+
+// ok is false when the key is missing or the value is not a bool
+func (s *Session) LoadBool(key string) (bool, bool) {
+	value, err := strconv.ParseBool(s.session[key])
+	return value, err == nil
+}
+
+func (s *Session) StoreBool(key string, value bool) {
+	s.Store(key, strconv.FormatBool(value))
+}
+
+func (s *Session) Changed() bool {
+	return len(s.dirtyKeys) != 0 || len(s.deletedKeys) != 0
+}
+
+// Keys stored with a new value since the loading of the session.
+func (s *Session) DirtyKeys() []string {
+	return s.dirtyKeys.Slice()
+}
+
+// Keys deleted since the loading of the session.
+func (s *Session) DeletedKeys() []string {
+	return s.deletedKeys.Slice()
+}
+
 // Writing in the returned map will not be saved.
 func (s *Session) AsMap() map[string]string {
 	return s.session
@@ -151,23 +209,170 @@ func (m sessionManager) manage(c *gin.Context) {
 
 	ctx := c.Request.Context()
 	session, err := m.Service.Get(ctx, sessionId)
+	fallback := false
 	if err != nil {
-		logSessionError(logger, "Failed to retrieve session", sessionId, c)
-		return
+		if session, fallback = m.loadFallback(logger, sessionId, c); !fallback {
+			logSessionError(logger, "Failed to retrieve session", sessionId, c)
+			return
+		}
+		logger.Warn("Failed to retrieve session, using fallback cookie", zap.Uint64("sessionId", sessionId), zap.Error(err))
 	}
 
 	if session == nil {
 		session = map[string]string{}
 	}
 
-	c.Set(SessionName, &Session{session: session}) // change is false (default bool)
+	s := newSession(session)
+	if fallback {
+		// re-hydrate the service session on recovery
+		for key := range session {
+			s.dirtyKeys.Add(key)
+		}
+	}
+	// after the re-hydration, so an expired login is also deleted in the service
+	m.checkLifeTime(logger, s, fallback)
+	if !fallback {
+		m.refreshFallback(sessionId, session, c)
+	}
+
+	c.Set(SessionName, s)
+	c.Set(sessionIdName, sessionId)
 	c.Next()
 
-	if s := GetSession(c); s.change {
-		if m.Service.Update(ctx, sessionId, s.session) != nil {
-			logSessionError(logger, "Failed to save session", sessionId, c)
+	s = GetSession(c)
+	touchLastSeen(s)
+	// no call to the service when nothing changed
+	if s.Changed() {
+		if err = m.saveSession(ctx, sessionId, s); err != nil {
+			if fallback {
+				logger.Warn("Failed to re-hydrate session", zap.Uint64("sessionId", sessionId), zap.Error(err))
+			} else {
+				logSessionError(logger, "Failed to save session", sessionId, c)
+			}
+		}
+	}
+}
+
+// the idle time out is handled by the cookie and the session service, it is also checked with the last seen time
+// (which could be late by one period), and a new authentication is forced when the login is older than the maximum life time
+func (m sessionManager) checkLifeTime(logger log.Logger, s *Session, fallback bool) {
+	if s.Load(userIdName) == "" {
+		return
+	}
+
+	now := time.Now().Unix()
+	if lastSeen, ok := s.LoadUint64(lastSeenName); ok && m.TimeOut > 0 && now-int64(lastSeen) > int64(m.TimeOut+lastSeenPeriod) {
+		logger.Info("Session idle time out exceeded, logging out")
+		logOut(s)
+		return
+	}
+	if m.MaxLifeTime == 0 {
+		return
+	}
+
+	loginTime, ok := s.LoadUint64(loginTimeName)
+	if !ok {
+		if fallback {
+			// nothing is persisted in fallback mode, the login time would be renewed on each request
+			logger.Info("Session login time missing in fallback cookie, logging out")
+			logOut(s)
+			return
+		}
+		// login done before the activation of the limit
+		s.StoreUint64(loginTimeName, uint64(now))
+		return
+	}
+	if now-int64(loginTime) > int64(m.MaxLifeTime) {
+		logger.Info("Session life time exceeded, logging out")
+		logOut(s)
+	}
+}
+
+// a clean session is only updated once by period,
+// a session already changed by the request always get the current time
+func touchLastSeen(s *Session) {
+	if s.Load(userIdName) == "" {
+		return
+	}
+
+	now := time.Now().Unix()
+	if lastSeen, ok := s.LoadUint64(lastSeenName); !ok || s.Changed() || now-int64(lastSeen) >= lastSeenPeriod {
+		s.StoreUint64(lastSeenName, uint64(now))
+	}
+}
+
+func logOut(s *Session) {
+	s.Delete(loginName)
+	s.Delete(userIdName)
+	s.Delete(loginTimeName)
+}
+
+// the fallback cookie carry a signed copy of the login data (when the fallback is enabled),
+// it is bound to the session id and expires with the idle time out
+func (m sessionManager) refreshFallback(sessionId uint64, session map[string]string, c *gin.Context) {
+	if !m.Fallback {
+		return
+	}
+
+	userId, login := session[userIdName], session[loginName]
+	if userId == "" {
+		if _, err := c.Cookie(m.FallbackCookieName); err == nil {
+			c.SetCookie(m.FallbackCookieName, "", -1, "/", m.Domain, true, true)
 		}
+		return
 	}
+
+	fallbackData := url.Values{
+		userIdName: {userId}, loginName: {login}, loginTimeName: {session[loginTimeName]},
+		sessionIdName: {strconv.FormatUint(sessionId, 10)},
+	}.Encode()
+	fallbackToken := m.fallbackSigner().Encode(fallbackData, time.Duration(m.TimeOut)*time.Second)
+	c.SetCookie(m.FallbackCookieName, fallbackToken, m.TimeOut, "/", m.Domain, true, true)
+}
+
+func (m sessionManager) fallbackSigner() common.SignedToken {
+	return common.SignedToken{Key: m.FallbackSigningKey}
+}
+
+// reject a fallback cookie expired or issued for another session
+func (m sessionManager) loadFallback(logger log.Logger, sessionId uint64, c *gin.Context) (map[string]string, bool) {
+	if !m.Fallback {
+		return nil, false
+	}
+
+	cookie, err := c.Cookie(m.FallbackCookieName)
+	if err != nil {
+		return nil, false
+	}
+
+	fallbackData, err := m.fallbackSigner().Decode(cookie)
+	if err != nil {
+		logger.Info("Rejected fallback cookie", zap.Error(err))
+		return nil, false
+	}
+
+	values, err := url.ParseQuery(fallbackData)
+	if err != nil || values.Get(sessionIdName) != strconv.FormatUint(sessionId, 10) {
+		logger.Info("Rejected fallback cookie of another session")
+		return nil, false
+	}
+	return map[string]string{
+		userIdName: values.Get(userIdName), loginName: values.Get(loginName), loginTimeName: values.Get(loginTimeName),
+	}, true
+}
+
+// send only the changes when the service allows it
+func (m sessionManager) saveSession(ctx context.Context, sessionId uint64, s *Session) error {
+	deltaService, ok := m.Service.(sessionservice.DeltaSessionService)
+	if !ok {
+		return m.Service.Update(ctx, sessionId, s.session)
+	}
+
+	changed := make(map[string]string, len(s.dirtyKeys))
+	for key := range s.dirtyKeys {
+		changed[key] = s.session[key]
+	}
+	return deltaService.UpdateDelta(ctx, sessionId, changed, s.DeletedKeys())
 }
 
 func logSessionError(logger log.Logger, msg string, sessionId uint64, c *gin.Context) {
@@ -180,18 +385,23 @@ func GetSession(c *gin.Context) *Session {
 	typed, ok := untyped.(*Session)
 	if !ok {
 		GetLogger(c).Error("There is no session in context")
-		typed = &Session{session: map[string]string{}, change: true}
+		typed = newSession(map[string]string{})
 		c.Set(SessionName, typed)
 	}
 	return typed
 }
 
 func GetSessionUserId(c *gin.Context) uint64 {
-	userId, err := strconv.ParseUint(GetSession(c).Load(userIdName), 10, 64)
-	if err == nil {
+	userId, ok := GetSession(c).LoadUint64(userIdName)
+	if ok {
 		GetLogger(c).Debug("userId parsed from session", zap.Uint64(userIdName, userId))
 	} else {
-		GetLogger(c).Info("Failed to parse userId from session", zap.Error(err))
+		GetLogger(c).Info("Failed to parse userId from session")
 	}
 	return userId
 }
+
+// empty for an anonymous user
+func GetSessionUserLogin(c *gin.Context) string {
+	return GetSession(c).Load(loginName)
+}
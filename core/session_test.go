@@ -0,0 +1,178 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package puzzleweb
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/dvaumoron/puzzleweb/common/config"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func makeTestSessionManager() sessionManager {
+	return makeSessionManager(config.SessionConfig{
+		TimeOut: 3600, SigningKey: []byte("test key"), Fallback: true, CookieName: "session", FallbackCookieName: "fallback",
+		FallbackSigningKey: []byte("test fallback key"),
+	})
+}
+
+// return the value of the fallback cookie set by refreshFallback
+func issueFallbackCookie(t *testing.T, m sessionManager, sessionId uint64, session map[string]string) string {
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	m.refreshFallback(sessionId, session, c)
+	for _, cookie := range recorder.Result().Cookies() {
+		if cookie.Name == m.FallbackCookieName {
+			return cookie.Value
+		}
+	}
+	t.Fatal("no fallback cookie")
+	return ""
+}
+
+func loadTestFallback(m sessionManager, sessionId uint64, cookieValue string) (map[string]string, bool) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	c.Request.AddCookie(&http.Cookie{Name: m.FallbackCookieName, Value: cookieValue})
+	return m.loadFallback(zap.NewNop(), sessionId, c)
+}
+
+func TestFallbackCookie(t *testing.T) {
+	m := makeTestSessionManager()
+	cookieValue := issueFallbackCookie(t, m, 7, map[string]string{userIdName: "3", loginName: "user", loginTimeName: "1000"})
+
+	session, ok := loadTestFallback(m, 7, cookieValue)
+	if !ok {
+		t.Fatal("fallback cookie rejected")
+	}
+	if session[userIdName] != "3" || session[loginName] != "user" || session[loginTimeName] != "1000" {
+		t.Errorf("got %v", session)
+	}
+
+	if _, ok = loadTestFallback(m, 8, cookieValue); ok {
+		t.Error("fallback cookie accepted for another session")
+	}
+	if _, ok = loadTestFallback(m, 7, cookieValue+"0"); ok {
+		t.Error("tampered fallback cookie accepted")
+	}
+
+	staleData := url.Values{userIdName: {"3"}, loginName: {"user"}, sessionIdName: {"7"}}.Encode()
+	if _, ok = loadTestFallback(m, 7, m.fallbackSigner().Encode(staleData, -time.Minute)); ok {
+		t.Error("expired fallback cookie accepted")
+	}
+}
+
+func TestCheckLifeTimeFallback(t *testing.T) {
+	m := makeTestSessionManager()
+	m.MaxLifeTime = 3600
+
+	s := newSession(map[string]string{userIdName: "3", loginName: "user"})
+	m.checkLifeTime(zap.NewNop(), s, false)
+	if s.Load(userIdName) == "" || s.Load(loginTimeName) == "" {
+		t.Error("a stored session without login time should get one")
+	}
+
+	s = newSession(map[string]string{userIdName: "3", loginName: "user"})
+	m.checkLifeTime(zap.NewNop(), s, true)
+	if s.Load(userIdName) != "" {
+		t.Error("a fallback session without login time should be logged out")
+	}
+}
+
+func TestSessionChanges(t *testing.T) {
+	s := newSession(map[string]string{"kept": "1", "same": "a", "removed": "x"})
+	s.Store("same", "a")
+	s.Delete("missing")
+	if s.Changed() {
+		t.Fatal("storing the same value or deleting a missing key should not change the session")
+	}
+
+	s.Store("added", "2")
+	s.Delete("removed")
+	s.Store("kept", "")
+	if !s.Changed() {
+		t.Fatal("the session should be changed")
+	}
+	dirtyKeys, deletedKeys := s.DirtyKeys(), s.DeletedKeys()
+	slices.Sort(deletedKeys)
+	if !slices.Equal(dirtyKeys, []string{"added"}) || !slices.Equal(deletedKeys, []string{"kept", "removed"}) {
+		t.Errorf("got dirty %v and deleted %v", dirtyKeys, deletedKeys)
+	}
+
+	// a key stored again after its deletion is only dirty, and the reverse
+	s.Store("removed", "y")
+	s.Delete("added")
+	dirtyKeys, deletedKeys = s.DirtyKeys(), s.DeletedKeys()
+	slices.Sort(deletedKeys)
+	if !slices.Equal(dirtyKeys, []string{"removed"}) || !slices.Equal(deletedKeys, []string{"added", "kept"}) {
+		t.Errorf("got dirty %v and deleted %v", dirtyKeys, deletedKeys)
+	}
+}
+
+// record the delta sent by saveSession
+type fakeDeltaSessionService struct {
+	changed map[string]string
+	deleted []string
+	updated bool
+}
+
+func (*fakeDeltaSessionService) Generate(context.Context) (uint64, error) {
+	return 1, nil
+}
+
+func (*fakeDeltaSessionService) Get(context.Context, uint64) (map[string]string, error) {
+	return map[string]string{}, nil
+}
+
+func (service *fakeDeltaSessionService) Update(context.Context, uint64, map[string]string) error {
+	service.updated = true
+	return nil
+}
+
+func (service *fakeDeltaSessionService) UpdateDelta(ctx context.Context, id uint64, changed map[string]string, deleted []string) error {
+	service.changed, service.deleted = changed, deleted
+	return nil
+}
+
+func TestSaveSessionDelta(t *testing.T) {
+	service := &fakeDeltaSessionService{}
+	m := makeTestSessionManager()
+	m.Service = service
+
+	s := newSession(map[string]string{"kept": "1", "removed": "x"})
+	s.Store("added", "2")
+	s.Delete("removed")
+	if err := m.saveSession(context.Background(), 1, s); err != nil {
+		t.Fatal(err)
+	}
+	if service.updated {
+		t.Error("the whole session should not be sent")
+	}
+	if len(service.changed) != 1 || service.changed["added"] != "2" || !slices.Equal(service.deleted, []string{"removed"}) {
+		t.Errorf("got changed %v and deleted %v", service.changed, service.deleted)
+	}
+}
@@ -0,0 +1,129 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package puzzleweb
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/dvaumoron/puzzleweb/common"
+	sessionservice "github.com/dvaumoron/puzzleweb/session/service"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	// also the key of the current session id in the gin context
+	sessionIdName = "SessionId"
+	sessionsName  = "Sessions"
+)
+
+type SessionDisplay struct {
+	Id       uint64
+	UserId   uint64
+	Login    string
+	Created  string
+	LastSeen string
+	Current  bool
+}
+
+type sessionLister struct {
+	service    sessionservice.AdminSessionService // nil when the store can not enumerate its sessions
+	dateFormat string
+}
+
+func newSessionLister(sessionService sessionservice.SessionService, dateFormat string) sessionLister {
+	adminSessionService, _ := sessionService.(sessionservice.AdminSessionService)
+	return sessionLister{service: adminSessionService, dateFormat: dateFormat}
+}
+
+// with a userId equal to 0, list the sessions of all users (most recently seen first)
+func (l sessionLister) list(c *gin.Context, userId uint64) ([]SessionDisplay, error) {
+	if l.service == nil {
+		return nil, common.ErrSessionListUnsupported
+	}
+
+	sessions, err := l.service.ListSessions(c.Request.Context())
+	if err != nil {
+		return nil, err
+	}
+	for index, session := range sessions {
+		if session.LastSeen.IsZero() {
+			// the store does not know it, use the time recorded in the session (only for a connected user)
+			if lastSeen, err := strconv.ParseInt(session.Info[lastSeenName], 10, 64); err == nil {
+				sessions[index].LastSeen = time.Unix(lastSeen, 0)
+			}
+		}
+	}
+	slices.SortFunc(sessions, func(a sessionservice.SessionInfo, b sessionservice.SessionInfo) int {
+		return b.LastSeen.Compare(a.LastSeen)
+	})
+
+	currentId := c.GetUint64(sessionIdName)
+	res := make([]SessionDisplay, 0, len(sessions))
+	for _, session := range sessions {
+		sessionUserId, _ := strconv.ParseUint(session.Info[userIdName], 10, 64)
+		if userId != 0 && sessionUserId != userId {
+			continue
+		}
+
+		res = append(res, SessionDisplay{
+			Id: session.Id, UserId: sessionUserId, Login: session.Info[loginName],
+			Created: l.formatTime(session.Created, c), LastSeen: l.formatTime(session.LastSeen, c),
+			Current: session.Id == currentId,
+		})
+	}
+	return res, nil
+}
+
+// with a userId equal to 0, the owner of the session is not checked
+func (l sessionLister) revoke(ctx context.Context, userId uint64, sessionId uint64) error {
+	if l.service == nil {
+		return common.ErrSessionListUnsupported
+	}
+
+	if userId != 0 {
+		info, err := l.service.Get(ctx, sessionId)
+		if err != nil {
+			return err
+		}
+		if info[userIdName] != strconv.FormatUint(userId, 10) {
+			return common.ErrNotAuthorized
+		}
+	}
+	return l.service.DeleteSession(ctx, sessionId)
+}
+
+// the store may not know the time
+func (l sessionLister) formatTime(t time.Time, c *gin.Context) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.In(common.GetTimeZone(c)).Format(l.dateFormat)
+}
+
+func getRequestedSessionId(c *gin.Context) uint64 {
+	sessionId, err := strconv.ParseUint(c.Param(sessionIdName), 10, 64)
+	if err != nil {
+		GetLogger(c).Warn("Failed to parse sessionId from request", zap.Error(err))
+	}
+	return sessionId
+}
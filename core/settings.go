@@ -21,7 +21,9 @@ package puzzleweb
 import (
 	"context"
 	"errors"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/dvaumoron/puzzleweb/common"
 	"github.com/dvaumoron/puzzleweb/common/config"
@@ -31,7 +33,12 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-const settingsName = "Settings"
+const (
+	settingsName         = "Settings"
+	publicProfileName    = "PublicProfile"
+	mailNotificationName = "MailNotification"
+	timeZoneName         = "TimeZone" // empty for the time zone of the site
+)
 
 var errWrongLang = errors.New(common.WrongLangKey)
 
@@ -46,19 +53,75 @@ func NewSettingsManager(settingsConfig config.SettingsConfig) *SettingsManager {
 }
 
 func initSettings(c *gin.Context) map[string]string {
-	return map[string]string{locale.LangName: GetLocalesManager(c).GetLang(c)}
+	return map[string]string{
+		locale.LangName: GetLocalesManager(c).GetLang(c), publicProfileName: "true", mailNotificationName: "true",
+	}
 }
 
 func checkSettings(settings map[string]string, c *gin.Context) error {
+	// an unchecked box is not sent
+	settings[publicProfileName] = strconv.FormatBool(settings[publicProfileName] == "true")
+	settings[mailNotificationName] = strconv.FormatBool(settings[mailNotificationName] == "true")
+
 	askedLang := settings[locale.LangName]
 	lang := GetLocalesManager(c).SetLangCookie(askedLang, c)
 	settings[locale.LangName] = lang
 	if lang != askedLang {
 		return errWrongLang
 	}
+
+	settings[timeZoneName] = strings.TrimSpace(settings[timeZoneName])
+	if _, err := loadUserTimeZone(settings); err != nil {
+		return common.ErrWrongTimeZone
+	}
 	return nil
 }
 
+// nil without time zone setting
+func loadUserTimeZone(userSettings map[string]string) (*time.Location, error) {
+	timeZone := userSettings[timeZoneName]
+	// time.LoadLocation treat the empty string as UTC
+	if timeZone == "" {
+		return nil, nil
+	}
+	return time.LoadLocation(timeZone)
+}
+
+// override the time zone of the site with the one choosen by the user
+func (m *SettingsManager) setUserTimeZone(userId uint64, c *gin.Context) {
+	userSettings, err := m.lookup(c.Request.Context(), userId, c)
+	if err != nil {
+		// keep the time zone of the site
+		return
+	}
+	location, err := loadUserTimeZone(userSettings)
+	if err != nil {
+		GetLogger(c).Warn("Failed to load user time zone", zap.Error(err))
+		return
+	}
+	if location != nil {
+		c.Set(common.TimeZoneName, location)
+	}
+}
+
+// read only access to the user settings, never create them (contrary to Get)
+func (m *SettingsManager) lookup(ctx context.Context, userId uint64, c *gin.Context) (map[string]string, error) {
+	userSettings := c.GetStringMapString(settingsName)
+	if len(userSettings) != 0 {
+		return userSettings, nil
+	}
+
+	userSettings, err := m.Service.Get(ctx, userId)
+	if err != nil {
+		m.LoggerGetter.Logger(ctx).Warn("Failed to retrieve user settings", zap.Error(err))
+		return nil, err
+	}
+	if len(userSettings) != 0 {
+		c.Set(settingsName, userSettings)
+	}
+	return userSettings, nil
+}
+
 func (m *SettingsManager) Get(ctx context.Context, userId uint64, c *gin.Context) map[string]string {
 	userSettings := c.GetStringMapString(settingsName)
 	if len(userSettings) != 0 {
@@ -81,21 +144,49 @@ func (m *SettingsManager) Get(ctx context.Context, userId uint64, c *gin.Context
 	return userSettings
 }
 
+// profile stay public when the setting is missing (user without saved settings)
+func (m *SettingsManager) IsPublicProfile(ctx context.Context, userId uint64) bool {
+	userSettings, err := m.Service.Get(ctx, userId)
+	if err != nil {
+		m.LoggerGetter.Logger(ctx).Warn("Failed to retrieve user settings", zap.Error(err))
+		return false
+	}
+	return userSettings[publicProfileName] != "false"
+}
+
+// return the lang to write the mail in and if the user accept mail notifications (missing setting means yes)
+func (m *SettingsManager) GetMailNotification(ctx context.Context, userId uint64) (string, bool) {
+	userSettings, err := m.Service.Get(ctx, userId)
+	if err != nil {
+		m.LoggerGetter.Logger(ctx).Warn("Failed to retrieve user settings", zap.Error(err))
+		return "", false
+	}
+	return userSettings[locale.LangName], userSettings[mailNotificationName] != "false"
+}
+
 func (m *SettingsManager) Update(ctx context.Context, userId uint64, settings map[string]string) error {
 	return m.Service.Update(ctx, userId, settings)
 }
 
 type settingsWidget struct {
-	editHandler gin.HandlerFunc
-	saveHandler gin.HandlerFunc
+	editHandler          gin.HandlerFunc
+	saveHandler          gin.HandlerFunc
+	listSessionHandler   gin.HandlerFunc
+	revokeSessionHandler gin.HandlerFunc
+	exportHandler        gin.HandlerFunc
+	deleteHandler        gin.HandlerFunc
 }
 
 func (w settingsWidget) LoadInto(router gin.IRouter) {
 	router.GET("/", w.editHandler)
 	router.POST("/save", w.saveHandler)
+	router.GET("/session/list", w.listSessionHandler)
+	router.POST("/session/revoke/:SessionId", w.revokeSessionHandler)
+	router.GET("/export", w.exportHandler)
+	router.POST("/delete", w.deleteHandler)
 }
 
-func newSettingsPage(settingsConfig config.ServiceConfig[*SettingsManager]) Page {
+func newSettingsPage(settingsConfig config.ServiceConfig[*SettingsManager], lister sessionLister, exporter dataExporter, deleter accountDeleter) Page {
 	settingsManager := settingsConfig.Service
 
 	p := MakeHiddenPage("settings")
@@ -117,10 +208,17 @@ func newSettingsPage(settingsConfig config.ServiceConfig[*SettingsManager]) Page
 				return common.DefaultErrorRedirect(logger, unknownUserKey)
 			}
 
+			ctx := c.Request.Context()
 			settings := c.PostFormMap("settings")
+			// the verified email is managed by the server, not by the form
+			delete(settings, verifiedEmailName)
+			if verifiedEmail := settingsManager.Get(ctx, userId, c)[verifiedEmailName]; verifiedEmail != "" {
+				settings[verifiedEmailName] = verifiedEmail
+			}
+
 			err := settingsManager.CheckSettings(settings, c)
 			if err == nil {
-				err = settingsManager.Update(c.Request.Context(), userId, settings)
+				err = settingsManager.Update(ctx, userId, settings)
 			}
 
 			var targetBuilder strings.Builder
@@ -130,6 +228,69 @@ func newSettingsPage(settingsConfig config.ServiceConfig[*SettingsManager]) Page
 			}
 			return targetBuilder.String()
 		}),
+		listSessionHandler: CreateTemplate(func(data gin.H, c *gin.Context) (string, string) {
+			logger := GetLogger(c)
+			userId, _ := data[common.UserIdName].(uint64)
+			if userId == 0 {
+				return "", common.DefaultErrorRedirect(logger, unknownUserKey)
+			}
+
+			// only the sessions of the current user
+			sessions, err := lister.list(c, userId)
+			if err != nil {
+				return "", common.DefaultErrorRedirect(logger, err.Error())
+			}
+
+			data[sessionsName] = sessions
+			return "settings/session/list", ""
+		}),
+		revokeSessionHandler: common.CreateRedirect(func(c *gin.Context) string {
+			logger := GetLogger(c)
+			userId := GetSessionUserId(c)
+			if userId == 0 {
+				return common.DefaultErrorRedirect(logger, unknownUserKey)
+			}
+
+			err := common.ErrTechnical
+			if sessionId := getRequestedSessionId(c); sessionId != 0 {
+				err = lister.revoke(c.Request.Context(), userId, sessionId)
+			}
+
+			var targetBuilder strings.Builder
+			targetBuilder.WriteString("/settings/session/list")
+			if err != nil {
+				common.WriteError(&targetBuilder, logger, err.Error())
+			}
+			return targetBuilder.String()
+		}),
+		exportHandler: exporter.exportHandler,
+		deleteHandler: common.CreateRedirect(func(c *gin.Context) string {
+			logger := GetLogger(c)
+			userId := GetSessionUserId(c)
+			if userId == 0 {
+				return common.DefaultErrorRedirect(logger, unknownUserKey)
+			}
+
+			// the user has to type its login to confirm
+			s := GetSession(c)
+			login := s.Load(loginName)
+			err := common.ErrWrongConfirmation
+			if c.PostForm(confirmationName) == login {
+				err = deleter.deleteOwnAccount(
+					c.Request.Context(), userId, login, c.PostForm(passwordName), c.PostForm(contentPolicyName),
+				)
+			}
+
+			if err != nil {
+				var targetBuilder strings.Builder
+				targetBuilder.WriteString("/settings")
+				common.WriteError(&targetBuilder, logger, err.Error())
+				return targetBuilder.String()
+			}
+
+			logOut(s)
+			return "/"
+		}),
 	}
 	return p
 }
@@ -0,0 +1,92 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package puzzleweb
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dvaumoron/puzzleweb/common"
+	"github.com/dvaumoron/puzzleweb/common/config"
+	"github.com/dvaumoron/puzzleweb/common/log"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type nopLoggerGetter struct{}
+
+func (nopLoggerGetter) Logger(context.Context) log.Logger {
+	return zap.NewNop()
+}
+
+// fail on read and count the writes
+type failingSettingsService struct {
+	updateCount int
+}
+
+func (*failingSettingsService) Generate(context.Context) (uint64, error) {
+	return 0, nil
+}
+
+func (*failingSettingsService) Get(context.Context, uint64) (map[string]string, error) {
+	return nil, errors.New("unavailable")
+}
+
+func (s *failingSettingsService) Update(context.Context, uint64, map[string]string) error {
+	s.updateCount++
+	return nil
+}
+
+func TestSetUserTimeZoneReadFailure(t *testing.T) {
+	service := &failingSettingsService{}
+	manager := NewSettingsManager(config.SettingsConfig{LoggerGetter: nopLoggerGetter{}, Service: service})
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/", nil)
+
+	manager.setUserTimeZone(1, c)
+
+	if service.updateCount != 0 {
+		t.Errorf("settings written %d times, want none", service.updateCount)
+	}
+	if _, exists := c.Get(common.TimeZoneName); exists {
+		t.Error("time zone set despite the read failure")
+	}
+}
+
+func TestResolveFeaturesReadFailure(t *testing.T) {
+	service := &failingSettingsService{}
+	manager := NewSettingsManager(config.SettingsConfig{LoggerGetter: nopLoggerGetter{}, Service: service})
+	resolver := newFeatureResolver([]string{"siteFeature"}, []string{"userFeature"}, manager)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/", nil)
+	c.Set(siteName, &Site{loggerGetter: nopLoggerGetter{}})
+	c.Set(SessionName, newSession(map[string]string{userIdName: "1"}))
+
+	resolver.resolve(c)
+
+	if service.updateCount != 0 {
+		t.Errorf("settings written %d times, want none", service.updateCount)
+	}
+	features, _ := c.Get(common.FeaturesName)
+	if typed, _ := features.(map[string]bool); !typed["siteFeature"] || len(typed) != 1 {
+		t.Errorf("got features %v, want only the site ones", features)
+	}
+}
@@ -0,0 +1,57 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package puzzleweb
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/dvaumoron/puzzleweb/common/config"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	staticVersionName = "StaticVersion"
+	staticAssetsName  = "Assets"
+	immutableMaxAge   = 365 * 24 * time.Hour
+)
+
+// return nil when there is no header to set, a fingerprinted url (?v=version) is cached as immutable
+func newStaticCacheControl(maxAge time.Duration, version string) gin.HandlerFunc {
+	if maxAge == 0 && version == "" {
+		return nil
+	}
+
+	defaultCacheControl := ""
+	if maxAge != 0 {
+		defaultCacheControl = formatCacheControl(maxAge)
+	}
+	immutableCacheControl := formatCacheControl(immutableMaxAge) + ", immutable"
+	return func(c *gin.Context) {
+		if version != "" && c.Query(config.StaticVersionQuery) == version {
+			c.Header("Cache-Control", immutableCacheControl)
+		} else if defaultCacheControl != "" {
+			c.Header("Cache-Control", defaultCacheControl)
+		}
+	}
+}
+
+func formatCacheControl(maxAge time.Duration) string {
+	return "public, max-age=" + strconv.FormatInt(int64(maxAge/time.Second), 10)
+}
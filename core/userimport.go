@@ -0,0 +1,130 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package puzzleweb
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"strings"
+
+	adminservice "github.com/dvaumoron/puzzleweb/admin/service"
+	"github.com/dvaumoron/puzzleweb/common"
+	"github.com/dvaumoron/puzzleweb/common/log"
+	loginservice "github.com/dvaumoron/puzzleweb/login/service"
+)
+
+const (
+	importFileName    = "users"
+	importedUsersName = "ImportedUsers"
+)
+
+// one line of the import report, Error is a message key (empty on success)
+type UserImportResult struct {
+	Line   int
+	Login  string
+	UserId uint64
+	Error  string
+}
+
+// roles are written like in the edit form ("role/group")
+func parseRoles(rolesStr []string) []adminservice.Group {
+	nameToGroup := make(map[string]adminservice.Group, len(rolesStr))
+	for _, roleStr := range rolesStr {
+		splitted := strings.Split(roleStr, "/")
+		if len(splitted) > 1 {
+			groupName := splitted[1]
+			group, ok := nameToGroup[groupName]
+			if !ok {
+				group = adminservice.Group{Name: groupName}
+			}
+			group.Roles = append(group.Roles, adminservice.Role{Name: splitted[0]})
+			nameToGroup[groupName] = group
+		}
+	}
+	return common.MapToValueSlice(nameToGroup)
+}
+
+// the csv columns are : login, initial password, roles (separated by spaces, optional),
+// a first line starting with "login" is considered as a header,
+// a failing line is reported without stopping the import
+func importUsers(ctx context.Context, logger log.Logger, adminService adminservice.AdminService, loginService loginservice.LoginService, adminId uint64, file io.Reader) ([]UserImportResult, error) {
+	if err := adminService.AuthQuery(ctx, adminId, adminservice.AdminGroupId, adminservice.ActionCreate); err != nil {
+		return nil, err
+	}
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var results []UserImportResult
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return results, nil
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				// the rest of the file can not be trusted
+				common.LogOriginalError(logger, err)
+				return append(results, UserImportResult{Line: line, Error: common.ErrorWrongImportFormatKey}), nil
+			}
+			return results, err
+		}
+
+		login := strings.TrimSpace(record[0])
+		if line == 1 && strings.EqualFold(login, "login") {
+			continue
+		}
+
+		userId, err := importUser(ctx, adminService, loginService, adminId, login, record)
+		result := UserImportResult{Line: line, Login: login, UserId: userId}
+		if err != nil {
+			result.Error = common.FilterErrorMsg(logger, err.Error())
+		}
+		results = append(results, result)
+	}
+}
+
+func importUser(ctx context.Context, adminService adminservice.AdminService, loginService loginservice.LoginService, adminId uint64, login string, record []string) (uint64, error) {
+	if login == "" {
+		return 0, common.ErrEmptyLogin
+	}
+	if len(record) < 2 || record[1] == "" {
+		return 0, common.ErrEmptyPassword
+	}
+
+	// the password policy is checked by Register, an existing login give an ErrExistingLogin
+	userId, err := loginService.Register(ctx, login, record[1])
+	if err != nil {
+		return 0, err
+	}
+
+	if len(record) > 2 {
+		if roles := parseRoles(strings.Fields(record[2])); len(roles) != 0 {
+			// the account stay created, the report show the failure on roles
+			if err = adminService.UpdateUser(ctx, adminId, userId, roles); err != nil {
+				return userId, err
+			}
+		}
+	}
+	return userId, nil
+}
@@ -0,0 +1,240 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package puzzleweb
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dvaumoron/puzzleweb/common"
+	"github.com/dvaumoron/puzzleweb/common/config"
+	"github.com/dvaumoron/puzzleweb/common/log"
+	"github.com/dvaumoron/puzzleweb/mail"
+	mailservice "github.com/dvaumoron/puzzleweb/mail/service"
+	profileservice "github.com/dvaumoron/puzzleweb/profile/service"
+	templateservice "github.com/dvaumoron/puzzleweb/templates/service"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// actions which can be gated by the email verification
+const (
+	VerifiedComment = "comment"
+	VerifiedPost    = "post"
+)
+
+const (
+	verifiedEmailName     = "VerifiedEmail" // in user settings
+	verificationTokenName = "token"
+	verificationUrl       = "/login/verify"
+	verificationMailTmpl  = "mail/verification"
+	verificationTimeOut   = time.Minute
+	tokenClockSkew        = time.Minute
+)
+
+// the verified address is kept in the user settings, so changing the email of the profile require a new verification
+type emailVerifier struct {
+	profileService  profileservice.AdvancedProfileService
+	settingsManager *SettingsManager
+	mailer          mailservice.Mailer
+	templateService templateservice.TemplateService
+	loggerGetter    log.LoggerGetter
+	tokenSigner     common.SignedToken
+	tokenTTL        time.Duration
+	gatedActions    common.Set[string]
+	resend          bool
+}
+
+// return nil when the verification is disabled
+func newEmailVerifier(verificationConfig config.VerificationConfig, settingsManager *SettingsManager) *emailVerifier {
+	if len(verificationConfig.GatedActions) == 0 {
+		return nil
+	}
+
+	return &emailVerifier{
+		profileService: verificationConfig.Service, settingsManager: settingsManager, mailer: verificationConfig.Mailer,
+		templateService: verificationConfig.TemplateService, loggerGetter: verificationConfig.LoggerGetter,
+		tokenSigner: common.SignedToken{Key: verificationConfig.SigningKey, ClockSkew: tokenClockSkew},
+		tokenTTL:    verificationConfig.TokenTTL, resend: verificationConfig.Resend,
+		gatedActions: common.MakeSet(verificationConfig.GatedActions),
+	}
+}
+
+// return an error when the action is gated and the current user has no verified email
+func CheckVerifiedEmail(c *gin.Context, action string) error {
+	verifier := getSite(c).emailVerifier
+	if verifier == nil || !verifier.gatedActions.Contains(action) {
+		return nil
+	}
+
+	userId := GetSessionUserId(c)
+	if userId == 0 {
+		// anonymous actions are managed by the rights and the captcha
+		return nil
+	}
+
+	ctx := c.Request.Context()
+	email, err := verifier.getEmail(ctx, userId)
+	if err != nil {
+		return err
+	}
+	if email == "" || verifier.settingsManager.Get(ctx, userId, c)[verifiedEmailName] != email {
+		return common.ErrEmailNotVerified
+	}
+	return nil
+}
+
+func (v *emailVerifier) getEmail(ctx context.Context, userId uint64) (string, error) {
+	profiles, err := v.profileService.GetProfiles(ctx, []uint64{userId})
+	if err != nil {
+		return "", err
+	}
+	return profiles[userId].Info[profileservice.EmailInfoName], nil
+}
+
+// the mail is sent asynchronously, in the lang of the current request
+func (v *emailVerifier) sendVerification(c *gin.Context, userId uint64, email string) {
+	verifyUrl := common.GetAbsolutePathUrl(
+		verificationUrl+"?"+verificationTokenName+"="+url.QueryEscape(v.makeToken(userId, email)), c,
+	)
+	lang := GetLocalesManager(c).GetLang(c)
+	data := gin.H{"VerifyUrl": verifyUrl, "ValidityHours": int(v.tokenTTL.Hours())}
+
+	// keep the trace but not the cancellation of the request
+	ctx := context.WithoutCancel(c.Request.Context())
+	go func() {
+		ctx, cancel := context.WithTimeout(ctx, verificationTimeOut)
+		defer cancel()
+
+		logger := v.loggerGetter.Logger(ctx)
+		message, err := mail.RenderMessage(ctx, v.templateService, verificationMailTmpl, email, lang, data)
+		if err != nil {
+			logger.Error("Failed to render verification mail", zap.Error(err))
+			return
+		}
+
+		if err = v.mailer.Send(ctx, message); err != nil {
+			logger.Error("Failed to send verification mail", zap.Uint64(userIdName, userId), zap.Error(err))
+		}
+	}()
+}
+
+// the email given at registration is saved in the profile, a failure does not cancel the registration
+func (v *emailVerifier) registerEmail(c *gin.Context, userId uint64, email string) {
+	ctx := c.Request.Context()
+	if err := v.profileService.UpdateProfile(ctx, userId, "", map[string]string{profileservice.EmailInfoName: email}); err != nil {
+		GetLogger(c).Warn("Failed to save the email given at registration", zap.Error(err))
+		return
+	}
+	v.sendVerification(c, userId, email)
+}
+
+// the token contains the user id and the email to verify
+func (v *emailVerifier) makeToken(userId uint64, email string) string {
+	return v.tokenSigner.Encode(strconv.FormatUint(userId, 10)+":"+email, v.tokenTTL)
+}
+
+func (v *emailVerifier) parseToken(token string) (uint64, string, error) {
+	payload, err := v.tokenSigner.Decode(token)
+	if err != nil {
+		return 0, "", err
+	}
+
+	userIdStr, email, ok := strings.Cut(payload, ":")
+	if !ok {
+		return 0, "", common.ErrTokenInvalid
+	}
+	userId, err := strconv.ParseUint(userIdStr, 10, 64)
+	if err != nil {
+		return 0, "", common.ErrTokenInvalid
+	}
+	return userId, email, nil
+}
+
+// the link can be opened without being connected, so the settings are not read from the current session
+func (v *emailVerifier) verify(ctx context.Context, token string) (uint64, error) {
+	userId, email, err := v.parseToken(token)
+	if err != nil {
+		v.loggerGetter.Logger(ctx).Info("Failed to parse verification token", zap.Error(err))
+		return 0, common.ErrWrongVerification
+	}
+
+	// the email may have changed since the sending
+	currentEmail, err := v.getEmail(ctx, userId)
+	if err != nil {
+		return 0, err
+	}
+	if currentEmail != email {
+		return 0, common.ErrWrongVerification
+	}
+
+	settingsService := v.settingsManager.Service
+	userSettings, err := settingsService.Get(ctx, userId)
+	if err != nil {
+		return 0, err
+	}
+	if userSettings == nil {
+		userSettings = map[string]string{}
+	}
+	userSettings[verifiedEmailName] = email
+	return userId, settingsService.Update(ctx, userId, userSettings)
+}
+
+func (v *emailVerifier) createVerifyHandler() gin.HandlerFunc {
+	return common.CreateRedirect(func(c *gin.Context) string {
+		logger := GetLogger(c)
+		userId, err := v.verify(c.Request.Context(), c.Query(verificationTokenName))
+		if err != nil {
+			return common.DefaultErrorRedirect(logger, err.Error())
+		}
+		return profileUrlBuilder(userId).String()
+	})
+}
+
+// return nil when the resend is disabled
+func (v *emailVerifier) createResendHandler() gin.HandlerFunc {
+	if !v.resend {
+		return nil
+	}
+
+	return common.CreateRedirect(func(c *gin.Context) string {
+		logger := GetLogger(c)
+		userId := GetSessionUserId(c)
+		if userId == 0 {
+			return common.DefaultErrorRedirect(logger, unknownUserKey)
+		}
+
+		targetBuilder := profileUrlBuilder(userId)
+		email, err := v.getEmail(c.Request.Context(), userId)
+		if err != nil {
+			common.WriteError(targetBuilder, logger, err.Error())
+			return targetBuilder.String()
+		}
+		if email == "" {
+			common.WriteError(targetBuilder, logger, common.ErrorEmailNotVerifiedKey)
+			return targetBuilder.String()
+		}
+
+		v.sendVerification(c, userId, email)
+		return targetBuilder.String()
+	})
+}
@@ -19,9 +19,14 @@
 package puzzleweb
 
 import (
+	"cmp"
 	"context"
 	"net"
 	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	adminservice "github.com/dvaumoron/puzzleweb/admin/service"
@@ -40,31 +45,64 @@ import (
 const siteName = "Site"
 const unknownUserKey = "ErrorUnknownUser"
 
+// adders are applied by ascending priority, then in registration order
+const (
+	CoreDataPriority    = -100 // features and current user, used by the other adders
+	DefaultDataPriority = 0
+)
+
+type prioritizedAdder struct {
+	adder    common.DataAdder
+	priority int
+}
+
 type Site struct {
 	loggerGetter   log.LoggerGetter
 	localesManager common.LocalesManager
 	authService    adminservice.AuthService
 	timeOut        time.Duration
 	root           Page
-	adders         []common.DataAdder
+	adders         []prioritizedAdder
+	maintenance    atomic.Pointer[maintenanceState]
+	pageCache      *pageCache     // nil when disabled
+	emailVerifier  *emailVerifier // nil when disabled
+	loadLimiter    *loadLimiter   // nil when disabled
+	maxDraftSize   uint64
+	staticVersion  string
+	staticAssets   map[string]string
+
+	settingsManager *SettingsManager
 }
 
 func NewSite(configExtracter config.BaseConfigExtracter, localesManager common.LocalesManager, settingsManager *SettingsManager) *Site {
 	adminConfig := configExtracter.ExtractAdminConfig()
 	root := MakeStaticPage("root", adminservice.PublicGroupId, "index")
-	root.AddSubPage(newLoginPage(configExtracter.ExtractLoginConfig(), settingsManager))
-	root.AddSubPage(newAdminPage(adminConfig))
-	root.AddSubPage(newSettingsPage(config.MakeServiceConfig(configExtracter, settingsManager)))
-	root.AddSubPage(newProfilePage(configExtracter.ExtractProfileConfig()))
+	verifier := newEmailVerifier(configExtracter.ExtractVerificationConfig(), settingsManager)
+	root.AddSubPage(newLoginPage(configExtracter.ExtractLoginConfig(), settingsManager, verifier))
+	lister := newSessionLister(adminConfig.SessionService, adminConfig.DateFormat)
+	profileConfig := configExtracter.ExtractProfileConfig()
+	deleter := newAccountDeleter(profileConfig)
+	root.AddSubPage(newAdminPage(adminConfig, lister, deleter))
+	root.AddSubPage(newSettingsPage(
+		config.MakeServiceConfig(configExtracter, settingsManager), lister,
+		newDataExporter(profileConfig, settingsManager), deleter,
+	))
+	root.AddSubPage(newProfilePage(profileConfig, settingsManager, verifier))
+	root.AddSubPage(newDashboardPage(profileConfig))
 
 	return &Site{
 		loggerGetter: configExtracter.GetLoggerGetter(), localesManager: localesManager,
 		authService: adminConfig.Service, timeOut: configExtracter.GetServiceTimeOut(), root: root,
+		adders: []prioritizedAdder{
+			{adder: addFeatures, priority: CoreDataPriority},
+			{adder: NewCurrentUserAdder(profileConfig.Service), priority: CoreDataPriority},
+		},
+		settingsManager: settingsManager, emailVerifier: verifier,
 	}
 }
 
-func (site *Site) AddPage(page Page) {
-	site.root.AddSubPage(page)
+func (site *Site) AddPage(page Page, checkers ...AccessChecker) {
+	site.root.AddSubPage(page, checkers...)
 }
 
 func (site *Site) AddStaticPages(pageGroup parser.StaticPagesConfig) bool {
@@ -80,7 +118,21 @@ func (site *Site) GetPageWithPath(path string) (Page, bool) {
 }
 
 func (site *Site) AddDefaultData(adder common.DataAdder) {
-	site.adders = append(site.adders, adder)
+	site.AddDefaultDataWithPriority(adder, DefaultDataPriority)
+}
+
+func (site *Site) AddDefaultDataWithPriority(adder common.DataAdder, priority int) {
+	site.adders = append(site.adders, prioritizedAdder{adder: adder, priority: priority})
+	// stable to keep the registration order between equal priorities
+	slices.SortStableFunc(site.adders, func(a prioritizedAdder, b prioritizedAdder) int {
+		return cmp.Compare(a.priority, b.priority)
+	})
+}
+
+// to call when the templates of the static pages or the public roles have changed
+// (also available to admins with POST /admin/cache/clear)
+func (site *Site) ClearPageCache() {
+	site.pageCache.clear()
 }
 
 func (site *Site) manageTimeOut(c *gin.Context) {
@@ -93,7 +145,25 @@ func (site *Site) manageTimeOut(c *gin.Context) {
 
 func (site *Site) initEngine(siteConfig config.SiteConfig) *gin.Engine {
 	engine := gin.New()
-	engine.Use(site.manageTimeOut, otelgin.Middleware(config.WebKey), gin.Recovery())
+	site.pageCache = newPageCache(siteConfig.PageCacheTTL, siteConfig.PageCacheSize)
+	site.maxDraftSize = siteConfig.MaxDraftSize
+	site.staticVersion = siteConfig.StaticVersion
+	site.staticAssets = siteConfig.StaticAssets
+	// with an empty list, no proxy is trusted (gin trust all by default)
+	if err := engine.SetTrustedProxies(siteConfig.TrustedProxies); err != nil {
+		siteConfig.Logger.Error("Failed to set trusted proxies", zap.Error(err))
+	}
+	site.loadLimiter = newLoadLimiter(siteConfig.MaxInFlight, siteConfig.InFlightWait)
+	if limiter := site.loadLimiter; limiter != nil {
+		// first, the waiting requests must not consume their time out
+		engine.Use(limiter.limit)
+	}
+	engine.Use(site.manageTimeOut, otelgin.Middleware(config.WebKey))
+	if accessLogger := newAccessLogger(site.loggerGetter, siteConfig.AccessLogLevel, siteConfig.AccessLogSkipPaths); accessLogger != nil {
+		// after otelgin to have the trace, before the recovery to see its status
+		engine.Use(accessLogger.log)
+	}
+	engine.Use(gin.Recovery())
 
 	if memorySize := siteConfig.MaxMultipartMemory; memorySize != 0 {
 		engine.MaxMultipartMemory = memorySize
@@ -101,12 +171,50 @@ func (site *Site) initEngine(siteConfig config.SiteConfig) *gin.Engine {
 
 	engine.HTMLRender = templates.NewServiceRender(siteConfig.ExtractTemplateConfig())
 
-	engine.StaticFS("/static", siteConfig.StaticFileSystem)
-	engine.StaticFileFS(config.DefaultFavicon, siteConfig.FaviconPath, siteConfig.StaticFileSystem)
+	staticRoutes := engine.Group("")
+	if cacheControl := newStaticCacheControl(siteConfig.StaticMaxAge, siteConfig.StaticVersion); cacheControl != nil {
+		staticRoutes.Use(cacheControl)
+	}
+	staticRoutes.StaticFS("/static", siteConfig.StaticFileSystem)
+	staticRoutes.StaticFileFS(config.DefaultFavicon, siteConfig.FaviconPath, siteConfig.StaticFileSystem)
+	for iconUrl, iconPath := range siteConfig.IconPaths {
+		staticRoutes.StaticFileFS(iconUrl, iconPath, siteConfig.StaticFileSystem)
+	}
+	if serviceWorkerPath := siteConfig.ServiceWorkerPath; serviceWorkerPath != "" {
+		staticFileSystem := siteConfig.StaticFileSystem
+		engine.GET(config.ServiceWorkerUrl, func(c *gin.Context) {
+			// the browser must always check for a new version
+			c.Header("Cache-Control", "no-cache")
+			c.Header("Service-Worker-Allowed", "/")
+			c.FileFromFS(serviceWorkerPath, staticFileSystem)
+		})
+	}
+	if manifest := siteConfig.Manifest; manifest != nil {
+		engine.GET(config.ManifestUrl, func(c *gin.Context) {
+			c.Data(http.StatusOK, "application/manifest+json", manifest)
+		})
+	}
+	if highlightCSS := siteConfig.HighlightCSS; highlightCSS != nil {
+		engine.GET(config.HighlightCSSUrl, func(c *gin.Context) {
+			c.Data(http.StatusOK, "text/css; charset=utf-8", highlightCSS)
+		})
+	}
 
+	trustedProxies := parseTrustedProxies(siteConfig.Logger, siteConfig.TrustedProxies)
+	accessDenied := newAccessDeniedHandler(siteConfig.AccessDenied)
 	engine.Use(func(c *gin.Context) {
 		c.Set(siteName, site)
-	}, makeSessionManager(siteConfig.ExtractSessionConfig()).manage)
+		common.SetErrorResponder(c, WriteAppError)
+		if accessDenied != nil {
+			common.SetAccessDenied(c, accessDenied)
+		}
+		c.Set(common.TimeAgoLimitName, siteConfig.TimeAgoLimit)
+		c.Set(common.PaginationWindowName, siteConfig.PaginationWindow)
+		c.Set(common.TimeZoneName, siteConfig.TimeZone)
+		c.Set(common.TrustProxyName, isTrustedProxy(trustedProxies, c))
+	}, makeSessionManager(siteConfig.ExtractSessionConfig()).manage, newFeatureResolver(
+		siteConfig.Features, siteConfig.UserFeatures, site.settingsManager,
+	).resolve, site.checkMaintenance)
 
 	if localesManager := site.localesManager; localesManager.GetMultipleLang() {
 		engine.GET("/changeLang", common.CreateRedirect(changeLangRedirecter))
@@ -117,6 +225,10 @@ func (site *Site) initEngine(siteConfig config.SiteConfig) *gin.Engine {
 		}
 	}
 
+	if homeRedirect := siteConfig.HomeRedirect; homeRedirect != "" {
+		// only "/" is concerned, the sub pages (login, admin, etc.) keep their routes
+		site.root.Widget.(*staticWidget).displayHandler = common.CreateRedirectString(homeRedirect)
+	}
 	site.root.Widget.LoadInto(engine)
 	engine.NoRoute(common.CreateRedirectString(siteConfig.Page404Url))
 	return engine
@@ -148,6 +260,50 @@ func Run(ginLogger *zap.Logger, sites ...SiteAndConfig) error {
 	return g.Wait()
 }
 
+func parseTrustedProxies(logger log.Logger, trustedProxies []string) []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, len(trustedProxies))
+	for _, trustedProxy := range trustedProxies {
+		prefix, err := parseTrustedProxy(trustedProxy)
+		if err != nil {
+			logger.Error("Failed to parse trusted proxy", zap.String("trustedProxy", trustedProxy), zap.Error(err))
+			continue
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes
+}
+
+// accept CIDR or single IP (like gin)
+func parseTrustedProxy(trustedProxy string) (netip.Prefix, error) {
+	if strings.Contains(trustedProxy, "/") {
+		return netip.ParsePrefix(trustedProxy)
+	}
+
+	addr, err := netip.ParseAddr(trustedProxy)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+func isTrustedProxy(trustedProxies []netip.Prefix, c *gin.Context) bool {
+	if len(trustedProxies) == 0 {
+		return false
+	}
+
+	addr, err := netip.ParseAddr(c.RemoteIP())
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
 func changeLangRedirecter(c *gin.Context) string {
 	getSite(c).localesManager.SetLangCookie(c.Query(locale.LangName), c)
 	return c.Query(common.RedirectName)
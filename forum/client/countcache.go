@@ -0,0 +1,66 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package forumclient
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	commentCountTTL = time.Minute
+	maxCountQueries = 8 // concurrent comment threads queried by CountComments
+)
+
+type cachedCount struct {
+	count      uint64
+	expiration time.Time
+}
+
+// comment counts by element title, kept for commentCountTTL (or until this client change the comments of the element)
+type countCache struct {
+	mutex  sync.RWMutex
+	counts map[string]cachedCount
+}
+
+func newCountCache() *countCache {
+	return &countCache{counts: map[string]cachedCount{}}
+}
+
+func (cache *countCache) load(elemTitle string) (uint64, bool) {
+	cache.mutex.RLock()
+	cached, ok := cache.counts[elemTitle]
+	cache.mutex.RUnlock()
+	if !ok || time.Now().After(cached.expiration) {
+		return 0, false
+	}
+	return cached.count, true
+}
+
+func (cache *countCache) store(elemTitle string, count uint64) {
+	cache.mutex.Lock()
+	cache.counts[elemTitle] = cachedCount{count: count, expiration: time.Now().Add(commentCountTTL)}
+	cache.mutex.Unlock()
+}
+
+func (cache *countCache) delete(elemTitle string) {
+	cache.mutex.Lock()
+	delete(cache.counts, elemTitle)
+	cache.mutex.Unlock()
+}
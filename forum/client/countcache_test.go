@@ -0,0 +1,46 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package forumclient
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCountCache(t *testing.T) {
+	cache := newCountCache()
+	if _, ok := cache.load("post"); ok {
+		t.Error("unexpected count in an empty cache")
+	}
+
+	cache.store("post", 3)
+	if count, ok := cache.load("post"); !ok || count != 3 {
+		t.Errorf("got %d (found %t), want 3", count, ok)
+	}
+
+	cache.delete("post")
+	if _, ok := cache.load("post"); ok {
+		t.Error("the count should be deleted")
+	}
+
+	cache.counts["old"] = cachedCount{count: 1, expiration: time.Now().Add(-time.Second)}
+	if _, ok := cache.load("old"); ok {
+		t.Error("an expired count should be ignored")
+	}
+}
@@ -21,22 +21,27 @@ package forumclient
 import (
 	"cmp"
 	"context"
+	"net/url"
 	"slices"
+	"strings"
 	"time"
 
 	pb "github.com/dvaumoron/puzzleforumservice"
 	grpcclient "github.com/dvaumoron/puzzlegrpcclient"
 	adminservice "github.com/dvaumoron/puzzleweb/admin/service"
 	"github.com/dvaumoron/puzzleweb/common"
+	"github.com/dvaumoron/puzzleweb/common/grpcretry"
 	"github.com/dvaumoron/puzzleweb/common/log"
 	forumservice "github.com/dvaumoron/puzzleweb/forum/service"
 	profileservice "github.com/dvaumoron/puzzleweb/profile/service"
 	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
 	"google.golang.org/grpc"
 )
 
 type forumClient struct {
 	grpcclient.Client
+	counts         *countCache
 	forumId        uint64
 	groupId        uint64
 	dateFormat     string
@@ -47,8 +52,8 @@ type forumClient struct {
 
 func New(serviceAddr string, dialOptions []grpc.DialOption, forumId uint64, groupId uint64, dateFormat string, authService adminservice.AuthService, profileService profileservice.ProfileService, loggerGetter log.LoggerGetter) forumservice.FullForumService {
 	return forumClient{
-		Client: grpcclient.Make(serviceAddr, dialOptions...), forumId: forumId, groupId: groupId, dateFormat: dateFormat,
-		authService: authService, profileService: profileService, loggerGetter: loggerGetter,
+		Client: grpcclient.Make(serviceAddr, dialOptions...), counts: newCountCache(), forumId: forumId, groupId: groupId,
+		dateFormat: dateFormat, authService: authService, profileService: profileService, loggerGetter: loggerGetter,
 	}
 }
 
@@ -134,11 +139,56 @@ func (client forumClient) CreateMessage(ctx context.Context, userId uint64, thre
 	return nil
 }
 
+// the forum service only knows user ids, so the identity of an anonymous author
+// is stored in a first line of the text (only decoded for a content without user id)
+const anonymousPrefix = "anonymous:"
+
+const (
+	anonymousNameKey  = "name"
+	anonymousEmailKey = "email"
+)
+
+func encodeAnonymous(author forumservice.AnonymousAuthor, comment string) string {
+	header := url.Values{anonymousNameKey: {author.Name}, anonymousEmailKey: {author.EmailHash}}
+	return anonymousPrefix + header.Encode() + "\n" + comment
+}
+
+// return the text unchanged when there is no valid header
+func decodeAnonymous(text string) (forumservice.AnonymousAuthor, string) {
+	if !strings.HasPrefix(text, anonymousPrefix) {
+		return forumservice.AnonymousAuthor{}, text
+	}
+
+	header, comment, found := strings.Cut(text[len(anonymousPrefix):], "\n")
+	if !found {
+		return forumservice.AnonymousAuthor{}, text
+	}
+
+	values, err := url.ParseQuery(header)
+	if err != nil {
+		return forumservice.AnonymousAuthor{}, text
+	}
+	return forumservice.AnonymousAuthor{Name: values.Get(anonymousNameKey), EmailHash: values.Get(anonymousEmailKey)}, comment
+}
+
 func (client forumClient) CreateComment(ctx context.Context, userId uint64, elemTitle string, comment string) error {
+	if userId == 0 {
+		// always encoded, so an anonymous text can not forge an identity
+		comment = encodeAnonymous(forumservice.AnonymousAuthor{}, comment)
+	}
+	return client.createComment(ctx, userId, elemTitle, comment)
+}
+
+func (client forumClient) CreateAnonymousComment(ctx context.Context, elemTitle string, author forumservice.AnonymousAuthor, comment string) error {
+	return client.createComment(ctx, 0, elemTitle, encodeAnonymous(author, comment))
+}
+
+func (client forumClient) createComment(ctx context.Context, userId uint64, elemTitle string, comment string) error {
 	err := client.authService.AuthQuery(ctx, userId, client.groupId, adminservice.ActionAccess)
 	if err != nil {
 		return err
 	}
+	defer client.counts.delete(elemTitle)
 
 	conn, err := client.Dial()
 	if err != nil {
@@ -188,14 +238,14 @@ func (client forumClient) GetThread(ctx context.Context, userId uint64, threadId
 	defer conn.Close()
 
 	forumClient := pb.NewForumClient(conn)
-	response, err := forumClient.GetThread(ctx, &pb.IdRequest{ContainerId: client.forumId, Id: threadId})
+	response, err := forumClient.GetThread(ctx, &pb.IdRequest{ContainerId: client.forumId, Id: threadId}, grpcretry.WithRetry())
 	if err != nil {
 		return 0, forumservice.ForumContent{}, nil, err
 	}
 
 	response2, err := forumClient.GetMessages(ctx, &pb.SearchRequest{
 		ContainerId: threadId, Start: start, End: end, Filter: filter,
-	})
+	}, grpcretry.WithRetry())
 	if err != nil {
 		return 0, forumservice.ForumContent{}, nil, err
 	}
@@ -230,7 +280,7 @@ func (client forumClient) GetThreads(ctx context.Context, userId uint64, start u
 
 	response, err := pb.NewForumClient(conn).GetThreads(ctx, &pb.SearchRequest{
 		ContainerId: client.forumId, Start: start, End: end, Filter: filter,
-	})
+	}, grpcretry.WithRetry())
 	if err != nil {
 		return 0, nil, err
 	}
@@ -249,7 +299,7 @@ func (client forumClient) GetThreads(ctx context.Context, userId uint64, start u
 	return total, convertContents(list, users, client.dateFormat), nil
 }
 
-func (client forumClient) GetCommentThread(ctx context.Context, userId uint64, elemTitle string, start uint64, end uint64) (uint64, []forumservice.ForumContent, error) {
+func (client forumClient) GetCommentThread(ctx context.Context, userId uint64, elemTitle string, start uint64, end uint64, newestFirst bool) (uint64, []forumservice.ForumContent, error) {
 	err := client.authService.AuthQuery(ctx, userId, client.groupId, adminservice.ActionAccess)
 	if err != nil {
 		return 0, nil, err
@@ -272,9 +322,27 @@ func (client forumClient) GetCommentThread(ctx context.Context, userId uint64, e
 	}
 	threadId := response.List[0].Id
 
+	cmpContent := cmpContentAsc
+	if newestFirst {
+		// the service pages in creation order, the window is mirrored with the total
+		countResponse, err := forumClient.GetMessages(ctx, &pb.SearchRequest{
+			ContainerId: threadId, Start: 0, End: 1,
+		}, grpcretry.WithRetry())
+		if err != nil {
+			return 0, nil, err
+		}
+
+		total := countResponse.Total
+		if start >= total {
+			return total, nil, nil
+		}
+		start, end = total-min(end, total), total-start
+		cmpContent = cmpContentDesc
+	}
+
 	response2, err := forumClient.GetMessages(ctx, &pb.SearchRequest{
 		ContainerId: threadId, Start: start, End: end,
-	})
+	}, grpcretry.WithRetry())
 	if err != nil {
 		return 0, nil, err
 	}
@@ -289,10 +357,72 @@ func (client forumClient) GetCommentThread(ctx context.Context, userId uint64, e
 	if err != nil {
 		return 0, nil, err
 	}
-	slices.SortFunc(list, cmpContentAsc)
+	slices.SortFunc(list, cmpContent)
 	return total, convertContents(list, users, client.dateFormat), nil
 }
 
+// the counts are cached, the other titles share one connection and their threads are queried concurrently
+// (each needs two calls, the service can not count by title)
+func (client forumClient) CountComments(ctx context.Context, userId uint64, elemTitles []string) (map[string]uint64, error) {
+	err := client.authService.AuthQuery(ctx, userId, client.groupId, adminservice.ActionAccess)
+	if err != nil {
+		return nil, err
+	}
+
+	countByTitle := make(map[string]uint64, len(elemTitles))
+	var unknowns []string
+	for _, elemTitle := range elemTitles {
+		if count, ok := client.counts.load(elemTitle); ok {
+			countByTitle[elemTitle] = count
+		} else {
+			unknowns = append(unknowns, elemTitle)
+		}
+	}
+	if len(unknowns) == 0 {
+		return countByTitle, nil
+	}
+
+	conn, err := client.Dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	objectId := client.forumId
+	forumClient := pb.NewForumClient(conn)
+	counts := make([]uint64, len(unknowns))
+	g, groupCtx := errgroup.WithContext(ctx)
+	g.SetLimit(maxCountQueries)
+	for index, elemTitle := range unknowns {
+		index, elemTitle := index, elemTitle
+		g.Go(func() error {
+			response, err := searchCommentThread(forumClient, groupCtx, objectId, elemTitle)
+			if err != nil || response.Total == 0 {
+				return err
+			}
+
+			response2, err := forumClient.GetMessages(groupCtx, &pb.SearchRequest{
+				ContainerId: response.List[0].Id, Start: 0, End: 1,
+			}, grpcretry.WithRetry())
+			if err != nil {
+				return err
+			}
+			counts[index] = response2.Total
+			return nil
+		})
+	}
+	if err = g.Wait(); err != nil {
+		return nil, err
+	}
+
+	for index, elemTitle := range unknowns {
+		count := counts[index]
+		client.counts.store(elemTitle, count)
+		countByTitle[elemTitle] = count
+	}
+	return countByTitle, nil
+}
+
 func (client forumClient) DeleteThread(ctx context.Context, userId uint64, threadId uint64) error {
 	return client.deleteContent(ctx, userId, deleteThread, &pb.IdRequest{ContainerId: client.forumId, Id: threadId})
 }
@@ -302,6 +432,7 @@ func (client forumClient) DeleteCommentThread(ctx context.Context, userId uint64
 	if err != nil {
 		return err
 	}
+	defer client.counts.delete(elemTitle)
 
 	conn, err := client.Dial()
 	if err != nil {
@@ -341,6 +472,7 @@ func (client forumClient) DeleteComment(ctx context.Context, userId uint64, elem
 	if err != nil {
 		return err
 	}
+	defer client.counts.delete(elemTitle)
 
 	conn, err := client.Dial()
 	if err != nil {
@@ -414,7 +546,7 @@ func (client forumClient) logCommentThreadNotFound(ctx context.Context, objectId
 func searchCommentThread(forumClient pb.ForumClient, ctx context.Context, objectId uint64, elemTitle string) (*pb.Contents, error) {
 	return forumClient.GetThreads(ctx, &pb.SearchRequest{
 		ContainerId: objectId, Start: 0, End: 1, Filter: elemTitle,
-	})
+	}, grpcretry.WithRetry())
 }
 
 func deleteThread(forumClient pb.ForumClient, ctx context.Context, request *pb.IdRequest) (*pb.Response, error) {
@@ -435,9 +567,13 @@ func convertContents(list []*pb.Content, users map[uint64]profileservice.UserPro
 
 func convertContent(content *pb.Content, creator profileservice.UserProfile, dateFormat string) forumservice.ForumContent {
 	createdAt := time.Unix(content.CreatedAt, 0)
-	return forumservice.ForumContent{
-		Id: content.Id, Creator: creator, Date: createdAt.Format(dateFormat), Text: content.Text,
+	converted := forumservice.ForumContent{
+		Id: content.Id, Creator: creator, Date: createdAt.Format(dateFormat), Created: createdAt, Text: content.Text,
+	}
+	if content.UserId == 0 {
+		converted.Anonymous, converted.Text = decodeAnonymous(content.Text)
 	}
+	return converted
 }
 
 // no duplicate check, there is one in GetProfiles
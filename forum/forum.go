@@ -26,6 +26,7 @@ import (
 	"github.com/dvaumoron/puzzleweb/common"
 	"github.com/dvaumoron/puzzleweb/common/config"
 	puzzleweb "github.com/dvaumoron/puzzleweb/core"
+	forumservice "github.com/dvaumoron/puzzleweb/forum/service"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
@@ -62,6 +63,7 @@ func (w forumWidget) LoadInto(router gin.IRouter) {
 func MakeForumPage(forumName string, forumConfig config.ForumConfig) puzzleweb.Page {
 	forumService := forumConfig.Service
 	defaultPageSize := forumConfig.PageSize
+	maxPageSize := forumConfig.MaxPageSize
 
 	listTmpl := "forum/list"
 	viewTmpl := "forum/view"
@@ -94,14 +96,16 @@ func MakeForumPage(forumName string, forumConfig config.ForumConfig) puzzleweb.P
 			ctx := c.Request.Context()
 			userId, _ := data[common.UserIdName].(uint64)
 
-			pageNumber, start, end, filter := common.GetPagination(defaultPageSize, c)
+			pageNumber, pageSize, start, end, filter := common.GetPagination(defaultPageSize, maxPageSize, c)
 
 			total, threads, err := forumService.GetThreads(ctx, userId, start, end, filter)
 			if err != nil {
 				return "", common.DefaultErrorRedirect(puzzleweb.GetLogger(c), err.Error())
 			}
 
-			common.InitPagination(data, filter, pageNumber, end, total)
+			localizeContentsDate(threads, c)
+
+			common.InitPagination(data, filter, pageNumber, pageSize, total, c)
 			data["Threads"] = threads
 			data[common.AllowedToCreateName] = forumService.CreateThreadRight(ctx, userId)
 			data[common.AllowedToDeleteName] = forumService.DeleteRight(ctx, userId)
@@ -123,6 +127,9 @@ func MakeForumPage(forumName string, forumConfig config.ForumConfig) puzzleweb.P
 			if message == "" {
 				return common.DefaultErrorRedirect(logger, emptyMessage)
 			}
+			if err := puzzleweb.CheckVerifiedEmail(c, puzzleweb.VerifiedPost); err != nil {
+				return common.DefaultErrorRedirect(logger, err.Error())
+			}
 
 			threadId, err := forumService.CreateThread(c.Request.Context(), puzzleweb.GetSessionUserId(c), title, message)
 			if err != nil {
@@ -155,7 +162,7 @@ func MakeForumPage(forumName string, forumConfig config.ForumConfig) puzzleweb.P
 				return "", common.DefaultErrorRedirect(logger, common.ErrorTechnicalKey)
 			}
 
-			pageNumber, start, end, filter := common.GetPagination(defaultPageSize, c)
+			pageNumber, pageSize, start, end, filter := common.GetPagination(defaultPageSize, maxPageSize, c)
 
 			ctx := c.Request.Context()
 			userId, _ := data[common.UserIdName].(uint64)
@@ -164,7 +171,10 @@ func MakeForumPage(forumName string, forumConfig config.ForumConfig) puzzleweb.P
 				return "", common.DefaultErrorRedirect(logger, err.Error())
 			}
 
-			common.InitPagination(data, filter, pageNumber, end, total)
+			thread.Date = common.FormatDate(thread.Created, c)
+			localizeContentsDate(messages, c)
+
+			common.InitPagination(data, filter, pageNumber, pageSize, total, c)
 			data[common.BaseUrlName] = common.GetBaseUrl(2, c)
 			data["Thread"] = thread
 			data["ForumMessages"] = messages
@@ -184,6 +194,9 @@ func MakeForumPage(forumName string, forumConfig config.ForumConfig) puzzleweb.P
 
 			err = errEmptyMessage
 			if message != "" {
+				err = puzzleweb.CheckVerifiedEmail(c, puzzleweb.VerifiedComment)
+			}
+			if err == nil {
 				err = forumService.CreateMessage(c.Request.Context(), puzzleweb.GetSessionUserId(c), threadId, message)
 			}
 
@@ -225,3 +238,9 @@ func threadUrlBuilder(base string, threadId uint64) *strings.Builder {
 	targetBuilder.WriteString(strconv.FormatUint(threadId, 10))
 	return targetBuilder
 }
+
+func localizeContentsDate(contents []forumservice.ForumContent, c *gin.Context) {
+	for index := range contents {
+		contents[index].Date = common.FormatDate(contents[index].Created, c)
+	}
+}
@@ -20,15 +20,25 @@ package forumservice
 
 import (
 	"context"
+	"time"
 
 	profileservice "github.com/dvaumoron/puzzleweb/profile/service"
 )
 
+// identity given by the author of an anonymous comment
+type AnonymousAuthor struct {
+	Name      string
+	EmailHash string // sha256 of the trimmed lower case email (as used by gravatar), empty when not given
+}
+
 type ForumContent struct {
 	Id      uint64
 	Creator profileservice.UserProfile
 	Date    string
+	Created time.Time
 	Text    string
+
+	Anonymous AnonymousAuthor // filled for an anonymous comment (the Creator is empty)
 }
 
 type ForumService interface {
@@ -46,7 +56,12 @@ type ForumService interface {
 type CommentService interface {
 	CreateCommentThread(ctx context.Context, userId uint64, elemTitle string) error
 	CreateComment(ctx context.Context, userId uint64, elemTitle string, message string) error
-	GetCommentThread(ctx context.Context, userId uint64, elemTitle string, start uint64, end uint64) (uint64, []ForumContent, error)
+	// the rights checked are the ones of the anonymous user
+	CreateAnonymousComment(ctx context.Context, elemTitle string, author AnonymousAuthor, message string) error
+	// batched count by title, a missing thread counts 0 comment
+	CountComments(ctx context.Context, userId uint64, elemTitles []string) (map[string]uint64, error)
+	// start and end count from the newest comment when newestFirst is true
+	GetCommentThread(ctx context.Context, userId uint64, elemTitle string, start uint64, end uint64, newestFirst bool) (uint64, []ForumContent, error)
 	DeleteCommentThread(ctx context.Context, userId uint64, elemTitle string) error
 	DeleteComment(ctx context.Context, userId uint64, elemTitle string, commentId uint64) error
 	CreateMessageRight(ctx context.Context, userId uint64) bool
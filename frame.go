@@ -55,6 +55,10 @@ func main() {
 	}
 
 	logger := globalConfig.Logger
+	if !build.EnsureRoles(globalConfig.InitCtx, logger, rightClient, parsedConfig.Roles) {
+		return
+	}
+
 	for _, pageGroup := range parsedConfig.StaticPages {
 		if !site.AddStaticPages(pageGroup) {
 			logger.Error("Failure during static pages creation")
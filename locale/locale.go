@@ -34,6 +34,20 @@ const (
 	pathName = "Path"
 )
 
+// numeric formats (the month names of time.Format are always in english)
+var conventionalDateFormats = map[string]string{
+	"de": "02.01.2006 15:04",
+	"en": "01/02/2006 3:04 PM",
+	"es": "02/01/2006 15:04",
+	"fr": "02/01/2006 15:04",
+	"it": "02/01/2006 15:04",
+	"ja": "2006/01/02 15:04",
+	"nl": "02-01-2006 15:04",
+	"pt": "02/01/2006 15:04",
+	"ru": "02.01.2006 15:04",
+	"zh": "2006/01/02 15:04",
+}
+
 type localesManager struct {
 	LoggerGetter   log.LoggerGetter
 	Domain         string
@@ -42,6 +56,7 @@ type localesManager struct {
 	DefaultLang    string
 	MultipleLang   bool
 	matcher        language.Matcher
+	dateFormats    map[string]string
 }
 
 func NewManager(localesConfig config.LocalesConfig) (common.LocalesManager, bool) {
@@ -53,16 +68,33 @@ func NewManager(localesConfig config.LocalesConfig) (common.LocalesManager, bool
 	}
 
 	tags := make([]language.Tag, 0, size)
+	dateFormats := make(map[string]string, size)
 	for _, lang := range allLang {
-		tags = append(tags, language.MustParse(lang))
+		tag := language.MustParse(lang)
+		tags = append(tags, tag)
+		dateFormats[lang] = chooseDateFormat(localesConfig, lang, tag)
 	}
 
 	return &localesManager{
 		LoggerGetter: localesConfig.LoggerGetter, Domain: localesConfig.Domain, SessionTimeOut: localesConfig.SessionTimeOut,
 		AllLang: localesConfig.AllLang, DefaultLang: allLang[0], MultipleLang: size > 1, matcher: language.NewMatcher(tags),
+		dateFormats: dateFormats,
 	}, true
 }
 
+// configured format, then the convention of the language, then the default format
+func chooseDateFormat(localesConfig config.LocalesConfig, lang string, tag language.Tag) string {
+	if dateFormat := localesConfig.DateFormats[lang]; dateFormat != "" {
+		return dateFormat
+	}
+
+	base, _ := tag.Base()
+	if dateFormat := conventionalDateFormats[base.String()]; dateFormat != "" {
+		return dateFormat
+	}
+	return localesConfig.DateFormat
+}
+
 func (m *localesManager) GetDefaultLang() string {
 	return m.DefaultLang
 }
@@ -95,6 +127,13 @@ func (m *localesManager) CheckLang(lang string, c *gin.Context) string {
 	return m.DefaultLang
 }
 
+func (m *localesManager) GetDateFormat(lang string) string {
+	if dateFormat, ok := m.dateFormats[lang]; ok {
+		return dateFormat
+	}
+	return m.dateFormats[m.DefaultLang]
+}
+
 func (m *localesManager) setLangCookie(lang string, c *gin.Context) string {
 	c.SetCookie(LangName, lang, m.SessionTimeOut, "/", m.Domain, false, false)
 	return lang
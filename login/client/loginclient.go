@@ -27,6 +27,7 @@ import (
 	grpcclient "github.com/dvaumoron/puzzlegrpcclient"
 	pb "github.com/dvaumoron/puzzleloginservice"
 	"github.com/dvaumoron/puzzleweb/common"
+	"github.com/dvaumoron/puzzleweb/common/grpcretry"
 	loginservice "github.com/dvaumoron/puzzleweb/login/service"
 	strengthservice "github.com/dvaumoron/puzzleweb/passwordstrength/service"
 	"google.golang.org/grpc"
@@ -122,7 +123,7 @@ func (client loginClient) GetUsers(ctx context.Context, userIds []uint64) (map[u
 	}
 	defer conn.Close()
 
-	response, err := pb.NewLoginClient(conn).GetUsers(ctx, &pb.UserIds{Ids: userIds})
+	response, err := pb.NewLoginClient(conn).GetUsers(ctx, &pb.UserIds{Ids: userIds}, grpcretry.WithRetry())
 	if err != nil {
 		return nil, err
 	}
@@ -209,7 +210,7 @@ func (client loginClient) ListUsers(ctx context.Context, start uint64, end uint6
 
 	response, err := pb.NewLoginClient(conn).ListUsers(ctx, &pb.RangeRequest{
 		Start: start, End: end, Filter: filter,
-	})
+	}, grpcretry.WithRetry())
 	if err != nil {
 		return 0, nil, err
 	}
@@ -243,5 +244,7 @@ func (client loginClient) Delete(ctx context.Context, userId uint64) error {
 
 func convertUser(user *pb.User, dateFormat string) loginservice.User {
 	registredAt := time.Unix(user.RegistredAt, 0)
-	return loginservice.User{Id: user.Id, Login: user.Login, RegistredAt: registredAt.Format(dateFormat)}
+	return loginservice.User{
+		Id: user.Id, Login: user.Login, RegistredAt: registredAt.Format(dateFormat), RegistredTime: registredAt,
+	}
 }
@@ -18,12 +18,16 @@
 
 package loginservice
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type User struct {
-	Id          uint64
-	Login       string
-	RegistredAt string
+	Id            uint64
+	Login         string
+	RegistredAt   string
+	RegistredTime time.Time
 }
 
 type UserService interface {
@@ -0,0 +1,224 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package mailclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+
+	"github.com/dvaumoron/puzzleweb/common/log"
+	mailservice "github.com/dvaumoron/puzzleweb/mail/service"
+	"go.uber.org/zap"
+)
+
+const (
+	TLSNone     = "none"
+	TLSStart    = "starttls"
+	TLSImplicit = "tls"
+)
+
+var errEmptyMessage = errors.New("mail message without content")
+
+type smtpClient struct {
+	addr     string
+	host     string
+	username string
+	password string
+	from     *mail.Address
+	tlsMode  string
+}
+
+// the authentication is done only when an username is given
+func New(host string, port string, username string, password string, from *mail.Address, tlsMode string) mailservice.Mailer {
+	return smtpClient{
+		addr: net.JoinHostPort(host, port), host: host, username: username, password: password, from: from, tlsMode: tlsMode,
+	}
+}
+
+func (client smtpClient) Send(ctx context.Context, message mailservice.Message) error {
+	// also protect the headers against injection
+	to, err := mail.ParseAddress(message.To)
+	if err != nil {
+		return err
+	}
+
+	data, err := buildMessage(client.from, to, message)
+	if err != nil {
+		return err
+	}
+
+	smtpClient, err := client.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer smtpClient.Close()
+
+	if err = client.authenticate(smtpClient); err != nil {
+		return err
+	}
+	if err = smtpClient.Mail(client.from.Address); err != nil {
+		return err
+	}
+	if err = smtpClient.Rcpt(to.Address); err != nil {
+		return err
+	}
+
+	writer, err := smtpClient.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = writer.Write(data); err != nil {
+		return err
+	}
+	if err = writer.Close(); err != nil {
+		return err
+	}
+	return smtpClient.Quit()
+}
+
+func (client smtpClient) dial(ctx context.Context) (*smtp.Client, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", client.addr)
+	if err != nil {
+		return nil, err
+	}
+	// net/smtp does not take a context, the deadline is applied on the connection instead
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	tlsConfig := &tls.Config{ServerName: client.host}
+	if client.tlsMode == TLSImplicit {
+		conn = tls.Client(conn, tlsConfig)
+	}
+
+	smtpClient, err := smtp.NewClient(conn, client.host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if client.tlsMode == TLSStart {
+		if err = smtpClient.StartTLS(tlsConfig); err != nil {
+			smtpClient.Close()
+			return nil, err
+		}
+	}
+	return smtpClient, nil
+}
+
+// smtp.PlainAuth refuses to send the password over an unencrypted connection (except to localhost)
+func (client smtpClient) authenticate(smtpClient *smtp.Client) error {
+	if client.username == "" {
+		return nil
+	}
+	return smtpClient.Auth(smtp.PlainAuth("", client.username, client.password, client.host))
+}
+
+func buildMessage(from *mail.Address, to *mail.Address, message mailservice.Message) ([]byte, error) {
+	var buffer bytes.Buffer
+	writeHeader(&buffer, "From", from.String())
+	writeHeader(&buffer, "To", to.String())
+	writeHeader(&buffer, "Subject", mime.QEncoding.Encode("utf-8", message.Subject))
+	writeHeader(&buffer, "MIME-Version", "1.0")
+
+	switch {
+	case message.Text != "" && message.HTML != "":
+		boundary, err := randomBoundary()
+		if err != nil {
+			return nil, err
+		}
+
+		writeHeader(&buffer, "Content-Type", mime.FormatMediaType("multipart/alternative", map[string]string{"boundary": boundary}))
+		buffer.WriteString("\r\n")
+		// the last part is the preferred one
+		writePart(&buffer, boundary, "text/plain; charset=utf-8", message.Text)
+		writePart(&buffer, boundary, "text/html; charset=utf-8", message.HTML)
+		buffer.WriteString("--")
+		buffer.WriteString(boundary)
+		buffer.WriteString("--\r\n")
+	case message.HTML != "":
+		writeBody(&buffer, "text/html; charset=utf-8", message.HTML)
+	case message.Text != "":
+		writeBody(&buffer, "text/plain; charset=utf-8", message.Text)
+	default:
+		return nil, errEmptyMessage
+	}
+	return buffer.Bytes(), nil
+}
+
+func writeHeader(buffer *bytes.Buffer, name string, value string) {
+	buffer.WriteString(name)
+	buffer.WriteString(": ")
+	buffer.WriteString(value)
+	buffer.WriteString("\r\n")
+}
+
+func writePart(buffer *bytes.Buffer, boundary string, contentType string, content string) {
+	buffer.WriteString("--")
+	buffer.WriteString(boundary)
+	buffer.WriteString("\r\n")
+	writeBody(buffer, contentType, content)
+	buffer.WriteString("\r\n")
+}
+
+// quoted-printable keeps the lines under the SMTP limit and the message 7 bit clean
+func writeBody(buffer *bytes.Buffer, contentType string, content string) {
+	writeHeader(buffer, "Content-Type", contentType)
+	writeHeader(buffer, "Content-Transfer-Encoding", "quoted-printable")
+	buffer.WriteString("\r\n")
+	// writing in a bytes.Buffer can not fail
+	writer := quotedprintable.NewWriter(buffer)
+	writer.Write([]byte(content))
+	writer.Close()
+}
+
+func randomBoundary() (string, error) {
+	var bytes [16]byte
+	if _, err := rand.Read(bytes[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes[:]), nil
+}
+
+// for development, the mails are only logged
+type logClient struct {
+	loggerGetter log.LoggerGetter
+}
+
+func NewLogOnly(loggerGetter log.LoggerGetter) mailservice.Mailer {
+	return logClient{loggerGetter: loggerGetter}
+}
+
+func (client logClient) Send(ctx context.Context, message mailservice.Message) error {
+	client.loggerGetter.Logger(ctx).Info(
+		"Mail not sent (log only mode)", zap.String("to", message.To), zap.String("subject", message.Subject),
+		zap.String("text", message.Text), zap.String("html", message.HTML),
+	)
+	return nil
+}
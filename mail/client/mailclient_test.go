@@ -0,0 +1,134 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package mailclient
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	mailservice "github.com/dvaumoron/puzzleweb/mail/service"
+)
+
+func TestBuildMessage(t *testing.T) {
+	from := &mail.Address{Name: "Site", Address: "site@example.com"}
+	to := &mail.Address{Address: "user@example.com"}
+
+	data, err := buildMessage(from, to, mailservice.Message{Subject: "Été", Text: "plain é", HTML: "<p>html</p>"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := string(data)
+	for _, part := range []string{
+		"From: \"Site\" <site@example.com>\r\n", "To: <user@example.com>\r\n", "Subject: =?utf-8?q?=C3=89t=C3=A9?=\r\n",
+		"multipart/alternative", "text/plain; charset=utf-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\nplain =C3=A9\r\n",
+		"text/html; charset=utf-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\n<p>html</p>\r\n",
+	} {
+		if !strings.Contains(content, part) {
+			t.Errorf("missing %q in %q", part, content)
+		}
+	}
+	if strings.Index(content, "text/plain") > strings.Index(content, "text/html") {
+		t.Error("the html part should be the last (preferred) one")
+	}
+
+	data, err = buildMessage(from, to, mailservice.Message{Subject: "s", Text: "only text"})
+	if err != nil || !strings.HasSuffix(string(data), "Content-Transfer-Encoding: quoted-printable\r\n\r\nonly text") {
+		t.Errorf("unexpected text message %q (error %v)", data, err)
+	}
+
+	if _, err = buildMessage(from, to, mailservice.Message{Subject: "s"}); !errors.Is(err, errEmptyMessage) {
+		t.Errorf("got %v, want errEmptyMessage", err)
+	}
+}
+
+// minimal SMTP server accepting one message, return its address and a channel with the received data
+func startFakeSmtpServer(t *testing.T) (string, <-chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("no local listener :", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+		reader := bufio.NewReader(conn)
+		conn.Write([]byte("220 localhost\r\n"))
+		var dataBuilder strings.Builder
+		inData := false
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch {
+			case inData && line == ".\r\n":
+				inData = false
+				received <- dataBuilder.String()
+				conn.Write([]byte("250 OK\r\n"))
+			case inData:
+				dataBuilder.WriteString(line)
+			case strings.HasPrefix(line, "DATA"):
+				inData = true
+				conn.Write([]byte("354 go ahead\r\n"))
+			case strings.HasPrefix(line, "QUIT"):
+				conn.Write([]byte("221 bye\r\n"))
+				return
+			default: // EHLO, MAIL and RCPT
+				conn.Write([]byte("250 OK\r\n"))
+			}
+		}
+	}()
+	return listener.Addr().String(), received
+}
+
+func TestSend(t *testing.T) {
+	addr, received := startFakeSmtpServer(t)
+	host, port, _ := net.SplitHostPort(addr)
+	mailer := New(host, port, "", "", &mail.Address{Address: "site@example.com"}, TLSNone)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := mailer.Send(ctx, mailservice.Message{To: "user@example.com", Subject: "Hello", Text: "body"}); err != nil {
+		t.Fatal(err)
+	}
+	if data := <-received; !strings.Contains(data, "Subject: Hello\r\n") || !strings.HasSuffix(data, "\r\nbody\r\n") {
+		t.Errorf("unexpected data %q", data)
+	}
+}
+
+func TestSendRejectHeaderInjection(t *testing.T) {
+	mailer := New("127.0.0.1", "1", "", "", &mail.Address{Address: "site@example.com"}, TLSNone)
+	err := mailer.Send(context.Background(), mailservice.Message{To: "user@example.com\r\nBcc: other@example.com", Text: "body"})
+	if err == nil {
+		t.Error("an address with a new line should be rejected")
+	}
+}
@@ -0,0 +1,57 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package mail
+
+import (
+	"context"
+	"strings"
+
+	"github.com/dvaumoron/puzzleweb/locale"
+	mailservice "github.com/dvaumoron/puzzleweb/mail/service"
+	templateservice "github.com/dvaumoron/puzzleweb/templates/service"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	subjectSuffix = "/subject"
+	textSuffix    = "/text"
+	htmlSuffix    = "/html"
+)
+
+// render the templates "<templateName>/subject", "<templateName>/text" and "<templateName>/html",
+// the lang is added to data so the template service localize the message like the pages
+func RenderMessage(ctx context.Context, templateService templateservice.TemplateService, templateName string, to string, lang string, data gin.H) (mailservice.Message, error) {
+	data[locale.LangName] = lang
+
+	subject, err := templateService.Render(ctx, templateName+subjectSuffix, data)
+	if err != nil {
+		return mailservice.Message{}, err
+	}
+	text, err := templateService.Render(ctx, templateName+textSuffix, data)
+	if err != nil {
+		return mailservice.Message{}, err
+	}
+	html, err := templateService.Render(ctx, templateName+htmlSuffix, data)
+	if err != nil {
+		return mailservice.Message{}, err
+	}
+	return mailservice.Message{
+		To: to, Subject: strings.TrimSpace(string(subject)), Text: string(text), HTML: string(html),
+	}, nil
+}
@@ -0,0 +1,47 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package mail
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dvaumoron/puzzleweb/locale"
+	"github.com/gin-gonic/gin"
+)
+
+// render the name of the template followed by the lang
+type fakeTemplateService struct{}
+
+func (fakeTemplateService) Render(ctx context.Context, templateName string, data any) ([]byte, error) {
+	return []byte(" " + templateName + " " + data.(gin.H)[locale.LangName].(string) + "\n"), nil
+}
+
+func TestRenderMessage(t *testing.T) {
+	message, err := RenderMessage(context.Background(), fakeTemplateService{}, "mail/comment", "user@example.com", "fr", gin.H{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message.To != "user@example.com" || message.Subject != "mail/comment/subject fr" {
+		t.Errorf("unexpected message %+v", message)
+	}
+	if message.Text != " mail/comment/text fr\n" || message.HTML != " mail/comment/html fr\n" {
+		t.Errorf("unexpected bodies %q and %q", message.Text, message.HTML)
+	}
+}
@@ -23,16 +23,22 @@ import (
 
 	grpcclient "github.com/dvaumoron/puzzlegrpcclient"
 	pb "github.com/dvaumoron/puzzlemarkdownservice"
+	"github.com/dvaumoron/puzzleweb/common/grpcretry"
+	"github.com/dvaumoron/puzzleweb/common/log"
 	"github.com/dvaumoron/puzzleweb/markdown/service"
+	"go.uber.org/zap"
 	"google.golang.org/grpc"
 )
 
+const applyErrorMsg = "Failed to apply markdown"
+
 type markdownClient struct {
 	grpcclient.Client
+	loggerGetter log.LoggerGetter
 }
 
-func New(serviceAddr string, dialOptions []grpc.DialOption) service.MarkdownService {
-	return markdownClient{Client: grpcclient.Make(serviceAddr, dialOptions...)}
+func New(serviceAddr string, dialOptions []grpc.DialOption, loggerGetter log.LoggerGetter) service.MarkdownService {
+	return markdownClient{Client: grpcclient.Make(serviceAddr, dialOptions...), loggerGetter: loggerGetter}
 }
 
 func (client markdownClient) Apply(ctx context.Context, text string) (string, error) {
@@ -42,6 +48,10 @@ func (client markdownClient) Apply(ctx context.Context, text string) (string, er
 	}
 	defer conn.Close()
 
-	markdownHtml, err := pb.NewMarkdownClient(conn).Apply(ctx, &pb.MarkdownText{Text: text})
-	return markdownHtml.GetHtml(), err
+	markdownHtml, err := pb.NewMarkdownClient(conn).Apply(ctx, &pb.MarkdownText{Text: text}, grpcretry.WithRetry())
+	if err != nil {
+		client.loggerGetter.Logger(ctx).Error(applyErrorMsg, zap.Error(err))
+		return "", err
+	}
+	return markdownHtml.Html, nil
 }
@@ -0,0 +1,82 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	pb "github.com/dvaumoron/puzzlemarkdownservice"
+	"github.com/dvaumoron/puzzleweb/common/log"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type failingMarkdownServer struct {
+	pb.UnimplementedMarkdownServer
+}
+
+func (failingMarkdownServer) Apply(context.Context, *pb.MarkdownText) (*pb.MarkdownHtml, error) {
+	return nil, errors.New("rendering failed")
+}
+
+type requestKey struct{}
+
+// return the observed logger, only for the context of the request
+type requestLoggerGetter struct {
+	t      *testing.T
+	logger *zap.Logger
+}
+
+func (getter requestLoggerGetter) Logger(ctx context.Context) log.Logger {
+	if ctx.Value(requestKey{}) == nil {
+		getter.t.Error("the logger is not retrieved with the request context")
+	}
+	return getter.logger
+}
+
+func TestApplyFailureLogged(t *testing.T) {
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	pb.RegisterMarkdownServer(server, failingMarkdownServer{})
+	go server.Serve(listener)
+	defer server.Stop()
+
+	core, logs := observer.New(zap.ErrorLevel)
+	markdownService := New("bufnet", []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+	}, requestLoggerGetter{t: t, logger: zap.New(core)})
+
+	ctx := context.WithValue(context.Background(), requestKey{}, true)
+	if _, err := markdownService.Apply(ctx, "# title"); err == nil {
+		t.Error("Apply should fail")
+	}
+
+	if entries := logs.FilterMessage(applyErrorMsg).All(); len(entries) != 1 {
+		t.Errorf("got %d logged failures, want 1", len(entries))
+	}
+}
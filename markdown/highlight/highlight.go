@@ -0,0 +1,95 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package highlight
+
+import (
+	"bytes"
+	"context"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/dvaumoron/puzzleweb/markdown/service"
+)
+
+// fenced code blocks as rendered by the markdown service, the language comes from the info string
+var codeBlockRegexp = regexp.MustCompile(`<pre><code(?: class="language-([^"]+)")?>((?s).*?)</code></pre>`)
+
+type Highlighter struct {
+	formatter *chromahtml.Formatter
+	style     *chroma.Style
+}
+
+// the generated html use css classes starting with classPrefix (see CSS), an unknown style fallback to the default one
+func New(styleName string, classPrefix string) Highlighter {
+	return Highlighter{
+		formatter: chromahtml.New(chromahtml.WithClasses(true), chromahtml.ClassPrefix(classPrefix)),
+		style:     styles.Get(styleName),
+	}
+}
+
+func (h Highlighter) CSS() ([]byte, error) {
+	var buffer bytes.Buffer
+	err := h.formatter.WriteCSS(&buffer, h.style)
+	return buffer.Bytes(), err
+}
+
+// code blocks without language or with an unknown one are left untouched
+func (h Highlighter) Highlight(htmlText string) string {
+	return codeBlockRegexp.ReplaceAllStringFunc(htmlText, func(block string) string {
+		submatches := codeBlockRegexp.FindStringSubmatch(block)
+		lexer := lexers.Get(submatches[1])
+		if lexer == nil {
+			return block
+		}
+
+		iterator, err := chroma.Coalesce(lexer).Tokenise(nil, html.UnescapeString(submatches[2]))
+		if err != nil {
+			return block
+		}
+
+		var builder strings.Builder
+		if err = h.formatter.Format(&builder, h.style, iterator); err != nil {
+			return block
+		}
+		return builder.String()
+	})
+}
+
+// add the highlighting pass after the markdown rendering
+func (h Highlighter) Wrap(markdownService service.MarkdownService) service.MarkdownService {
+	return highlightService{markdownService: markdownService, highlighter: h}
+}
+
+type highlightService struct {
+	markdownService service.MarkdownService
+	highlighter     Highlighter
+}
+
+func (s highlightService) Apply(ctx context.Context, text string) (string, error) {
+	htmlText, err := s.markdownService.Apply(ctx, text)
+	if err != nil {
+		return "", err
+	}
+	return s.highlighter.Highlight(htmlText), nil
+}
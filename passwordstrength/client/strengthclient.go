@@ -23,6 +23,7 @@ import (
 
 	grpcclient "github.com/dvaumoron/puzzlegrpcclient"
 	pb "github.com/dvaumoron/puzzlepassstrengthservice"
+	"github.com/dvaumoron/puzzleweb/common/grpcretry"
 	strengthservice "github.com/dvaumoron/puzzleweb/passwordstrength/service"
 	"google.golang.org/grpc"
 )
@@ -42,7 +43,7 @@ func (client strengthClient) Validate(ctx context.Context, password string) (boo
 	}
 	defer conn.Close()
 
-	response, err := pb.NewPassstrengthClient(conn).Check(ctx, &pb.PasswordRequest{Password: password})
+	response, err := pb.NewPassstrengthClient(conn).Check(ctx, &pb.PasswordRequest{Password: password}, grpcretry.WithRetry())
 	if err != nil {
 		return false, err
 	}
@@ -56,7 +57,7 @@ func (client strengthClient) GetRules(ctx context.Context, lang string) (string,
 	}
 	defer conn.Close()
 
-	response, err := pb.NewPassstrengthClient(conn).GetRules(ctx, &pb.LangRequest{Lang: lang})
+	response, err := pb.NewPassstrengthClient(conn).GetRules(ctx, &pb.LangRequest{Lang: lang}, grpcretry.WithRetry())
 	if err != nil {
 		return "", err
 	}
@@ -25,6 +25,7 @@ import (
 	pb "github.com/dvaumoron/puzzleprofileservice"
 	adminservice "github.com/dvaumoron/puzzleweb/admin/service"
 	"github.com/dvaumoron/puzzleweb/common"
+	"github.com/dvaumoron/puzzleweb/common/grpcretry"
 	"github.com/dvaumoron/puzzleweb/common/log"
 	loginservice "github.com/dvaumoron/puzzleweb/login/service"
 	profileservice "github.com/dvaumoron/puzzleweb/profile/service"
@@ -92,11 +93,14 @@ func (client profileClient) GetPicture(ctx context.Context, userId uint64) []byt
 	}
 	defer conn.Close()
 
-	response, err := pb.NewProfileClient(conn).GetPicture(ctx, &pb.UserId{Id: userId})
+	response, err := pb.NewProfileClient(conn).GetPicture(ctx, &pb.UserId{Id: userId}, grpcretry.WithRetry())
 	if err != nil {
 		common.LogOriginalError(logger, err)
 		return client.defaultPicture
 	}
+	if len(response.Data) == 0 {
+		return client.defaultPicture
+	}
 	return response.Data
 }
 
@@ -112,7 +116,7 @@ func (client profileClient) GetProfiles(ctx context.Context, userIds []uint64) (
 
 	response, err := pb.NewProfileClient(conn).ListProfiles(ctx, &pb.UserIds{
 		Ids: userIds,
-	})
+	}, grpcretry.WithRetry())
 	if err != nil {
 		return nil, err
 	}
@@ -129,7 +133,14 @@ func (client profileClient) GetProfiles(ctx context.Context, userIds []uint64) (
 	}
 
 	profiles := map[uint64]profileservice.UserProfile{}
-	for userId, user := range users {
+	for _, userId := range userIds {
+		user, ok := users[userId]
+		if !ok {
+			// the authored content of a deleted user stay anonymized
+			profiles[userId] = profileservice.UserProfile{User: loginservice.User{Login: profileservice.DeletedUserLogin}}
+			continue
+		}
+
 		profile, ok := tempProfiles[userId]
 		if ok {
 			profiles[userId] = profile
@@ -24,6 +24,12 @@ import (
 	loginservice "github.com/dvaumoron/puzzleweb/login/service"
 )
 
+// displayed in place of the login of a deleted author
+const DeletedUserLogin = "[deleted]"
+
+// key of the profile info read to notify the user by mail
+const EmailInfoName = "Email"
+
 type UserProfile struct {
 	loginservice.User
 	Desc string
@@ -25,6 +25,7 @@ import (
 
 	grpcclient "github.com/dvaumoron/puzzlegrpcclient"
 	"github.com/dvaumoron/puzzleweb/common"
+	"github.com/dvaumoron/puzzleweb/common/grpcretry"
 	"github.com/dvaumoron/puzzleweb/common/log"
 	widgetservice "github.com/dvaumoron/puzzleweb/remotewidget/service"
 	pb "github.com/dvaumoron/puzzlewidgetservice"
@@ -55,7 +56,7 @@ func (client widgetClient) GetDesc(ctx context.Context) ([]widgetservice.Action,
 	}
 	defer conn.Close()
 
-	response, err := pb.NewWidgetClient(conn).GetWidget(ctx, &pb.WidgetRequest{Name: client.widgetName})
+	response, err := pb.NewWidgetClient(conn).GetWidget(ctx, &pb.WidgetRequest{Name: client.widgetName}, grpcretry.WithRetry())
 	if err != nil {
 		return nil, err
 	}
@@ -24,6 +24,7 @@ import (
 	grpcclient "github.com/dvaumoron/puzzlegrpcclient"
 	pb "github.com/dvaumoron/puzzlesessionservice"
 	"github.com/dvaumoron/puzzleweb/common"
+	"github.com/dvaumoron/puzzleweb/common/grpcretry"
 	sessionservice "github.com/dvaumoron/puzzleweb/session/service"
 	"google.golang.org/grpc"
 )
@@ -56,7 +57,7 @@ func (client sessionClient) Get(ctx context.Context, id uint64) (map[string]stri
 	}
 	defer conn.Close()
 
-	response, err := pb.NewSessionClient(conn).GetSessionInfo(ctx, &pb.SessionId{Id: id})
+	response, err := pb.NewSessionClient(conn).GetSessionInfo(ctx, &pb.SessionId{Id: id}, grpcretry.WithRetry())
 	return response.GetInfo(), err
 }
 
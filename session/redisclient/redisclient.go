@@ -0,0 +1,267 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package redisclient
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dvaumoron/puzzleweb/common"
+	sessionservice "github.com/dvaumoron/puzzleweb/session/service"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	keyPrefix = "session:"
+	// field allowing the creation of an empty session
+	createdField  = "_created"
+	lastSeenField = "_seen"
+
+	// first byte of stored values
+	plainMarker = 'p'
+	gzipMarker  = 'z'
+)
+
+// number of try to find an unused id
+const generateMaxTry = 5
+
+// avoid a write on each read of a session
+const lastSeenPrecision = time.Minute
+
+const scanBatchSize = 100
+
+var (
+	errGenerate     = errors.New("failed to generate an unused session id")
+	errUnknownValue = errors.New("unknown session value format")
+)
+
+// session are stored as hash, allowing update of only the changed keys
+type redisClient struct {
+	client            *redis.Client
+	ttl               time.Duration
+	compressThreshold int
+}
+
+// values longer than compressThreshold are gzipped (0 disable compression)
+func New(client *redis.Client, ttl time.Duration, compressThreshold int) sessionservice.DeltaSessionService {
+	return redisClient{client: client, ttl: ttl, compressThreshold: compressThreshold}
+}
+
+func (client redisClient) Generate(ctx context.Context) (uint64, error) {
+	idBytes := make([]byte, 8)
+	for i := 0; i < generateMaxTry; i++ {
+		if _, err := rand.Read(idBytes); err != nil {
+			return 0, err
+		}
+
+		id := binary.LittleEndian.Uint64(idBytes)
+		key := sessionKey(id)
+		created, err := client.client.HSetNX(ctx, key, createdField, time.Now().Unix()).Result()
+		if err != nil {
+			return 0, err
+		}
+		if created {
+			return id, client.client.Expire(ctx, key, client.ttl).Err()
+		}
+	}
+	return 0, errGenerate
+}
+
+// return a nil map with an unknown (or expired) id, like the session service
+func (client redisClient) Get(ctx context.Context, id uint64) (map[string]string, error) {
+	key := sessionKey(id)
+	var getCmd *redis.MapStringStringCmd
+	_, err := client.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		getCmd = pipe.HGetAll(ctx, key)
+		pipe.Expire(ctx, key, client.ttl)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stored := getCmd.Val()
+	if len(stored) == 0 {
+		return nil, nil
+	}
+
+	if time.Since(parseTime(stored[lastSeenField])) > lastSeenPrecision {
+		if err = client.client.HSet(ctx, key, lastSeenField, time.Now().Unix()).Err(); err != nil {
+			return nil, err
+		}
+	}
+	return decodeInfo(stored)
+}
+
+// empty values are deleted
+func (client redisClient) Update(ctx context.Context, id uint64, info map[string]string) error {
+	changed := make(map[string]string, len(info))
+	for field, value := range info {
+		if value != "" {
+			changed[field] = value
+		}
+	}
+	return client.update(ctx, id, true, changed, nil)
+}
+
+func (client redisClient) UpdateDelta(ctx context.Context, id uint64, changed map[string]string, deleted []string) error {
+	return client.update(ctx, id, false, changed, deleted)
+}
+
+func (client redisClient) update(ctx context.Context, id uint64, replace bool, changed map[string]string, deleted []string) error {
+	values := make([]any, 0, 2*len(changed)+2)
+	values = append(values, lastSeenField, time.Now().Unix())
+	for field, value := range changed {
+		encoded, err := client.encodeValue(value)
+		if err != nil {
+			return err
+		}
+		values = append(values, field, encoded)
+	}
+
+	key := sessionKey(id)
+	_, err := client.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if replace {
+			pipe.Del(ctx, key)
+		}
+		if len(deleted) != 0 {
+			pipe.HDel(ctx, key, deleted...)
+		}
+		pipe.HSetNX(ctx, key, createdField, time.Now().Unix())
+		pipe.HSet(ctx, key, values...)
+		pipe.Expire(ctx, key, client.ttl)
+		return nil
+	})
+	if err != nil {
+		return common.ErrUpdate
+	}
+	return nil
+}
+
+func (client redisClient) ListSessions(ctx context.Context) ([]sessionservice.SessionInfo, error) {
+	var sessions []sessionservice.SessionInfo
+	iter := client.client.Scan(ctx, 0, keyPrefix+"*", scanBatchSize).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		id, err := strconv.ParseUint(strings.TrimPrefix(key, keyPrefix), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		stored, err := client.client.HGetAll(ctx, key).Result()
+		if err != nil {
+			return nil, err
+		}
+		// expired between the scan and the read
+		if len(stored) == 0 {
+			continue
+		}
+
+		info, err := decodeInfo(stored)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, sessionservice.SessionInfo{
+			Id: id, Created: parseTime(stored[createdField]), LastSeen: parseTime(stored[lastSeenField]), Info: info,
+		})
+	}
+	return sessions, iter.Err()
+}
+
+func (client redisClient) DeleteSession(ctx context.Context, id uint64) error {
+	if err := client.client.Del(ctx, sessionKey(id)).Err(); err != nil {
+		return common.ErrUpdate
+	}
+	return nil
+}
+
+func (client redisClient) encodeValue(value string) ([]byte, error) {
+	if client.compressThreshold == 0 || len(value) <= client.compressThreshold {
+		return append([]byte{plainMarker}, value...), nil
+	}
+
+	var buffer bytes.Buffer
+	buffer.WriteByte(gzipMarker)
+	writer := gzip.NewWriter(&buffer)
+	if _, err := writer.Write([]byte(value)); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func decodeInfo(stored map[string]string) (map[string]string, error) {
+	info := make(map[string]string, len(stored))
+	for field, value := range stored {
+		if field == createdField || field == lastSeenField {
+			continue
+		}
+
+		decoded, err := decodeValue(value)
+		if err != nil {
+			return nil, err
+		}
+		info[field] = decoded
+	}
+	return info, nil
+}
+
+func decodeValue(value string) (string, error) {
+	if value == "" {
+		return "", errUnknownValue
+	}
+
+	switch value[0] {
+	case plainMarker:
+		return value[1:], nil
+	case gzipMarker:
+		reader, err := gzip.NewReader(bytes.NewReader([]byte(value[1:])))
+		if err != nil {
+			return "", err
+		}
+		defer reader.Close()
+
+		decoded, err := io.ReadAll(reader)
+		return string(decoded), err
+	}
+	return "", errUnknownValue
+}
+
+// zero time when missing or malformed
+func parseTime(value string) time.Time {
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0)
+}
+
+func sessionKey(id uint64) string {
+	return keyPrefix + strconv.FormatUint(id, 10)
+}
@@ -18,10 +18,33 @@
 
 package sessionservice
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type SessionService interface {
 	Generate(ctx context.Context) (uint64, error)
 	Get(ctx context.Context, id uint64) (map[string]string, error)
 	Update(ctx context.Context, id uint64, info map[string]string) error
 }
+
+// optional, implemented by store able to apply only the changes
+type DeltaSessionService interface {
+	SessionService
+	UpdateDelta(ctx context.Context, id uint64, changed map[string]string, deleted []string) error
+}
+
+type SessionInfo struct {
+	Id       uint64
+	Created  time.Time
+	LastSeen time.Time
+	Info     map[string]string
+}
+
+// optional, implemented by store able to enumerate its sessions
+type AdminSessionService interface {
+	SessionService
+	ListSessions(ctx context.Context) ([]SessionInfo, error)
+	DeleteSession(ctx context.Context, id uint64) error
+}
@@ -25,6 +25,7 @@ import (
 	grpcclient "github.com/dvaumoron/puzzlegrpcclient"
 	pb "github.com/dvaumoron/puzzletemplateservice"
 	"github.com/dvaumoron/puzzleweb/common"
+	"github.com/dvaumoron/puzzleweb/common/grpcretry"
 	"github.com/dvaumoron/puzzleweb/common/log"
 	templateservice "github.com/dvaumoron/puzzleweb/templates/service"
 	"go.uber.org/zap"
@@ -54,7 +55,7 @@ func (client templateClient) Render(ctx context.Context, templateName string, da
 	defer conn.Close()
 
 	response, err := pb.NewTemplateClient(conn).Render(
-		ctx, &pb.RenderRequest{TemplateName: templateName, Data: dataBytes},
+		ctx, &pb.RenderRequest{TemplateName: templateName, Data: dataBytes}, grpcretry.WithRetry(),
 	)
 	if err != nil {
 		return nil, err
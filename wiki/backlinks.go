@@ -0,0 +1,148 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package wiki
+
+import (
+	"slices"
+	"sync"
+)
+
+// max number of pages loaded by the crawl of a lang
+const maxCrawledPages = 1000
+
+// backlinks of the pages seen (saved, viewed or crawled) by this instance, the wiki service can not list the pages,
+// so the crawl only reaches the pages linked from the default one
+type linkIndex struct {
+	mutex    sync.RWMutex
+	outgoing map[string][]string
+	incoming map[string]map[string]struct{}
+	crawled  map[string]bool // lang -> crawl completed (absent when not started)
+}
+
+func newLinkIndex() *linkIndex {
+	return &linkIndex{
+		outgoing: map[string][]string{}, incoming: map[string]map[string]struct{}{}, crawled: map[string]bool{},
+	}
+}
+
+func buildRef(lang string, title string) string {
+	return lang + "/" + title
+}
+
+// replace the links of the page (nil links remove the page from the index)
+func (index *linkIndex) update(lang string, title string, links []string) {
+	ref := buildRef(lang, title)
+
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	for _, link := range index.outgoing[ref] {
+		linkRef := buildRef(lang, link)
+		if sources := index.incoming[linkRef]; sources != nil {
+			delete(sources, title)
+			if len(sources) == 0 {
+				delete(index.incoming, linkRef)
+			}
+		}
+	}
+
+	if len(links) == 0 {
+		delete(index.outgoing, ref)
+		return
+	}
+
+	index.outgoing[ref] = links
+	for _, link := range links {
+		linkRef := buildRef(lang, link)
+		sources := index.incoming[linkRef]
+		if sources == nil {
+			sources = map[string]struct{}{}
+			index.incoming[linkRef] = sources
+		}
+		sources[title] = struct{}{}
+	}
+}
+
+// sorted titles of the pages linking to the given one
+func (index *linkIndex) backlinks(lang string, title string) []string {
+	index.mutex.RLock()
+	sources := index.incoming[buildRef(lang, title)]
+	res := make([]string, 0, len(sources))
+	for source := range sources {
+		res = append(res, source)
+	}
+	index.mutex.RUnlock()
+
+	slices.Sort(res)
+	return res
+}
+
+// start (once per lang) a background crawl of the pages reachable from the start one,
+// loadLinks returns the links of a page (nil for a missing one)
+func (index *linkIndex) startCrawl(lang string, start string, loadLinks func(string) ([]string, error)) {
+	index.mutex.Lock()
+	_, started := index.crawled[lang]
+	if !started {
+		index.crawled[lang] = false
+	}
+	index.mutex.Unlock()
+
+	if !started {
+		go index.crawl(lang, start, loadLinks)
+	}
+}
+
+func (index *linkIndex) crawl(lang string, start string, loadLinks func(string) ([]string, error)) {
+	seen := map[string]struct{}{start: {}}
+	queue := []string{start}
+	for loaded := 0; len(queue) != 0; loaded++ {
+		if loaded == maxCrawledPages {
+			return // stay partial
+		}
+
+		title := queue[0]
+		queue = queue[1:]
+		links, err := loadLinks(title)
+		if err != nil {
+			index.mutex.Lock()
+			delete(index.crawled, lang) // retried by the next call to startCrawl
+			index.mutex.Unlock()
+			return
+		}
+
+		index.update(lang, title, links)
+		for _, link := range links {
+			if _, ok := seen[link]; !ok {
+				seen[link] = struct{}{}
+				queue = append(queue, link)
+			}
+		}
+	}
+
+	index.mutex.Lock()
+	index.crawled[lang] = true
+	index.mutex.Unlock()
+}
+
+// false while the crawl of the lang is not completed
+func (index *linkIndex) complete(lang string) bool {
+	index.mutex.RLock()
+	defer index.mutex.RUnlock()
+	return index.crawled[lang]
+}
@@ -0,0 +1,85 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package wiki
+
+import (
+	"errors"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func waitCrawl(t *testing.T, index *linkIndex, lang string) {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		if index.complete(lang) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("crawl not completed")
+}
+
+func TestCrawl(t *testing.T) {
+	pages := map[string][]string{
+		"Welcome": {"A", "B"},
+		"A":       {"B", "Welcome"},
+		"B":       {"Missing"},
+	}
+	index := newLinkIndex()
+	index.startCrawl("en", "Welcome", func(title string) ([]string, error) {
+		return pages[title], nil
+	})
+	waitCrawl(t, index, "en")
+
+	if got := index.backlinks("en", "B"); !slices.Equal(got, []string{"A", "Welcome"}) {
+		t.Errorf("backlinks of B: got %v", got)
+	}
+	if got := index.backlinks("en", "Missing"); !slices.Equal(got, []string{"B"}) {
+		t.Errorf("backlinks of Missing: got %v", got)
+	}
+	if index.complete("fr") {
+		t.Error("crawl of an other lang should not be completed")
+	}
+}
+
+func TestCrawlFailure(t *testing.T) {
+	index := newLinkIndex()
+	failed := make(chan struct{})
+	index.startCrawl("en", "Welcome", func(title string) ([]string, error) {
+		defer close(failed)
+		return nil, errors.New("unavailable")
+	})
+	<-failed
+
+	var calls atomic.Int32
+	for i := 0; i < 100 && calls.Load() == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		// the failed crawl is retried
+		index.startCrawl("en", "Welcome", func(title string) ([]string, error) {
+			calls.Add(1)
+			return nil, nil
+		})
+	}
+	waitCrawl(t, index, "en")
+	if count := calls.Load(); count != 1 {
+		t.Errorf("expected one retried load, got %d", count)
+	}
+}
@@ -20,6 +20,7 @@ package wikicache
 
 import (
 	"sync"
+	"time"
 
 	"github.com/dvaumoron/puzzleweb/common/log"
 	wikiservice "github.com/dvaumoron/puzzleweb/wiki/service"
@@ -60,3 +61,42 @@ func (wiki *WikiCache) Delete(logger log.Logger, wikiRef string) {
 	wiki.mutex.Unlock()
 	logger.Debug("wikiCache delete", zap.String(wikiRefName, wikiRef))
 }
+
+type existence struct {
+	exists     bool
+	expiration time.Time
+}
+
+// existence of the pages for a short time, avoid a call to the wiki service by internal link on each view
+type ExistenceCache struct {
+	mutex sync.RWMutex
+	ttl   time.Duration
+	cache map[string]existence
+}
+
+func NewExistenceCache(ttl time.Duration) *ExistenceCache {
+	return &ExistenceCache{ttl: ttl, cache: map[string]existence{}}
+}
+
+// ok is false when the existence is unknown or expired
+func (existences *ExistenceCache) Load(wikiRef string) (exists bool, ok bool) {
+	existences.mutex.RLock()
+	state, ok := existences.cache[wikiRef]
+	existences.mutex.RUnlock()
+	if !ok || time.Now().After(state.expiration) {
+		return false, false
+	}
+	return state.exists, true
+}
+
+func (existences *ExistenceCache) Store(wikiRef string, exists bool) {
+	existences.mutex.Lock()
+	existences.cache[wikiRef] = existence{exists: exists, expiration: time.Now().Add(existences.ttl)}
+	existences.mutex.Unlock()
+}
+
+func (existences *ExistenceCache) Delete(wikiRef string) {
+	existences.mutex.Lock()
+	delete(existences.cache, wikiRef)
+	existences.mutex.Unlock()
+}
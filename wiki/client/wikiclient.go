@@ -22,22 +22,32 @@ import (
 	"context"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	grpcclient "github.com/dvaumoron/puzzlegrpcclient"
 	adminservice "github.com/dvaumoron/puzzleweb/admin/service"
 	"github.com/dvaumoron/puzzleweb/common"
+	"github.com/dvaumoron/puzzleweb/common/grpcretry"
 	"github.com/dvaumoron/puzzleweb/common/log"
 	profileservice "github.com/dvaumoron/puzzleweb/profile/service"
 	wikicache "github.com/dvaumoron/puzzleweb/wiki/client/cache"
 	wikiservice "github.com/dvaumoron/puzzleweb/wiki/service"
 	pb "github.com/dvaumoron/puzzlewikiservice"
 	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
 	"google.golang.org/grpc"
 )
 
+const (
+	existenceTTL       = time.Minute
+	maxExistenceChecks = 8 // concurrent calls by ExistingPages
+)
+
 type wikiClient struct {
 	grpcclient.Client
 	cache          *wikicache.WikiCache
+	existences     *wikicache.ExistenceCache
 	wikiId         uint64
 	groupId        uint64
 	dateFormat     string
@@ -48,7 +58,8 @@ type wikiClient struct {
 
 func New(serviceAddr string, dialOptions []grpc.DialOption, wikiId uint64, groupId uint64, dateFormat string, authService adminservice.AuthService, profileService profileservice.ProfileService, loggerGetter log.LoggerGetter) wikiservice.WikiService {
 	return wikiClient{
-		Client: grpcclient.Make(serviceAddr, dialOptions...), cache: wikicache.NewCache(), wikiId: wikiId, groupId: groupId,
+		Client: grpcclient.Make(serviceAddr, dialOptions...), cache: wikicache.NewCache(),
+		existences: wikicache.NewExistenceCache(existenceTTL), wikiId: wikiId, groupId: groupId,
 		dateFormat: dateFormat, authService: authService, profileService: profileService, loggerGetter: loggerGetter,
 	}
 }
@@ -85,7 +96,7 @@ func (client wikiClient) LoadContent(ctx context.Context, userId uint64, lang st
 
 	versions, err := pbWikiClient.ListVersions(ctx, &pb.VersionRequest{
 		WikiId: wikiId, WikiRef: wikiRef,
-	})
+	}, grpcretry.WithRetry())
 	if err != nil {
 		return nil, err
 	}
@@ -134,6 +145,7 @@ func (client wikiClient) StoreContent(ctx context.Context, userId uint64, lang s
 	client.cache.Store(logger, wikiRef, &wikiservice.WikiContent{
 		Version: response.Version, Markdown: markdown,
 	})
+	client.existences.Store(wikiRef, true)
 	return nil
 }
 
@@ -153,7 +165,7 @@ func (client wikiClient) GetVersions(ctx context.Context, userId uint64, lang st
 
 	response, err := pb.NewWikiClient(conn).ListVersions(ctx, &pb.VersionRequest{
 		WikiId: client.wikiId, WikiRef: wikiRef,
-	})
+	}, grpcretry.WithRetry())
 	if err != nil {
 		return nil, err
 	}
@@ -193,6 +205,7 @@ func (client wikiClient) DeleteContent(ctx context.Context, userId uint64, lang
 	if content != nil && version == content.Version {
 		client.cache.Delete(logger, wikiRef)
 	}
+	client.existences.Delete(wikiRef) // the page could have lost its last version
 	return err
 }
 
@@ -200,10 +213,80 @@ func (client wikiClient) DeleteRight(ctx context.Context, userId uint64) bool {
 	return client.authService.AuthQuery(ctx, userId, client.groupId, adminservice.ActionDelete) == nil
 }
 
+// titles with at least one version
+func (client wikiClient) ExistingPages(ctx context.Context, userId uint64, lang string, titles []string) (common.Set[string], error) {
+	err := client.authService.AuthQuery(ctx, userId, client.groupId, adminservice.ActionAccess)
+	if err != nil {
+		return nil, err
+	}
+
+	logger := client.loggerGetter.Logger(ctx)
+
+	res := common.Set[string]{}
+	var unknowns []string
+	for _, title := range titles {
+		wikiRef := buildRef(lang, title)
+		if client.cache.Load(logger, wikiRef) != nil {
+			res.Add(title)
+		} else if exists, ok := client.existences.Load(wikiRef); !ok {
+			unknowns = append(unknowns, title)
+		} else if exists {
+			res.Add(title)
+		}
+	}
+	if len(unknowns) == 0 {
+		return res, nil
+	}
+
+	conn, err := client.Dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var mutex sync.Mutex
+	pbWikiClient := pb.NewWikiClient(conn)
+	g, gCtx := errgroup.WithContext(ctx)
+	g.SetLimit(maxExistenceChecks)
+	for _, title := range unknowns {
+		title := title
+		g.Go(func() error {
+			wikiRef := buildRef(lang, title)
+			versions, err := pbWikiClient.ListVersions(gCtx, &pb.VersionRequest{
+				WikiId: client.wikiId, WikiRef: wikiRef,
+			}, grpcretry.WithRetry())
+			if err != nil {
+				return err
+			}
+
+			exists := len(versions.List) != 0
+			client.existences.Store(wikiRef, exists)
+			if exists {
+				mutex.Lock()
+				res.Add(title)
+				mutex.Unlock()
+			}
+			return nil
+		})
+	}
+	if err = g.Wait(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (client wikiClient) ViewRight(ctx context.Context, userId uint64) error {
+	return client.authService.AuthQuery(ctx, userId, client.groupId, adminservice.ActionAccess)
+}
+
+func (client wikiClient) EditRight(ctx context.Context, userId uint64) error {
+	return client.authService.AuthQuery(ctx, userId, client.groupId, adminservice.ActionCreate)
+}
+
 func (client wikiClient) innerLoadContent(ctx context.Context, pbWikiClient pb.WikiClient, wikiRef string, askedVersion uint64) (*wikiservice.WikiContent, error) {
 	response, err := pbWikiClient.Load(ctx, &pb.WikiRequest{
 		WikiId: client.wikiId, WikiRef: wikiRef, Version: askedVersion,
-	})
+	}, grpcretry.WithRetry())
 	if err != nil {
 		return nil, err
 	}
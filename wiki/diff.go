@@ -0,0 +1,100 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package wiki
+
+import "strings"
+
+const (
+	diffSame    = "same"
+	diffAdded   = "added"
+	diffRemoved = "removed"
+
+	// the table of the longest common subsequence is quadratic
+	maxDiffCells = 1 << 22
+)
+
+type DiffLine struct {
+	Kind string
+	Text string
+}
+
+// line diff from oldText to newText, nil when the texts are too different to be compared
+func diffLines(oldText string, newText string) []DiffLine {
+	// the browsers send the textarea with CRLF
+	oldLines := strings.Split(strings.ReplaceAll(oldText, "\r\n", "\n"), "\n")
+	newLines := strings.Split(strings.ReplaceAll(newText, "\r\n", "\n"), "\n")
+
+	// the common start and end are not in the table
+	prefix := 0
+	for prefix < len(oldLines) && prefix < len(newLines) && oldLines[prefix] == newLines[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(oldLines)-prefix && suffix < len(newLines)-prefix &&
+		oldLines[len(oldLines)-1-suffix] == newLines[len(newLines)-1-suffix] {
+		suffix++
+	}
+	oldMiddle := oldLines[prefix : len(oldLines)-suffix]
+	newMiddle := newLines[prefix : len(newLines)-suffix]
+	oldSize, newSize := len(oldMiddle), len(newMiddle)
+	width := newSize + 1
+	if (oldSize+1)*width > maxDiffCells {
+		return nil
+	}
+
+	// lengths[i*width+j] is the length of the longest common subsequence of oldMiddle[i:] and newMiddle[j:]
+	lengths := make([]int32, (oldSize+1)*width)
+	for i := oldSize - 1; i >= 0; i-- {
+		for j := newSize - 1; j >= 0; j-- {
+			if oldMiddle[i] == newMiddle[j] {
+				lengths[i*width+j] = lengths[(i+1)*width+j+1] + 1
+			} else {
+				lengths[i*width+j] = max(lengths[(i+1)*width+j], lengths[i*width+j+1])
+			}
+		}
+	}
+
+	res := make([]DiffLine, 0, len(oldLines)+newSize)
+	res = appendDiffLines(res, diffSame, oldLines[:prefix])
+	i, j := 0, 0
+	for i < oldSize && j < newSize {
+		switch {
+		case oldMiddle[i] == newMiddle[j]:
+			res = append(res, DiffLine{Kind: diffSame, Text: oldMiddle[i]})
+			i++
+			j++
+		case lengths[(i+1)*width+j] >= lengths[i*width+j+1]:
+			res = append(res, DiffLine{Kind: diffRemoved, Text: oldMiddle[i]})
+			i++
+		default:
+			res = append(res, DiffLine{Kind: diffAdded, Text: newMiddle[j]})
+			j++
+		}
+	}
+	res = appendDiffLines(res, diffRemoved, oldMiddle[i:])
+	res = appendDiffLines(res, diffAdded, newMiddle[j:])
+	return appendDiffLines(res, diffSame, oldLines[len(oldLines)-suffix:])
+}
+
+func appendDiffLines(res []DiffLine, kind string, lines []string) []DiffLine {
+	for _, line := range lines {
+		res = append(res, DiffLine{Kind: kind, Text: line})
+	}
+	return res
+}
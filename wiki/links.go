@@ -0,0 +1,199 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package wiki
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/dvaumoron/puzzleweb/common"
+	"github.com/dvaumoron/puzzleweb/common/log"
+	"go.uber.org/zap"
+)
+
+const (
+	// [[Title]] or [[Title|label]]
+	defaultLinkPattern      = `\[\[([^\[\]|]+)(?:\|([^\[\]]*))?\]\]`
+	defaultMissingLinkClass = "missing-page"
+)
+
+// internal links are left as is in the content of these elements (code or an other link)
+var skippedElements = []string{"a", "code", "pre", "script", "style", "textarea"}
+
+type linkRenderer struct {
+	pattern        *regexp.Regexp
+	missingClass   string
+	normalizeTitle func(string) string
+}
+
+func newLinkRenderer(logger log.Logger, pattern string, missingClass string, normalizeTitle func(string) string) linkRenderer {
+	linkRegexp := regexp.MustCompile(defaultLinkPattern)
+	if pattern != "" {
+		if customRegexp, err := regexp.Compile(pattern); err != nil {
+			logger.Warn("Failed to compile wiki linkPattern, using default", zap.Error(err))
+		} else if customRegexp.NumSubexp() == 0 {
+			logger.Warn("Wiki linkPattern should capture the title, using default", zap.String("linkPattern", pattern))
+		} else {
+			linkRegexp = customRegexp
+		}
+	}
+	if missingClass == "" {
+		missingClass = defaultMissingLinkClass
+	}
+	return linkRenderer{pattern: linkRegexp, missingClass: missingClass, normalizeTitle: normalizeTitle}
+}
+
+// titles of the internal links in the markdown (normalized, without duplicate nor self link)
+func (r linkRenderer) extractLinks(markdown string, title string) []string {
+	var links []string
+	for _, match := range r.pattern.FindAllStringSubmatch(markdown, -1) {
+		link := r.normalizeTitle(strings.TrimSpace(match[1]))
+		if link != "" && link != title && !slices.Contains(links, link) {
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+// titles of the internal links in the html body
+func (r linkRenderer) bodyLinks(body string) []string {
+	var links []string
+	replaceInText(body, func(part string) string {
+		for _, match := range r.pattern.FindAllStringSubmatch(part, -1) {
+			if link := r.htmlTitle(match[1]); link != "" && !slices.Contains(links, link) {
+				links = append(links, link)
+			}
+		}
+		return part
+	})
+	return links
+}
+
+// replace the internal links of the html body by links to their view, with the missing class when the page does not exist
+func (r linkRenderer) render(body string, baseUrl string, lang string, existing common.Set[string]) string {
+	return replaceInText(body, func(part string) string {
+		return r.pattern.ReplaceAllStringFunc(part, func(link string) string {
+			match := r.pattern.FindStringSubmatch(link)
+			title := r.htmlTitle(match[1])
+			if title == "" {
+				return link
+			}
+
+			label := strings.TrimSpace(match[1])
+			if len(match) > 2 && strings.TrimSpace(match[2]) != "" {
+				label = strings.TrimSpace(match[2])
+			}
+			// the text node is escaped by the markdown rendering, but a custom pattern can capture a lone '&'
+			label = html.EscapeString(html.UnescapeString(label))
+
+			var linkBuilder strings.Builder
+			linkBuilder.WriteString(`<a href="`)
+			linkBuilder.WriteString(html.EscapeString(wikiUrlBuilder(baseUrl, lang, viewMode, url.PathEscape(title)).String()))
+			linkBuilder.WriteByte('"')
+			if !existing.Contains(title) {
+				linkBuilder.WriteString(` class="`)
+				linkBuilder.WriteString(html.EscapeString(r.missingClass))
+				linkBuilder.WriteByte('"')
+			}
+			linkBuilder.WriteByte('>')
+			linkBuilder.WriteString(label)
+			linkBuilder.WriteString("</a>")
+			return linkBuilder.String()
+		})
+	})
+}
+
+func (r linkRenderer) htmlTitle(escaped string) string {
+	return r.normalizeTitle(strings.TrimSpace(html.UnescapeString(escaped)))
+}
+
+// apply the replacer to the text nodes of the html body, tags (with their attributes),
+// comments and the content of the skipped elements are left as is
+func replaceInText(body string, replacer func(string) string) string {
+	var resBuilder strings.Builder
+	previous := 0
+	for index := 0; index < len(body); {
+		if body[index] != '<' {
+			index++
+			continue
+		}
+
+		resBuilder.WriteString(replacer(body[previous:index]))
+		end := tagEnd(body, index)
+		if name := openingTagName(body[index:end]); slices.Contains(skippedElements, name) {
+			end = closingTagEnd(body, end, name)
+		}
+		resBuilder.WriteString(body[index:end])
+		previous, index = end, end
+	}
+	resBuilder.WriteString(replacer(body[previous:]))
+	return resBuilder.String()
+}
+
+// index after the tag (or the comment) starting at index, quoted attribute values can contain '>'
+func tagEnd(body string, index int) int {
+	if strings.HasPrefix(body[index:], "<!--") {
+		if end := strings.Index(body[index+4:], "-->"); end != -1 {
+			return index + 4 + end + 3
+		}
+		return len(body)
+	}
+
+	var quote byte
+	for index++; index < len(body); index++ {
+		switch char := body[index]; {
+		case quote != 0:
+			if char == quote {
+				quote = 0
+			}
+		case char == '"' || char == '\'':
+			quote = char
+		case char == '>':
+			return index + 1
+		}
+	}
+	return len(body)
+}
+
+// lower case name of an opening tag, empty for a closing one or a comment
+func openingTagName(tag string) string {
+	end := 1
+	for end < len(tag) && isAsciiAlphanumeric(tag[end]) {
+		end++
+	}
+	return strings.ToLower(tag[1:end])
+}
+
+// index after the closing tag of the element, the end of the body when it is not closed
+func closingTagEnd(body string, index int, name string) int {
+	for nameEnd := len(name) + 2; index+nameEnd <= len(body); index++ {
+		if body[index] == '<' && body[index+1] == '/' && strings.EqualFold(body[index+2:index+nameEnd], name) &&
+			(index+nameEnd == len(body) || !isAsciiAlphanumeric(body[index+nameEnd])) {
+			return tagEnd(body, index)
+		}
+	}
+	return len(body)
+}
+
+func isAsciiAlphanumeric(char byte) bool {
+	return ('a' <= char && char <= 'z') || ('A' <= char && char <= 'Z') || ('0' <= char && char <= '9')
+}
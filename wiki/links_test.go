@@ -0,0 +1,73 @@
+/*
+ *
+ * Copyright 2023 puzzleweb authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package wiki
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/dvaumoron/puzzleweb/common"
+	"go.uber.org/zap"
+)
+
+func makeTestRenderer(pattern string) linkRenderer {
+	return newLinkRenderer(zap.NewNop(), pattern, "", makeTitleNormalizer(false, true))
+}
+
+func TestRenderLinks(t *testing.T) {
+	renderer := makeTestRenderer("")
+	existing := common.MakeSet([]string{"Home"})
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"existing", `<p>[[Home]]</p>`, `<p><a href="/wiki/en/view/Home">Home</a></p>`},
+		{"missing", `<p>[[Other page|see]]</p>`, `<p><a href="/wiki/en/view/Other_page" class="missing-page">see</a></p>`},
+		{"attribute", `<img alt="[[Home]]" title='a > [[Home]]'>`, `<img alt="[[Home]]" title='a > [[Home]]'>`},
+		{"code", `<pre><code>[[Home]]</code></pre>`, `<pre><code>[[Home]]</code></pre>`},
+		{"link", `<a href="/x">[[Home]]</a>`, `<a href="/x">[[Home]]</a>`},
+		{"comment", `<!-- [[Home]] -->`, `<!-- [[Home]] -->`},
+		{"abbr", `<abbr>[[Home]]</abbr>`, `<abbr><a href="/wiki/en/view/Home">Home</a></abbr>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderer.render(tt.body, "/wiki/", "en", existing); got != tt.want {
+				t.Errorf("render(%q): got %q, want %q", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderLinksEscapeLabel(t *testing.T) {
+	renderer := makeTestRenderer(`\{\{([^{}|]+)(?:\|([^{}]*))?\}\}`)
+	got := renderer.render(`<p>{{Home|a & b &amp; c}}</p>`, "/wiki/", "en", common.Set[string]{})
+	want := `<p><a href="/wiki/en/view/Home" class="missing-page">a &amp; b &amp; c</a></p>`
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestBodyLinks(t *testing.T) {
+	renderer := makeTestRenderer("")
+	body := `<p title="[[Hidden]]">[[Home]] [[Other]]</p><code>[[Code]]</code><p>[[Home]]</p>`
+	if got := renderer.bodyLinks(body); !slices.Equal(got, []string{"Home", "Other"}) {
+		t.Errorf("got %v", got)
+	}
+}
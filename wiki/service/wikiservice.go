@@ -22,6 +22,7 @@ import (
 	"context"
 	"sync"
 
+	"github.com/dvaumoron/puzzleweb/common"
 	markdownservice "github.com/dvaumoron/puzzleweb/markdown/service"
 	profileservice "github.com/dvaumoron/puzzleweb/profile/service"
 )
@@ -73,4 +74,7 @@ type WikiService interface {
 	GetVersions(ctx context.Context, userId uint64, lang string, title string) ([]Version, error)
 	DeleteContent(ctx context.Context, userId uint64, lang string, title string, version string) error
 	DeleteRight(ctx context.Context, userId uint64) bool
+	ViewRight(ctx context.Context, userId uint64) error
+	EditRight(ctx context.Context, userId uint64) error
+	ExistingPages(ctx context.Context, userId uint64, lang string, titles []string) (common.Set[string], error)
 }
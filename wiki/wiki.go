@@ -19,14 +19,20 @@
 package wiki
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/dvaumoron/puzzleweb/common"
 	"github.com/dvaumoron/puzzleweb/common/config"
 	puzzleweb "github.com/dvaumoron/puzzleweb/core"
 	"github.com/dvaumoron/puzzleweb/locale"
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 )
 
 const (
@@ -35,41 +41,66 @@ const (
 	viewMode        = "/view/"
 	editMode        = "/edit/"
 	listMode        = "/list/"
+	backlinksMode   = "/backlinks/"
 	titleName       = "title"
 	wikiTitleName   = "WikiTitle"
 	wikiVersionName = "WikiVersion"
 	wikiContentName = "WikiContent"
+	contentName     = "content"
+	crawlTimeOut    = 10 * time.Second // by crawled page
 )
 
 type wikiWidget struct {
 	defaultHandler gin.HandlerFunc
 	viewHandler    gin.HandlerFunc
 	editHandler    gin.HandlerFunc
+	previewHandler gin.HandlerFunc
+	draftHandler   gin.HandlerFunc
 	saveHandler    gin.HandlerFunc
 	listHandler    gin.HandlerFunc
 	deleteHandler  gin.HandlerFunc
+	linksHandler   gin.HandlerFunc
 }
 
 func (w wikiWidget) LoadInto(router gin.IRouter) {
 	router.GET("/", w.defaultHandler)
 	router.GET("/:lang/view/:title", w.viewHandler)
 	router.GET("/:lang/edit/:title", w.editHandler)
+	router.POST("/:lang/preview/:title", w.previewHandler)
+	router.POST("/:lang/draft/:title", w.draftHandler)
 	router.POST("/:lang/save/:title", w.saveHandler)
 	router.GET("/:lang/list/:title", w.listHandler)
 	router.GET("/:lang/delete/:title", w.deleteHandler)
+	router.GET("/:lang/backlinks/:title", w.linksHandler)
 }
 
 func MakeWikiPage(wikiName string, wikiConfig config.WikiConfig) puzzleweb.Page {
 	wikiService := wikiConfig.Service
 	markdownService := wikiConfig.MarkdownService
+	extractSize := wikiConfig.ExtractSize
+	maxContentLength := wikiConfig.MaxContentLength
+	defaultPageSize := wikiConfig.PageSize
+	maxPageSize := wikiConfig.MaxPageSize
 
 	defaultPage := "Welcome"
 	viewTmpl := "wiki/view"
 	editTmpl := "wiki/edit"
 	listTmpl := "wiki/list"
+	backlinksTmpl := "wiki/backlinks"
+	previewTmpl := "wiki/preview"
 	switch args := wikiConfig.Args; len(args) {
 	default:
-		wikiConfig.Logger.Info("MakeWikiPage should be called with 0 to 4 optional arguments.")
+		wikiConfig.Logger.Info("MakeWikiPage should be called with 0 to 6 optional arguments.")
+		fallthrough
+	case 6:
+		if args[5] != "" {
+			previewTmpl = args[5]
+		}
+		fallthrough
+	case 5:
+		if args[4] != "" {
+			backlinksTmpl = args[4]
+		}
 		fallthrough
 	case 4:
 		if args[3] != "" {
@@ -93,6 +124,30 @@ func MakeWikiPage(wikiName string, wikiConfig config.WikiConfig) puzzleweb.Page
 	case 0:
 	}
 
+	normalizeTitle := makeTitleNormalizer(wikiConfig.TitleCaseFold, wikiConfig.TitleSpaceToUnderscore)
+	defaultPage = normalizeTitle(defaultPage)
+	draftKey := func(lang string, title string) string {
+		return wikiName + "/" + lang + "/" + title
+	}
+	renderer := newLinkRenderer(wikiConfig.Logger, wikiConfig.LinkPattern, wikiConfig.MissingLinkClass, normalizeTitle)
+	links := newLinkIndex()
+
+	// mark the internal links to missing pages
+	renderLinks := func(userId uint64, lang string, body string, c *gin.Context) string {
+		bodyLinks := renderer.bodyLinks(body)
+		if len(bodyLinks) == 0 {
+			return body
+		}
+
+		existing, err := wikiService.ExistingPages(c.Request.Context(), userId, lang, bodyLinks)
+		if err != nil {
+			puzzleweb.GetLogger(c).Warn("Failed to check the linked wiki pages", zap.Error(err))
+			// better than marking every link as missing
+			existing = common.MakeSet(bodyLinks)
+		}
+		return renderer.render(body, common.GetBaseUrl(3, c), lang, existing)
+	}
+
 	p := puzzleweb.MakePage(wikiName)
 	p.Widget = wikiWidget{
 		defaultHandler: common.CreateRedirect(func(c *gin.Context) string {
@@ -102,7 +157,8 @@ func MakeWikiPage(wikiName string, wikiConfig config.WikiConfig) puzzleweb.Page
 		viewHandler: puzzleweb.CreateTemplate(func(data gin.H, c *gin.Context) (string, string) {
 			logger := puzzleweb.GetLogger(c)
 			askedLang := c.Param(locale.LangName)
-			title := c.Param(titleName)
+			askedTitle := c.Param(titleName)
+			title := normalizeTitle(askedTitle)
 			lang := puzzleweb.GetLocalesManager(c).CheckLang(askedLang, c)
 
 			if lang != askedLang {
@@ -110,6 +166,9 @@ func MakeWikiPage(wikiName string, wikiConfig config.WikiConfig) puzzleweb.Page
 				common.WriteError(targetBuilder, logger, common.WrongLangKey)
 				return "", targetBuilder.String()
 			}
+			if title != askedTitle {
+				return "", canonicalUrlBuilder(lang, viewMode, title, c).String()
+			}
 
 			userId, _ := data[common.UserIdName].(uint64)
 			version := c.Query(versionName)
@@ -120,17 +179,24 @@ func MakeWikiPage(wikiName string, wikiConfig config.WikiConfig) puzzleweb.Page
 			}
 
 			if content == nil {
-				base := common.GetBaseUrl(3, c)
-				if version == "" {
-					return "", wikiUrlBuilder(base, lang, editMode, title).String()
+				if version != "" {
+					// the asked version does not exist
+					return "", common.ErrorResponse(c, logger, common.ErrNotFound)
 				}
-				return "", wikiUrlBuilder(base, lang, viewMode, title).String()
+				// a missing page is created by the edition
+				links.update(lang, title, nil)
+				return "", wikiUrlBuilder(common.GetBaseUrl(3, c), lang, editMode, title).String()
+			}
+			if version == "" {
+				// last version, keep the index up to date with pages saved by other instances
+				links.update(lang, title, renderer.extractLinks(content.Markdown, title))
 			}
 
 			body, err := content.GetBody(ctx, markdownService)
 			if err != nil {
 				return "", common.DefaultErrorRedirect(logger, err.Error())
 			}
+			body, toc := common.BuildToc(renderLinks(userId, lang, body, c))
 
 			data[wikiTitleName] = title
 			if version != "" {
@@ -138,12 +204,15 @@ func MakeWikiPage(wikiName string, wikiConfig config.WikiConfig) puzzleweb.Page
 			}
 			data[common.BaseUrlName] = common.GetBaseUrl(2, c)
 			data[wikiContentName] = body
+			data["Toc"] = toc
+			common.InitOpenGraph(data, title, body, extractSize, c)
 			return viewTmpl, ""
 		}),
 		editHandler: puzzleweb.CreateTemplate(func(data gin.H, c *gin.Context) (string, string) {
 			logger := puzzleweb.GetLogger(c)
 			askedLang := c.Param(locale.LangName)
-			title := c.Param(titleName)
+			askedTitle := c.Param(titleName)
+			title := normalizeTitle(askedTitle)
 			lang := puzzleweb.GetLocalesManager(c).CheckLang(askedLang, c)
 
 			if lang != askedLang {
@@ -151,6 +220,9 @@ func MakeWikiPage(wikiName string, wikiConfig config.WikiConfig) puzzleweb.Page
 				common.WriteError(targetBuilder, logger, common.WrongLangKey)
 				return "", targetBuilder.String()
 			}
+			if title != askedTitle {
+				return "", canonicalUrlBuilder(lang, editMode, title, c).String()
+			}
 
 			userId, _ := data[common.UserIdName].(uint64)
 			content, err := wikiService.LoadContent(c.Request.Context(), userId, lang, title, "")
@@ -166,43 +238,143 @@ func MakeWikiPage(wikiName string, wikiConfig config.WikiConfig) puzzleweb.Page
 				data[wikiVersionName] = strconv.FormatUint(content.Version, 10)
 				data[wikiContentName] = content.Markdown
 			}
+			if draft := puzzleweb.LoadDraft(c, draftKey(lang, title)); draft != nil {
+				// the version allows to warn when the draft is based on an outdated content
+				data["DraftVersion"] = draft.Get(versionName)
+				data["DraftContent"] = draft.Get(contentName)
+			}
 			return editTmpl, ""
 		}),
-		saveHandler: common.CreateRedirect(func(c *gin.Context) string {
+		previewHandler: puzzleweb.CreateTemplate(func(data gin.H, c *gin.Context) (string, string) {
 			logger := puzzleweb.GetLogger(c)
 			askedLang := c.Param(locale.LangName)
 			lang := puzzleweb.GetLocalesManager(c).CheckLang(askedLang, c)
-			title := c.Param(titleName)
+			title := normalizeTitle(c.Param(titleName))
+
+			if lang != askedLang {
+				targetBuilder := wikiUrlBuilder(common.GetBaseUrl(3, c), lang, viewMode, title)
+				common.WriteError(targetBuilder, logger, common.WrongLangKey)
+				return "", targetBuilder.String()
+			}
+
+			userId, _ := data[common.UserIdName].(uint64)
+			ctx := c.Request.Context()
+			// only an editor can preview, the markdown rendering is not free
+			if err := wikiService.EditRight(ctx, userId); err != nil {
+				return "", common.DefaultErrorRedirect(logger, err.Error())
+			}
+
+			// nothing is stored, the form is sent again with the same version for the save
+			content := c.PostForm(contentName)
+			if common.TooLong(content, maxContentLength) {
+				initEditData(data, title, c.PostForm(versionName), content, c)
+				puzzleweb.InitErrorMsg(data, common.ErrorContentTooLongKey)
+				return editTmpl, ""
+			}
+
+			body, err := markdownService.Apply(ctx, content)
+			if err != nil {
+				return "", common.DefaultErrorRedirect(logger, err.Error())
+			}
+
+			data[wikiTitleName] = title
+			data[common.BaseUrlName] = common.GetBaseUrl(2, c)
+			data[wikiVersionName] = c.PostForm(versionName)
+			data[wikiContentName] = content
+			data["PreviewHTML"] = renderLinks(userId, lang, body, c)
+			return previewTmpl, ""
+		}),
+		// called in background by the edit page, so there is no redirect
+		draftHandler: func(c *gin.Context) {
+			askedLang := c.Param(locale.LangName)
+			title := normalizeTitle(c.Param(titleName))
+			if puzzleweb.GetLocalesManager(c).CheckLang(askedLang, c) != askedLang {
+				puzzleweb.WriteAppError(c, common.ErrNotFound)
+				return
+			}
+
+			err := puzzleweb.SaveDraft(c, draftKey(askedLang, title), url.Values{
+				versionName: {c.PostForm(versionName)}, contentName: {c.PostForm(contentName)},
+			})
+			if err != nil {
+				puzzleweb.WriteAppError(c, err)
+				return
+			}
+			c.Status(http.StatusNoContent)
+		},
+		saveHandler: puzzleweb.CreateTemplate(func(data gin.H, c *gin.Context) (string, string) {
+			logger := puzzleweb.GetLogger(c)
+			askedLang := c.Param(locale.LangName)
+			lang := puzzleweb.GetLocalesManager(c).CheckLang(askedLang, c)
+			title := normalizeTitle(c.Param(titleName))
 
 			targetBuilder := wikiUrlBuilder(common.GetBaseUrl(3, c), lang, viewMode, title)
 			if lang != askedLang {
 				common.WriteError(targetBuilder, logger, common.WrongLangKey)
-				return targetBuilder.String()
+				return "", targetBuilder.String()
 			}
 
-			userId := puzzleweb.GetSessionUserId(c)
+			userId, _ := data[common.UserIdName].(uint64)
 			last := c.PostForm(versionName)
-			content := c.PostForm("content")
+			content := c.PostForm(contentName)
 
-			err := wikiService.StoreContent(c.Request.Context(), userId, lang, title, last, content)
-			if err != nil {
+			if common.TooLong(content, maxContentLength) {
+				initEditData(data, title, last, content, c)
+				puzzleweb.InitErrorMsg(data, common.ErrorContentTooLongKey)
+				return editTmpl, ""
+			}
+
+			ctx := c.Request.Context()
+			err := puzzleweb.CheckVerifiedEmail(c, puzzleweb.VerifiedPost)
+			if err == nil {
+				err = wikiService.StoreContent(ctx, userId, lang, title, last, content)
+			}
+			switch {
+			case err == nil:
+				links.update(lang, title, renderer.extractLinks(content, title))
+				puzzleweb.ClearDraft(c, draftKey(lang, title))
+			case errors.Is(err, common.ErrBaseVersion):
+				// the edition is shown again (instead of losing it) with the current version to merge with
+				current, err := wikiService.LoadContent(ctx, userId, lang, title, "")
+				if err != nil {
+					common.WriteError(targetBuilder, logger, err.Error())
+					break
+				}
+
+				puzzleweb.InitErrorMsg(data, common.ErrorBaseVersionKey)
+				if current == nil {
+					// deleted in the meantime
+					initEditData(data, title, "0", content, c)
+				} else {
+					initEditData(data, title, strconv.FormatUint(current.Version, 10), content, c)
+					data["ConflictContent"] = current.Markdown
+					data["ConflictDiff"] = diffLines(current.Markdown, content)
+				}
+				return editTmpl, ""
+			default:
 				common.WriteError(targetBuilder, logger, err.Error())
 			}
-			return targetBuilder.String()
+			return "", targetBuilder.String()
 		}),
 		listHandler: puzzleweb.CreateTemplate(func(data gin.H, c *gin.Context) (string, string) {
 			logger := puzzleweb.GetLogger(c)
 			askedLang := c.Param(locale.LangName)
+			askedTitle := c.Param(titleName)
+			title := normalizeTitle(askedTitle)
 			lang := puzzleweb.GetLocalesManager(c).CheckLang(askedLang, c)
-			title := c.Param(titleName)
 
 			targetBuilder := wikiUrlBuilder(common.GetBaseUrl(3, c), lang, listMode, title)
 			if lang != askedLang {
 				common.WriteError(targetBuilder, logger, common.WrongLangKey)
 				return "", targetBuilder.String()
 			}
+			if title != askedTitle {
+				return "", targetBuilder.String()
+			}
 
 			userId, _ := data[common.UserIdName].(uint64)
+			pageNumber, pageSize, start, end, _ := common.GetPagination(defaultPageSize, maxPageSize, c)
+
 			ctx := c.Request.Context()
 			versions, err := wikiService.GetVersions(ctx, userId, lang, title)
 			if err != nil {
@@ -210,6 +382,11 @@ func MakeWikiPage(wikiName string, wikiConfig config.WikiConfig) puzzleweb.Page
 				return "", targetBuilder.String()
 			}
 
+			// the wiki service always return all the versions
+			total := uint64(len(versions))
+			versions = versions[min(start, total):min(end, total)]
+
+			common.InitPagination(data, "", pageNumber, pageSize, total, c)
 			data[wikiTitleName] = title
 			data[versionsName] = versions
 			data[common.BaseUrlName] = common.GetBaseUrl(2, c)
@@ -221,7 +398,7 @@ func MakeWikiPage(wikiName string, wikiConfig config.WikiConfig) puzzleweb.Page
 			logger := puzzleweb.GetLogger(c)
 			askedLang := c.Param(locale.LangName)
 			lang := puzzleweb.GetLocalesManager(c).CheckLang(askedLang, c)
-			title := c.Param(titleName)
+			title := normalizeTitle(c.Param(titleName))
 
 			targetBuilder := wikiUrlBuilder(common.GetBaseUrl(3, c), lang, listMode, title)
 			if lang != askedLang {
@@ -234,13 +411,67 @@ func MakeWikiPage(wikiName string, wikiConfig config.WikiConfig) puzzleweb.Page
 			err := wikiService.DeleteContent(c.Request.Context(), userId, lang, title, version)
 			if err != nil {
 				common.WriteError(targetBuilder, logger, err.Error())
+			} else {
+				// the links of the remaining version are indexed again by the next view
+				links.update(lang, title, nil)
 			}
 			return targetBuilder.String()
 		}),
+		linksHandler: puzzleweb.CreateTemplate(func(data gin.H, c *gin.Context) (string, string) {
+			logger := puzzleweb.GetLogger(c)
+			askedLang := c.Param(locale.LangName)
+			askedTitle := c.Param(titleName)
+			title := normalizeTitle(askedTitle)
+			lang := puzzleweb.GetLocalesManager(c).CheckLang(askedLang, c)
+
+			targetBuilder := wikiUrlBuilder(common.GetBaseUrl(3, c), lang, backlinksMode, title)
+			if lang != askedLang {
+				common.WriteError(targetBuilder, logger, common.WrongLangKey)
+				return "", targetBuilder.String()
+			}
+			if title != askedTitle {
+				return "", targetBuilder.String()
+			}
+
+			userId, _ := data[common.UserIdName].(uint64)
+			if err := wikiService.ViewRight(c.Request.Context(), userId); err != nil {
+				return "", common.DefaultErrorRedirect(logger, err.Error())
+			}
+
+			// keep the trace but not the cancellation of the request
+			crawlCtx := context.WithoutCancel(c.Request.Context())
+			links.startCrawl(lang, defaultPage, func(pageTitle string) ([]string, error) {
+				ctx, cancel := context.WithTimeout(crawlCtx, crawlTimeOut)
+				defer cancel()
+
+				content, err := wikiService.LoadContent(ctx, userId, lang, pageTitle, "")
+				if err != nil || content == nil {
+					return nil, err
+				}
+				return renderer.extractLinks(content.Markdown, pageTitle), nil
+			})
+
+			backlinks := links.backlinks(lang, title)
+			data[wikiTitleName] = title
+			data["Backlinks"] = backlinks
+			// orphan pages and pages saved by other instances can be missing
+			data["BacklinksPartial"] = !links.complete(lang)
+			data[common.BaseUrlName] = common.GetBaseUrl(2, c)
+			puzzleweb.InitNoELementMsg(data, len(backlinks), c)
+			return backlinksTmpl, ""
+		}),
 	}
 	return p
 }
 
+// data of the edit template for a submitted content
+func initEditData(data gin.H, title string, version string, content string, c *gin.Context) {
+	data[wikiTitleName] = title
+	data[common.BaseUrlName] = common.GetBaseUrl(2, c)
+	data[wikiVersionName] = version
+	data[wikiContentName] = content
+}
+
 func wikiUrlBuilder(base string, lang string, mode string, title string) *strings.Builder {
 	targetBuilder := new(strings.Builder)
 	targetBuilder.WriteString(base)
@@ -249,3 +480,26 @@ func wikiUrlBuilder(base string, lang string, mode string, title string) *string
 	targetBuilder.WriteString(title)
 	return targetBuilder
 }
+
+// keep the query (like version) when redirecting to the canonical title
+func canonicalUrlBuilder(lang string, mode string, title string, c *gin.Context) *strings.Builder {
+	common.SetPermanentRedirect(c)
+	targetBuilder := wikiUrlBuilder(common.GetBaseUrl(3, c), lang, mode, title)
+	if rawQuery := c.Request.URL.RawQuery; rawQuery != "" {
+		targetBuilder.WriteByte('?')
+		targetBuilder.WriteString(rawQuery)
+	}
+	return targetBuilder
+}
+
+func makeTitleNormalizer(caseFold bool, spaceToUnderscore bool) func(string) string {
+	return func(title string) string {
+		if caseFold {
+			title = strings.ToLower(title)
+		}
+		if spaceToUnderscore {
+			title = strings.Join(strings.Fields(title), "_")
+		}
+		return title
+	}
+}